@@ -0,0 +1,232 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/ban"
+)
+
+// 封禁维度
+const (
+	// BanDimensionIP 按IP封禁
+	BanDimensionIP = "ip"
+	// BanDimensionUser 按用户封禁
+	BanDimensionUser = "user"
+)
+
+// 封禁来源
+const (
+	// BanSourceManual 通过Ban手动封禁
+	BanSourceManual = ban.SourceManual
+	// BanSourceAuto 违规分数达到阈值后自动拉黑
+	BanSourceAuto = ban.SourceAuto
+)
+
+// ErrBanDimensionDisabled 封禁维度未启用动态黑名单（封禁不会生效）
+var ErrBanDimensionDisabled = errors.New("封禁维度未启用动态黑名单")
+
+// BanInfo 封禁信息
+type BanInfo struct {
+	// Dimension 封禁维度（ip/user）
+	Dimension string
+	// Identifier 被封禁的IP或用户ID
+	Identifier string
+	// Reason 封禁原因
+	Reason string
+	// Source 封禁来源（manual/auto）
+	Source string
+	// CreatedAt 封禁时间
+	CreatedAt time.Time
+	// ExpiresAt 到期时间（永久封禁为零值）
+	ExpiresAt time.Time
+}
+
+// Permanent 是否为永久封禁
+func (b *BanInfo) Permanent() bool {
+	return b.ExpiresAt.IsZero()
+}
+
+// Ban 手动封禁IP或用户（duration<=0 表示永久封禁）
+// 配置了IP聚合时封禁IP所在的整个网段
+// 维度需在 auto_ban.dimensions 中或启用 blacklist.dynamic，否则返回 ErrBanDimensionDisabled
+func (l *Limiter) Ban(dimension, identifier string, duration time.Duration, reason string) error {
+	return l.BanContext(context.Background(), dimension, identifier, duration, reason)
+}
+
+// BanContext 手动封禁IP或用户（支持context）
+func (l *Limiter) BanContext(ctx context.Context, dimension, identifier string, duration time.Duration, reason string) error {
+	if err := validateBanTarget(dimension, identifier); err != nil {
+		return err
+	}
+	rs := l.snapshot()
+	if !rs.banDimensions[dimension] {
+		return fmt.Errorf("%w: %s", ErrBanDimensionDisabled, dimension)
+	}
+	identifier = rs.banIdentifier(dimension, identifier)
+
+	if err := l.bans.Ban(ctx, dimension, identifier, duration, reason, BanSourceManual); err != nil {
+		l.notifyStoreError(OperationBan, nil, err)
+		return err
+	}
+	l.metrics.ObserveBan(dimension, BanSourceManual)
+	if len(l.observers) > 0 {
+		event := BanEvent{
+			Time:       time.Now(),
+			Dimension:  dimension,
+			Identifier: identifier,
+			Duration:   duration,
+			Reason:     reason,
+			Source:     BanSourceManual,
+		}
+		l.notify(func(o Observer) { o.OnBan(event) })
+	}
+	return nil
+}
+
+// Unban 解除封禁（包括自动拉黑），同时清除违规分数
+// 配置文件中的静态黑名单不受影响
+func (l *Limiter) Unban(dimension, identifier string) error {
+	return l.UnbanContext(context.Background(), dimension, identifier)
+}
+
+// UnbanContext 解除封禁（支持context）
+func (l *Limiter) UnbanContext(ctx context.Context, dimension, identifier string) error {
+	if err := validateBanTarget(dimension, identifier); err != nil {
+		return err
+	}
+
+	identifier = l.snapshot().banIdentifier(dimension, identifier)
+	existed, err := l.bans.Unban(ctx, dimension, identifier)
+	if err != nil {
+		l.notifyStoreError(OperationUnban, nil, err)
+		return err
+	}
+	if existed && len(l.observers) > 0 {
+		event := UnbanEvent{Time: time.Now(), Dimension: dimension, Identifier: identifier}
+		l.notify(func(o Observer) { o.OnUnban(event) })
+	}
+	return l.ResetViolationsContext(ctx, dimension, identifier)
+}
+
+// ListBans 列出未到期的封禁（dimension 为空时列出所有维度）
+func (l *Limiter) ListBans(dimension string) ([]BanInfo, error) {
+	return l.ListBansContext(context.Background(), dimension)
+}
+
+// ListBansContext 列出未到期的封禁（支持context）
+func (l *Limiter) ListBansContext(ctx context.Context, dimension string) ([]BanInfo, error) {
+	dimensions := []string{BanDimensionIP, BanDimensionUser}
+	if dimension != "" {
+		if err := validateBanDimension(dimension); err != nil {
+			return nil, err
+		}
+		dimensions = []string{dimension}
+	}
+
+	var bans []BanInfo
+	for _, dim := range dimensions {
+		page, err := l.listBans(ctx, dim, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, page...)
+	}
+	return bans, nil
+}
+
+// ListBansPage 分页列出维度下未到期的封禁（按到期时间升序，永久封禁在最后）
+// offset为跳过的记录数，limit为最多返回的记录数（<=0时返回offset之后的全部记录）
+func (l *Limiter) ListBansPage(dimension string, offset, limit int) ([]BanInfo, error) {
+	return l.ListBansPageContext(context.Background(), dimension, offset, limit)
+}
+
+// ListBansPageContext 分页列出维度下未到期的封禁（支持context）
+func (l *Limiter) ListBansPageContext(ctx context.Context, dimension string, offset, limit int) ([]BanInfo, error) {
+	if err := validateBanDimension(dimension); err != nil {
+		return nil, err
+	}
+	return l.listBans(ctx, dimension, offset, limit)
+}
+
+// listBans 读取维度下的一页封禁记录
+func (l *Limiter) listBans(ctx context.Context, dimension string, offset, limit int) ([]BanInfo, error) {
+	records, err := l.bans.List(ctx, dimension, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	bans := make([]BanInfo, 0, len(records))
+	for _, record := range records {
+		info := BanInfo{
+			Dimension:  dimension,
+			Identifier: record.Identifier,
+			Reason:     record.Reason,
+			Source:     record.Source,
+			CreatedAt:  time.UnixMilli(record.CreatedAt),
+		}
+		if record.ExpiresAt > 0 {
+			info.ExpiresAt = time.UnixMilli(record.ExpiresAt)
+		}
+		bans = append(bans, info)
+	}
+	return bans, nil
+}
+
+// GetViolationScore 获取当前违规分数（违规窗口内按权重累计，达到阈值后自动拉黑并清零）
+func (l *Limiter) GetViolationScore(dimension, identifier string) (int64, error) {
+	return l.GetViolationScoreContext(context.Background(), dimension, identifier)
+}
+
+// GetViolationScoreContext 获取当前违规分数（支持context）
+func (l *Limiter) GetViolationScoreContext(ctx context.Context, dimension, identifier string) (int64, error) {
+	if err := validateBanTarget(dimension, identifier); err != nil {
+		return 0, err
+	}
+	identifier = l.snapshot().banIdentifier(dimension, identifier)
+	return l.store.GetContext(ctx, ban.ViolationKey(dimension, identifier))
+}
+
+// ResetViolations 清除违规分数
+func (l *Limiter) ResetViolations(dimension, identifier string) error {
+	return l.ResetViolationsContext(context.Background(), dimension, identifier)
+}
+
+// ResetViolationsContext 清除违规分数（支持context）
+func (l *Limiter) ResetViolationsContext(ctx context.Context, dimension, identifier string) error {
+	if err := validateBanTarget(dimension, identifier); err != nil {
+		return err
+	}
+	identifier = l.snapshot().banIdentifier(dimension, identifier)
+	return l.store.DelContext(ctx, ban.ViolationKey(dimension, identifier))
+}
+
+// banIdentifier 返回封禁使用的标识（ip维度按配置的前缀聚合）
+func (rs *ruleSet) banIdentifier(dimension, identifier string) string {
+	if dimension == BanDimensionIP {
+		return rs.aggregateIP(identifier)
+	}
+	return identifier
+}
+
+// validateBanTarget 验证封禁维度和标识
+func validateBanTarget(dimension, identifier string) error {
+	if err := validateBanDimension(dimension); err != nil {
+		return err
+	}
+	if identifier == "" {
+		return fmt.Errorf("封禁标识不能为空")
+	}
+	return nil
+}
+
+// validateBanDimension 验证封禁维度
+func validateBanDimension(dimension string) error {
+	switch dimension {
+	case BanDimensionIP, BanDimensionUser:
+		return nil
+	default:
+		return fmt.Errorf("无效的封禁维度: %s", dimension)
+	}
+}
@@ -0,0 +1,367 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+func newBanLimiter(t *testing.T, config *Config) *Limiter {
+	t.Helper()
+	store := memory.NewStore(0)
+	t.Cleanup(func() { store.Close() })
+
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("创建限流器失败: %v", err)
+	}
+	return limiter
+}
+
+func TestBan(t *testing.T) {
+	limiter := newBanLimiter(t, &Config{
+		Default:   DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Blacklist: BlacklistConfig{Dynamic: true},
+	})
+
+	if err := limiter.Ban(BanDimensionIP, "1.1.1.1", time.Hour, "刷接口"); err != nil {
+		t.Fatalf("Ban() error = %v", err)
+	}
+	if err := limiter.Ban(BanDimensionUser, "u1", 0, "盗号"); err != nil {
+		t.Fatalf("Ban() error = %v", err)
+	}
+
+	result, _ := limiter.Check("/api", "GET", "1.1.1.1", "")
+	if result.Allowed {
+		t.Error("被封禁的IP应该被拒绝")
+	}
+	result, _ = limiter.Check("/api", "GET", "2.2.2.2", "u1")
+	if result.Allowed {
+		t.Error("被封禁的用户应该被拒绝")
+	}
+	result, _ = limiter.Check("/api", "GET", "2.2.2.2", "u2")
+	if !result.Allowed {
+		t.Error("未封禁的请求应该允许")
+	}
+
+	bans, err := limiter.ListBans("")
+	if err != nil {
+		t.Fatalf("ListBans() error = %v", err)
+	}
+	if len(bans) != 2 {
+		t.Fatalf("len(bans) = %d, want 2", len(bans))
+	}
+	ipBan, userBan := bans[0], bans[1]
+	if ipBan.Dimension != BanDimensionIP || ipBan.Identifier != "1.1.1.1" || ipBan.Reason != "刷接口" || ipBan.Source != BanSourceManual {
+		t.Errorf("IP封禁 = %+v", ipBan)
+	}
+	if ipBan.Permanent() || ipBan.ExpiresAt.Sub(ipBan.CreatedAt) != time.Hour {
+		t.Errorf("IP封禁时长 = %v", ipBan.ExpiresAt.Sub(ipBan.CreatedAt))
+	}
+	if userBan.Dimension != BanDimensionUser || !userBan.Permanent() {
+		t.Errorf("用户封禁 = %+v", userBan)
+	}
+
+	userBans, _ := limiter.ListBans(BanDimensionUser)
+	if len(userBans) != 1 || userBans[0].Identifier != "u1" {
+		t.Errorf("ListBans(user) = %+v", userBans)
+	}
+
+	// 解封后立即恢复访问
+	if err := limiter.Unban(BanDimensionIP, "1.1.1.1"); err != nil {
+		t.Fatalf("Unban() error = %v", err)
+	}
+	result, _ = limiter.Check("/api", "GET", "1.1.1.1", "")
+	if !result.Allowed {
+		t.Error("解封后应该允许")
+	}
+	if bans, _ := limiter.ListBans(BanDimensionIP); len(bans) != 0 {
+		t.Errorf("解封后 ListBans(ip) = %+v", bans)
+	}
+}
+
+func TestListBansPage(t *testing.T) {
+	limiter := newBanLimiter(t, &Config{
+		Default:   DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Blacklist: BlacklistConfig{Dynamic: true},
+	})
+
+	for i, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		limiter.Ban(BanDimensionIP, ip, time.Duration(i+1)*time.Hour, "")
+	}
+
+	page, err := limiter.ListBansPage(BanDimensionIP, 1, 1)
+	if err != nil {
+		t.Fatalf("ListBansPage() error = %v", err)
+	}
+	if len(page) != 1 || page[0].Identifier != "2.2.2.2" || page[0].Dimension != BanDimensionIP {
+		t.Errorf("ListBansPage(1, 1) = %+v", page)
+	}
+	if page, _ := limiter.ListBansPage(BanDimensionIP, 1, 0); len(page) != 2 {
+		t.Errorf("ListBansPage(1, 0) len = %d, want 2", len(page))
+	}
+	if _, err := limiter.ListBansPage("", 0, 10); err == nil {
+		t.Error("分页列出需要指定维度")
+	}
+}
+
+func TestBan_InvalidArguments(t *testing.T) {
+	limiter := newBanLimiter(t, &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		AutoBan: AutoBanConfig{
+			Enabled:            true,
+			Dimensions:         []string{"ip"},
+			ViolationThreshold: 3,
+			ViolationWindow:    "1m",
+			BanDuration:        "1h",
+		},
+	})
+
+	if err := limiter.Ban("region", "cn", time.Hour, ""); err == nil {
+		t.Error("无效维度应该返回错误")
+	}
+	if err := limiter.Ban(BanDimensionIP, "", time.Hour, ""); err == nil {
+		t.Error("空标识应该返回错误")
+	}
+	if _, err := limiter.ListBans("region"); err == nil {
+		t.Error("无效维度应该返回错误")
+	}
+
+	// 只对ip启用了自动拉黑，user维度的封禁不会生效
+	if err := limiter.Ban(BanDimensionUser, "u1", time.Hour, ""); !errors.Is(err, ErrBanDimensionDisabled) {
+		t.Errorf("Ban(user) error = %v, want ErrBanDimensionDisabled", err)
+	}
+	if err := limiter.Ban(BanDimensionIP, "1.1.1.1", time.Hour, ""); err != nil {
+		t.Errorf("Ban(ip) error = %v", err)
+	}
+}
+
+func TestAutoBan_ListedAndViolations(t *testing.T) {
+	limiter := newBanLimiter(t, &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "login", Path: "/login", By: "ip", Params: []string{"1", "1m"}, RecordViolation: true, ViolationWeight: 2},
+		},
+		AutoBan: AutoBanConfig{
+			Enabled:            true,
+			Dimensions:         []string{"ip"},
+			ViolationThreshold: 5,
+			ViolationWindow:    "10m",
+			BanDuration:        "1h",
+		},
+	})
+
+	ip := "9.9.9.9"
+	limiter.Check("/login", "POST", ip, "")
+	limiter.Check("/login", "POST", ip, "")
+
+	score, err := limiter.GetViolationScore(BanDimensionIP, ip)
+	if err != nil || score != 2 {
+		t.Errorf("GetViolationScore() = %d, %v, want 2", score, err)
+	}
+
+	if err := limiter.ResetViolations(BanDimensionIP, ip); err != nil {
+		t.Fatalf("ResetViolations() error = %v", err)
+	}
+	if score, _ := limiter.GetViolationScore(BanDimensionIP, ip); score != 0 {
+		t.Errorf("重置后违规分数 = %d, want 0", score)
+	}
+
+	// 累计3次违规（分数6）触发自动拉黑
+	for i := 0; i < 3; i++ {
+		limiter.Check("/login", "POST", ip, "")
+	}
+
+	bans, err := limiter.ListBans(BanDimensionIP)
+	if err != nil {
+		t.Fatalf("ListBans() error = %v", err)
+	}
+	if len(bans) != 1 || bans[0].Identifier != ip || bans[0].Source != BanSourceAuto || bans[0].Reason == "" {
+		t.Fatalf("自动拉黑应该出现在封禁列表中，bans = %+v", bans)
+	}
+	if bans[0].ExpiresAt.Sub(bans[0].CreatedAt) != time.Hour {
+		t.Errorf("封禁时长 = %v, want 1h", bans[0].ExpiresAt.Sub(bans[0].CreatedAt))
+	}
+
+	// 解封同时清除违规分数
+	limiter.store.IncrByContext(context.Background(), "violation:ip:"+ip, 1)
+	if err := limiter.Unban(BanDimensionIP, ip); err != nil {
+		t.Fatalf("Unban() error = %v", err)
+	}
+	if score, _ := limiter.GetViolationScore(BanDimensionIP, ip); score != 0 {
+		t.Errorf("解封后违规分数 = %d, want 0", score)
+	}
+	if isBanned(t, limiter, &Request{IP: ip}) {
+		t.Error("解封后不应在黑名单中")
+	}
+}
+
+func TestAutoBan_Escalation(t *testing.T) {
+	limiter := newBanLimiter(t, &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "login", Path: "/login", By: "ip", Params: []string{"1", "1m"}, RecordViolation: true},
+		},
+		AutoBan: AutoBanConfig{
+			Enabled:            true,
+			Dimensions:         []string{"ip"},
+			ViolationThreshold: 1,
+			ViolationWindow:    "10m",
+			BanEscalation:      []string{"1h", "6h", "1d"},
+		},
+	})
+
+	ip := "9.9.9.9"
+	want := []time.Duration{time.Hour, 6 * time.Hour, 24 * time.Hour, 24 * time.Hour}
+	for i, duration := range want {
+		// 第1次请求消耗配额（之后窗口内一直超限），后续每次超限都会触发封禁
+		limiter.Check("/login", "POST", ip, "")
+		limiter.Check("/login", "POST", ip, "")
+
+		bans, err := limiter.ListBans(BanDimensionIP)
+		if err != nil {
+			t.Fatalf("ListBans() error = %v", err)
+		}
+		if len(bans) != 1 {
+			t.Fatalf("第%d次: bans = %+v", i+1, bans)
+		}
+		if got := bans[0].ExpiresAt.Sub(bans[0].CreatedAt); got != duration {
+			t.Errorf("第%d次封禁时长 = %v, want %v", i+1, got, duration)
+		}
+
+		if err := limiter.Unban(BanDimensionIP, ip); err != nil {
+			t.Fatalf("Unban() error = %v", err)
+		}
+	}
+
+	// 封禁次数带有记忆时长
+	ttl, _ := limiter.store.TTLContext(context.Background(), "offenses:ip:"+ip)
+	if ttl <= 29*24*time.Hour || ttl > DefaultEscalationWindow {
+		t.Errorf("封禁次数TTL = %v, want ~%v", ttl, DefaultEscalationWindow)
+	}
+}
+
+func TestAutoBan_EscalationConfig(t *testing.T) {
+	base := AutoBanConfig{
+		Enabled:            true,
+		Dimensions:         []string{"ip"},
+		ViolationThreshold: 1,
+		ViolationWindow:    "1m",
+	}
+
+	tests := []struct {
+		name       string
+		escalation []string
+		window     string
+		wantErr    bool
+		wantWindow time.Duration
+	}{
+		{"默认记忆时长", []string{"1h", "7d"}, "", false, DefaultEscalationWindow},
+		{"自定义记忆时长", []string{"1h"}, "90d", false, 90 * 24 * time.Hour},
+		{"无效时长", []string{"1h", "abc"}, "", true, 0},
+		{"时长为0", []string{"0s"}, "", true, 0},
+		{"无效记忆时长", []string{"1h"}, "abc", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			autoBan := base
+			autoBan.BanEscalation = tt.escalation
+			autoBan.EscalationWindow = tt.window
+
+			limiter, err := NewFromConfig(&Config{AutoBan: autoBan}, NewMockStore())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewFromConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && limiter.snapshot().escalationWindow != tt.wantWindow {
+				t.Errorf("escalationWindow = %v, want %v", limiter.snapshot().escalationWindow, tt.wantWindow)
+			}
+		})
+	}
+}
+
+func TestEscalatedBanDuration(t *testing.T) {
+	escalation := []time.Duration{time.Hour, 6 * time.Hour, 24 * time.Hour}
+
+	tests := []struct {
+		offenses int64
+		want     time.Duration
+	}{
+		{0, time.Hour},
+		{1, time.Hour},
+		{2, 6 * time.Hour},
+		{3, 24 * time.Hour},
+		{10, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := escalatedBanDuration(escalation, tt.offenses); got != tt.want {
+			t.Errorf("escalatedBanDuration(%d) = %v, want %v", tt.offenses, got, tt.want)
+		}
+	}
+}
+
+func TestIPAggregation(t *testing.T) {
+	limiter := newBanLimiter(t, &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "login", Path: "/login", By: "ip", Params: []string{"2", "1m"}, RecordViolation: true},
+		},
+		Whitelist: WhitelistConfig{IPs: []string{"2001:db8::ff"}},
+		AutoBan: AutoBanConfig{
+			Enabled:            true,
+			Dimensions:         []string{"ip"},
+			ViolationThreshold: 2,
+			ViolationWindow:    "10m",
+			BanDuration:        "1h",
+		},
+		IPAggregation: IPAggregationConfig{IPv6Prefix: 64},
+	})
+
+	// 同一/64内轮换地址共享限流计数
+	for i, ip := range []string{"2001:db8::1", "2001:db8::2"} {
+		if result, _ := limiter.Check("/login", "POST", ip, ""); !result.Allowed {
+			t.Fatalf("第%d次请求应该允许", i+1)
+		}
+	}
+	if result, _ := limiter.Check("/login", "POST", "2001:db8::3", ""); result.Allowed {
+		t.Fatal("同一网段超出限制应该被拒绝")
+	}
+	if result, _ := limiter.Check("/login", "POST", "2001:db8:0:2::1", ""); !result.Allowed {
+		t.Error("其他网段不受影响")
+	}
+	// 白名单仍按原始IP匹配
+	if result, _ := limiter.Check("/login", "POST", "2001:db8::ff", ""); !result.Allowed {
+		t.Error("白名单IP应该允许")
+	}
+
+	// 违规分数按网段累计
+	if score, _ := limiter.GetViolationScore(BanDimensionIP, "2001:db8::ffff"); score != 1 {
+		t.Errorf("GetViolationScore() = %d, want 1", score)
+	}
+	limiter.Check("/login", "POST", "2001:db8::4", "")
+
+	bans, _ := limiter.ListBans(BanDimensionIP)
+	if len(bans) != 1 || bans[0].Identifier != "2001:db8::/64" {
+		t.Fatalf("应该封禁整个网段，bans = %+v", bans)
+	}
+	if !isBanned(t, limiter, &Request{IP: "2001:db8::abcd"}) {
+		t.Error("网段内其他地址应该被封禁")
+	}
+
+	// 使用网段内任意地址解封
+	if err := limiter.Unban(BanDimensionIP, "2001:db8::5"); err != nil {
+		t.Fatalf("Unban() error = %v", err)
+	}
+	if bans, _ := limiter.ListBans(BanDimensionIP); len(bans) != 0 {
+		t.Errorf("解封后 bans = %+v", bans)
+	}
+
+	// 手动封禁同样按网段生效
+	limiter.Ban(BanDimensionIP, "2001:db8:0:3::1", time.Hour, "")
+	if !isBanned(t, limiter, &Request{IP: "2001:db8:0:3::2"}) {
+		t.Error("手动封禁应该覆盖整个网段")
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrLeaseExpired 续期时租约已到期被回收（名额可能已被其他请求占用）
+var ErrLeaseExpired = errors.New("并发名额租约已到期")
+
+// Release 释放检查结果中占用的并发名额（请求处理完成后调用）
+func (l *Limiter) Release(result *Result) error {
+	return l.ReleaseContext(context.Background(), result)
+}
+
+// ReleaseContext 释放检查结果中占用的并发名额（支持context）
+// 释放后清空result.Leases，重复调用不会产生影响；租约已到期被回收时不视为错误
+func (l *Limiter) ReleaseContext(ctx context.Context, result *Result) error {
+	if result == nil || len(result.Leases) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, lease := range result.Leases {
+		if _, err := l.concurrency.ReleaseContext(ctx, lease.Key, lease.ID); err != nil {
+			errs = append(errs, fmt.Errorf("释放并发名额失败: %w", err))
+		}
+	}
+	result.Leases = nil
+	err := errors.Join(errs...)
+	if err != nil {
+		l.notifyStoreError(OperationRelease, nil, err)
+	}
+	return err
+}
+
+// Renew 续期检查结果中占用的并发名额（处理时间可能超过租约时长时定期调用）
+func (l *Limiter) Renew(result *Result) error {
+	return l.RenewContext(context.Background(), result)
+}
+
+// RenewContext 续期检查结果中占用的并发名额（支持context）
+// 每个租约延长到当前时间之后的租约时长；租约已到期被回收时返回 ErrLeaseExpired，不会重新占用名额
+func (l *Limiter) RenewContext(ctx context.Context, result *Result) error {
+	if result == nil || len(result.Leases) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, lease := range result.Leases {
+		renewed, err := l.concurrency.RenewContext(ctx, lease.Key, lease.ID, lease.Duration)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("续期并发名额失败: %w", err))
+		} else if !renewed {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrLeaseExpired, lease.Key))
+		}
+	}
+	err := errors.Join(errs...)
+	if err != nil {
+		l.notifyStoreError(OperationRenew, nil, err)
+	}
+	return err
+}
+
+// releaseLeases 请求被拒绝时释放已占用的并发名额
+// 释放失败时名额会在租约到期后自动回收，因此忽略错误
+func (l *Limiter) releaseLeases(ctx context.Context, leases []Lease) {
+	for _, lease := range leases {
+		_, _ = l.concurrency.ReleaseContext(ctx, lease.Key, lease.ID)
+	}
+}
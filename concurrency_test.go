@@ -0,0 +1,136 @@
+package ratelimiter
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+func TestCheck_Concurrency(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	yes := true
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "export-concurrency", Path: "/export", By: "user", Algorithm: "concurrency", Params: []string{"2", "5m"}, Continue: &yes},
+			{Name: "export-rate", Path: "/export", By: "user", Params: []string{"3", "1m"}},
+		},
+	}
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("validateConfig() error = %v", err)
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	first, err := limiter.Check("/export", "GET", "1.1.1.1", "u1")
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	second, _ := limiter.Check("/export", "GET", "1.1.1.1", "u1")
+	if !first.Allowed || !second.Allowed || len(first.Leases) != 1 {
+		t.Fatalf("前2个请求应该允许, first = %+v, second = %+v", first, second)
+	}
+
+	// 并发数已满
+	if result, _ := limiter.Check("/export", "GET", "1.1.1.1", "u1"); result.Allowed || len(result.Leases) != 0 {
+		t.Fatalf("超出并发数应该拒绝, result = %+v", result)
+	}
+
+	// 释放后空出名额，重复释放不产生影响
+	if err := limiter.Release(first); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if len(first.Leases) != 0 {
+		t.Errorf("释放后应清空Leases, Leases = %v", first.Leases)
+	}
+	if err := limiter.Release(first); err != nil {
+		t.Errorf("重复Release() error = %v", err)
+	}
+
+	// 第3个请求占用名额后被后续的频率规则拒绝，已占用的名额应立即释放
+	third, _ := limiter.Check("/export", "GET", "1.1.1.1", "u1")
+	if !third.Allowed {
+		t.Fatalf("释放后应该允许, result = %+v", third)
+	}
+	if result, _ := limiter.Check("/export", "GET", "1.1.1.1", "u1"); result.Allowed {
+		t.Fatalf("超出频率限制应该拒绝, result = %+v", result)
+	}
+	count, err := store.ZCount("export-concurrency:user:u1", math.Inf(-1), math.Inf(1))
+	if err != nil {
+		t.Fatalf("ZCard() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("被拒绝的请求不应占用名额, count = %d, want 2", count)
+	}
+}
+
+func TestRelease_NoLeases(t *testing.T) {
+	limiter, err := NewFromConfig(&Config{Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true}}, NewMockStore())
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	if err := limiter.Release(nil); err != nil {
+		t.Errorf("Release(nil) error = %v", err)
+	}
+	if err := limiter.Release(&Result{Allowed: true}); err != nil {
+		t.Errorf("Release() error = %v", err)
+	}
+}
+
+func TestRenew_ExtendsLease(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "export", Path: "/export", By: "user", Algorithm: "concurrency", Params: []string{"1", "300ms"}},
+		},
+	}
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("validateConfig() error = %v", err)
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	held, err := limiter.Check("/export", "GET", "1.1.1.1", "u1")
+	if err != nil || !held.Allowed {
+		t.Fatalf("Check() = %+v, %v", held, err)
+	}
+	if held.Leases[0].Duration != 300*time.Millisecond {
+		t.Errorf("Lease.Duration = %v, want 300ms", held.Leases[0].Duration)
+	}
+
+	// 续期后超过原到期时间仍占用名额
+	time.Sleep(200 * time.Millisecond)
+	if err := limiter.Renew(held); err != nil {
+		t.Fatalf("Renew() error = %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	denied, _ := limiter.Check("/export", "GET", "1.1.1.1", "u1")
+	if denied.Allowed {
+		t.Fatal("续期后的名额不应被回收")
+	}
+	// 建议重试时间为租约的剩余时长
+	if denied.RetryAfterMillis <= 0 || denied.RetryAfterMillis > 300 {
+		t.Errorf("RetryAfterMillis = %d, want (0, 300]", denied.RetryAfterMillis)
+	}
+
+	// 租约到期被回收后不再续期
+	time.Sleep(350 * time.Millisecond)
+	if err := limiter.Renew(held); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("Renew() error = %v, want ErrLeaseExpired", err)
+	}
+	if result, _ := limiter.Check("/export", "GET", "1.1.1.1", "u1"); !result.Allowed {
+		t.Errorf("租约到期后应该允许, result = %+v", result)
+	}
+}
@@ -24,6 +24,10 @@ type Config struct {
 	Blacklist BlacklistConfig `yaml:"blacklist"`
 	// AutoBan 自动拉黑配置
 	AutoBan AutoBanConfig `yaml:"auto_ban"`
+	// IPAggregation IP聚合配置
+	IPAggregation IPAggregationConfig `yaml:"ip_aggregation"`
+	// DecisionLog 决策日志配置
+	DecisionLog DecisionLogConfig `yaml:"decision_log"`
 }
 
 // DefaultConfig 默认配置
@@ -32,6 +36,9 @@ type DefaultConfig struct {
 	Algorithm string `yaml:"algorithm"`
 	// Enabled 是否启用限流
 	Enabled bool `yaml:"enabled"`
+	// Continue 规则通过后是否继续检查后续匹配的规则（规则未设置continue时使用）
+	// 为true时评估所有匹配的规则并返回最严格的结果
+	Continue bool `yaml:"continue"`
 }
 
 // GlobalConfig 全局限流配置
@@ -39,8 +46,9 @@ type GlobalConfig struct {
 	// Algorithm 算法（可选，不指定则使用默认算法）
 	Algorithm string `yaml:"algorithm"`
 	// Params 算法参数数组
-	// - fixed_window/sliding_window: [limit, window]  例如: ["1000", "60s"]
-	// - token_bucket: [capacity, rate]  例如: ["10", "1/s"]
+	// - fixed_window/sliding_window/sliding_window_counter: [limit, window]  例如: ["1000", "60s"]
+	// - token_bucket/leaky_bucket/leaky_bucket_queue/gcra: [capacity, rate]  例如: ["10", "1/s"]
+	// - concurrency: [limit, lease]  例如: ["2", "5m"]（最大并发数和租约时长）
 	Params []string `yaml:"params"`
 }
 
@@ -52,23 +60,32 @@ type RuleConfig struct {
 	Path string `yaml:"path"`
 	// Method HTTP方法（GET/POST等，为空表示所有方法）
 	Method string `yaml:"method"`
-	// By 限流维度（ip/user/path/global）
+	// By 限流维度（ip/user/path/global/custom）
 	By string `yaml:"by"`
-	// Algorithm 限流算法（fixed_window/sliding_window/token_bucket）
+	// Key 自定义维度的key提取器（仅by为custom时使用）
+	// 格式为 name 或 name:arg，例如: api_key、tenant、header:X-Tenant-ID、attr:region
+	Key string `yaml:"key"`
+	// Algorithm 限流算法（fixed_window/sliding_window/sliding_window_counter/token_bucket/leaky_bucket/leaky_bucket_queue/gcra/concurrency）
 	Algorithm string `yaml:"algorithm"`
 	// Params 算法参数数组
-	// - fixed_window/sliding_window: [limit, window]  例如: ["5", "60s"]
-	// - token_bucket: [capacity, rate]  例如: ["10", "1/s"]
+	// - fixed_window/sliding_window/sliding_window_counter: [limit, window]  例如: ["5", "60s"]
+	// - token_bucket/leaky_bucket/leaky_bucket_queue/gcra: [capacity, rate]  例如: ["10", "1/s"]
+	// - concurrency: [limit, lease]  例如: ["2", "5m"]（最大并发数和租约时长）
 	Params []string `yaml:"params"`
 	// RecordViolation 是否记录违规（用于自动拉黑）
 	RecordViolation bool `yaml:"record_violation"`
 	// ViolationWeight 违规权重（默认1，用于分级违规记录）
 	ViolationWeight int `yaml:"violation_weight"`
+	// Continue 本规则通过后是否继续检查后续匹配的规则（为空时使用default.continue）
+	Continue *bool `yaml:"continue"`
+	// Cost 每个请求消耗的配额数量（可选，默认1）
+	// 支持固定值（如 "5"）、header:<name>、attr:<name>、content_length 和 content_length:<unit>（每unit字节计1）
+	Cost string `yaml:"cost"`
 }
 
 // WhitelistConfig 白名单配置
 type WhitelistConfig struct {
-	// IPs IP白名单
+	// IPs IP白名单（支持单个IP和CIDR，如：10.0.0.0/8、2001:db8::/32）
 	IPs []string `yaml:"ips"`
 	// Users 用户白名单
 	Users []string `yaml:"users"`
@@ -76,10 +93,58 @@ type WhitelistConfig struct {
 
 // BlacklistConfig 黑名单配置
 type BlacklistConfig struct {
-	// IPs IP黑名单
+	// IPs IP黑名单（支持单个IP和CIDR）
 	IPs []string `yaml:"ips"`
 	// Users 用户黑名单
 	Users []string `yaml:"users"`
+	// Dynamic 是否检查动态黑名单（通过Ban手动封禁）
+	// 启用自动拉黑的维度始终检查动态黑名单，不受此项影响
+	Dynamic bool `yaml:"dynamic"`
+}
+
+// IPAggregationConfig IP聚合配置
+// 按前缀聚合客户端IP，同一网段共享限流计数、违规分数和封禁（黑白名单仍按原始IP匹配）
+type IPAggregationConfig struct {
+	// IPv6Prefix IPv6聚合前缀长度（如：64，0表示不聚合）
+	IPv6Prefix int `yaml:"ipv6_prefix"`
+	// IPv4Prefix IPv4聚合前缀长度（如：24，0表示不聚合）
+	IPv4Prefix int `yaml:"ipv4_prefix"`
+}
+
+// DecisionLogConfig 决策日志配置
+type DecisionLogConfig struct {
+	// Enabled 是否启用决策日志
+	Enabled bool `yaml:"enabled"`
+	// Path 日志文件路径（stdout/stderr 表示输出到标准输出/标准错误，不轮转）
+	Path string `yaml:"path"`
+	// SampleRate 通过的请求的采样比例（0~1，0表示只记录拒绝的请求），拒绝的请求全部记录
+	SampleRate float64 `yaml:"sample_rate"`
+	// MaxSize 单个日志文件的大小上限（如：100MB，默认100MB），超出后轮转
+	MaxSize string `yaml:"max_size"`
+	// MaxBackups 保留的历史文件数量（0为默认值5，-1表示不保留，轮转时直接删除）
+	MaxBackups int `yaml:"max_backups"`
+}
+
+// validateDecisionLog 验证决策日志配置
+func validateDecisionLog(config *DecisionLogConfig) error {
+	if !config.Enabled {
+		return nil
+	}
+	if config.Path == "" {
+		return fmt.Errorf("决策日志路径不能为空")
+	}
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return fmt.Errorf("决策日志采样比例必须在0到1之间: %v", config.SampleRate)
+	}
+	if config.MaxSize != "" {
+		if _, err := parseSize(config.MaxSize); err != nil {
+			return fmt.Errorf("无效的决策日志大小上限: %s", config.MaxSize)
+		}
+	}
+	if config.MaxBackups < -1 {
+		return fmt.Errorf("决策日志保留文件数量不能小于-1")
+	}
+	return nil
 }
 
 // AutoBanConfig 自动拉黑配置
@@ -94,8 +159,17 @@ type AutoBanConfig struct {
 	ViolationWindow string `yaml:"violation_window"`
 	// BanDuration 封禁时长（如：1h, 24h）
 	BanDuration string `yaml:"ban_duration"`
+	// BanEscalation 递增的封禁时长（如：[1h, 6h, 24h, 7d]）
+	// 第N次封禁使用第N个时长，超出后使用最后一个；设置后忽略BanDuration
+	BanEscalation []string `yaml:"ban_escalation"`
+	// EscalationWindow 封禁次数的记忆时长（如：30d，默认30d）
+	// 自最近一次封禁起超过该时长未再被封禁时，封禁次数清零
+	EscalationWindow string `yaml:"escalation_window"`
 }
 
+// DefaultEscalationWindow 默认的封禁次数记忆时长
+const DefaultEscalationWindow = 30 * 24 * time.Hour
+
 // LoadConfig 从文件加载配置
 func LoadConfig(filename string) (*Config, error) {
 	// 读取文件
@@ -145,14 +219,22 @@ func validateConfig(config *Config) error {
 			return fmt.Errorf("全局限流params数组至少需要2个元素")
 		}
 
-		if algo == string(AlgorithmTokenBucket) {
+		if isRateAlgorithm(Algorithm(algo)) {
 			// params[0]=capacity, params[1]=rate
-			if _, err := parseInt64(config.Global.Params[0]); err != nil {
+			capacity, err := parseInt64(config.Global.Params[0])
+			if err != nil {
 				return fmt.Errorf("无效的全局capacity: %s", config.Global.Params[0])
 			}
-			if _, err := parseRate(config.Global.Params[1]); err != nil {
+			if capacity <= 0 {
+				return fmt.Errorf("全局限流容量必须大于0")
+			}
+			rate, err := parseRate(config.Global.Params[1])
+			if err != nil {
 				return fmt.Errorf("无效的全局rate: %s", config.Global.Params[1])
 			}
+			if rate <= 0 {
+				return fmt.Errorf("全局限流速率必须大于0")
+			}
 		} else {
 			// params[0]=limit, params[1]=window
 			limit, err := parseInt64(config.Global.Params[0])
@@ -162,58 +244,95 @@ func validateConfig(config *Config) error {
 			if limit <= 0 {
 				return fmt.Errorf("全局限流阈值必须大于0")
 			}
-			if _, err := parseDuration(config.Global.Params[1]); err != nil {
+			window, err := parseDuration(config.Global.Params[1])
+			if err != nil {
 				return fmt.Errorf("无效的全局window: %s", config.Global.Params[1])
 			}
+			if Algorithm(algo) == AlgorithmConcurrency && window <= 0 {
+				return fmt.Errorf("全局并发限制租约时长必须大于0")
+			}
 		}
 	}
 
 	// 验证规则
 	for i, rule := range config.Rules {
-		if rule.Path == "" {
-			return fmt.Errorf("规则[%d]缺少path字段", i)
+		if err := validateRuleConfig(i, &rule, config.Default.Algorithm); err != nil {
+			return err
 		}
-		if rule.By == "" {
-			return fmt.Errorf("规则[%d]缺少by字段", i)
+	}
+
+	return validateDecisionLog(&config.DecisionLog)
+}
+
+// validateRuleConfig 验证单条规则配置（i 为规则序号，用于错误信息）
+func validateRuleConfig(i int, rule *RuleConfig, defaultAlgo string) error {
+	if rule.Path == "" {
+		return fmt.Errorf("规则[%d]缺少path字段", i)
+	}
+	if rule.By == "" {
+		return fmt.Errorf("规则[%d]缺少by字段", i)
+	}
+	if !isValidLimitBy(rule.By) {
+		return fmt.Errorf("规则[%d]无效的限流维度: %s", i, rule.By)
+	}
+	if LimitBy(rule.By) == LimitByCustom {
+		if rule.Key == "" {
+			return fmt.Errorf("规则[%d]自定义维度缺少key字段", i)
 		}
-		if !isValidLimitBy(rule.By) {
-			return fmt.Errorf("规则[%d]无效的限流维度: %s", i, rule.By)
+		if _, _, err := lookupKeyExtractor(rule.Key); err != nil {
+			return fmt.Errorf("规则[%d]%w", i, err)
 		}
+	}
 
-		// 验证算法
-		algo := rule.Algorithm
-		if algo == "" {
-			algo = config.Default.Algorithm
+	if err := validateCost(rule.Cost); err != nil {
+		return fmt.Errorf("规则[%d]%w", i, err)
+	}
+
+	// 验证算法
+	algo := rule.Algorithm
+	if algo == "" {
+		algo = defaultAlgo
+	}
+	if !isValidAlgorithm(algo) {
+		return fmt.Errorf("规则[%d]无效的算法: %s", i, algo)
+	}
+
+	// 验证params数组
+	if len(rule.Params) < 2 {
+		return fmt.Errorf("规则[%d]params数组至少需要2个元素", i)
+	}
+
+	if isRateAlgorithm(Algorithm(algo)) {
+		// params[0]=capacity, params[1]=rate
+		capacity, err := parseInt64(rule.Params[0])
+		if err != nil {
+			return fmt.Errorf("规则[%d]无效的capacity: %s", i, rule.Params[0])
 		}
-		if !isValidAlgorithm(algo) {
-			return fmt.Errorf("规则[%d]无效的算法: %s", i, algo)
+		if capacity <= 0 {
+			return fmt.Errorf("规则[%d]容量必须大于0", i)
 		}
-
-		// 验证params数组
-		if len(rule.Params) < 2 {
-			return fmt.Errorf("规则[%d]params数组至少需要2个元素", i)
+		rate, err := parseRate(rule.Params[1])
+		if err != nil {
+			return fmt.Errorf("规则[%d]无效的rate: %s", i, rule.Params[1])
 		}
-
-		if algo == string(AlgorithmTokenBucket) {
-			// params[0]=capacity, params[1]=rate
-			if _, err := parseInt64(rule.Params[0]); err != nil {
-				return fmt.Errorf("规则[%d]无效的capacity: %s", i, rule.Params[0])
-			}
-			if _, err := parseRate(rule.Params[1]); err != nil {
-				return fmt.Errorf("规则[%d]无效的rate: %s", i, rule.Params[1])
-			}
-		} else {
-			// params[0]=limit, params[1]=window
-			limit, err := parseInt64(rule.Params[0])
-			if err != nil {
-				return fmt.Errorf("规则[%d]无效的limit: %s", i, rule.Params[0])
-			}
-			if limit <= 0 {
-				return fmt.Errorf("规则[%d]限流阈值必须大于0", i)
-			}
-			if _, err := parseDuration(rule.Params[1]); err != nil {
-				return fmt.Errorf("规则[%d]无效的window: %s", i, rule.Params[1])
-			}
+		if rate <= 0 {
+			return fmt.Errorf("规则[%d]速率必须大于0", i)
+		}
+	} else {
+		// params[0]=limit, params[1]=window
+		limit, err := parseInt64(rule.Params[0])
+		if err != nil {
+			return fmt.Errorf("规则[%d]无效的limit: %s", i, rule.Params[0])
+		}
+		if limit <= 0 {
+			return fmt.Errorf("规则[%d]限流阈值必须大于0", i)
+		}
+		window, err := parseDuration(rule.Params[1])
+		if err != nil {
+			return fmt.Errorf("规则[%d]无效的window: %s", i, rule.Params[1])
+		}
+		if Algorithm(algo) == AlgorithmConcurrency && window <= 0 {
+			return fmt.Errorf("规则[%d]并发限制租约时长必须大于0", i)
 		}
 	}
 
@@ -223,7 +342,19 @@ func validateConfig(config *Config) error {
 // isValidAlgorithm 检查算法是否有效
 func isValidAlgorithm(algo string) bool {
 	switch Algorithm(algo) {
-	case AlgorithmFixedWindow, AlgorithmSlidingWindow, AlgorithmTokenBucket:
+	case AlgorithmFixedWindow, AlgorithmSlidingWindow, AlgorithmSlidingWindowCounter,
+		AlgorithmTokenBucket, AlgorithmLeakyBucket, AlgorithmLeakyBucketQueue, AlgorithmGCRA,
+		AlgorithmConcurrency:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRateAlgorithm 检查算法是否使用 [capacity, rate] 参数
+func isRateAlgorithm(algo Algorithm) bool {
+	switch algo {
+	case AlgorithmTokenBucket, AlgorithmLeakyBucket, AlgorithmLeakyBucketQueue, AlgorithmGCRA:
 		return true
 	default:
 		return false
@@ -240,8 +371,15 @@ func isValidLimitBy(by string) bool {
 	}
 }
 
-// parseDuration 解析时间窗口字符串
+// parseDuration 解析时间窗口字符串（在time.ParseDuration基础上支持天，如：7d）
 func parseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int64
+		if _, err := fmt.Sscanf(days, "%d", &n); err != nil || fmt.Sprint(n) != days {
+			return 0, fmt.Errorf("无效的时长: %s", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
 	return time.ParseDuration(s)
 }
 
@@ -281,8 +419,8 @@ func setAlgorithmParams(rule *Rule, algo Algorithm, params []string) error {
 		return fmt.Errorf("params数组至少需要2个元素")
 	}
 
-	if algo == AlgorithmTokenBucket {
-		// 令牌桶算法: [capacity, rate]
+	if isRateAlgorithm(algo) {
+		// 令牌桶和漏桶算法: [capacity, rate]
 		cap, err := parseInt64(params[0])
 		if err != nil {
 			return fmt.Errorf("解析capacity失败: %w", err)
@@ -329,8 +467,13 @@ func (rc *RuleConfig) ToRule(defaultAlgo Algorithm) (*Rule, error) {
 		Path:            rc.Path,
 		Method:          strings.ToUpper(rc.Method),
 		By:              LimitBy(rc.By),
+		Key:             rc.Key,
 		RecordViolation: rc.RecordViolation,
 		ViolationWeight: rc.ViolationWeight,
+		Cost:            rc.Cost,
+	}
+	if rc.Continue != nil {
+		rule.Continue = *rc.Continue
 	}
 
 	// 确定使用的算法
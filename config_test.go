@@ -38,6 +38,7 @@ rules:
     algorithm: token_bucket
     params: ["100", "10/s"]
     by: user
+    continue: true
 `
 
 	tmpfile, err := os.CreateTemp("", "rate_limit_*.yaml")
@@ -127,6 +128,14 @@ rules:
 	if rule2.Params[1] != "10/s" {
 		t.Errorf("Rules[1].Params[1] = %v, want 10/s", rule2.Params[1])
 	}
+
+	// 验证continue
+	if rule1.Continue != nil {
+		t.Errorf("Rules[0].Continue = %v, want nil", *rule1.Continue)
+	}
+	if rule2.Continue == nil || !*rule2.Continue {
+		t.Error("Rules[1].Continue should be true")
+	}
 }
 
 func TestLoadConfig_FileNotFound(t *testing.T) {
@@ -209,6 +218,9 @@ func TestParseDuration(t *testing.T) {
 		{"5m", 5 * time.Minute, false},
 		{"1h", time.Hour, false},
 		{"2h", 2 * time.Hour, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"1.5d", 0, true},
+		{"d", 0, true},
 		{"invalid", 0, true},
 		{"", 0, true},
 	}
@@ -287,6 +299,175 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "自定义维度",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "fixed_window"},
+				Rules: []RuleConfig{
+					{Path: "/api/*", By: "custom", Key: "header:X-API-Key", Params: []string{"10", "1m"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "自定义维度缺少key",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "fixed_window"},
+				Rules: []RuleConfig{
+					{Path: "/api/*", By: "custom", Params: []string{"10", "1m"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "漏桶算法",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "leaky_bucket"},
+				Global:  &GlobalConfig{Algorithm: "leaky_bucket_queue", Params: []string{"10", "5/s"}},
+				Rules: []RuleConfig{
+					{Path: "/pay", By: "global", Params: []string{"10", "1/s"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "滑动窗口计数器",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "sliding_window_counter"},
+				Rules: []RuleConfig{
+					{Path: "/api", By: "custom", Key: "tenant", Params: []string{"100000", "1h"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "GCRA算法",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "fixed_window"},
+				Rules: []RuleConfig{
+					{Path: "/api", By: "ip", Algorithm: "gcra", Params: []string{"5", "50/s"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "GCRA容量为0",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "fixed_window"},
+				Rules: []RuleConfig{
+					{Path: "/api", By: "ip", Algorithm: "gcra", Params: []string{"0", "50/s"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "并发限制",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "fixed_window"},
+				Rules: []RuleConfig{
+					{Path: "/export", By: "user", Algorithm: "concurrency", Params: []string{"2", "5m"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "并发限制租约时长为0",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "fixed_window"},
+				Rules: []RuleConfig{
+					{Path: "/export", By: "user", Algorithm: "concurrency", Params: []string{"2", "0s"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "按请求头计算cost",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "token_bucket"},
+				Rules: []RuleConfig{
+					{Path: "/bulk", By: "user", Params: []string{"1000", "100/s"}, Cost: "header:X-Batch-Size"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "无效的cost",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "token_bucket"},
+				Rules: []RuleConfig{
+					{Path: "/bulk", By: "user", Params: []string{"1000", "100/s"}, Cost: "content_length:0"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "漏桶速率为0",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "fixed_window"},
+				Rules: []RuleConfig{
+					{Path: "/pay", By: "global", Algorithm: "leaky_bucket", Params: []string{"10", "0/s"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "自定义维度未知提取器",
+			config: &Config{
+				Default: DefaultConfig{Algorithm: "fixed_window"},
+				Rules: []RuleConfig{
+					{Path: "/api/*", By: "custom", Key: "unknown", Params: []string{"10", "1m"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "决策日志缺少路径",
+			config: &Config{
+				Default:     DefaultConfig{Algorithm: "fixed_window"},
+				DecisionLog: DecisionLogConfig{Enabled: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "决策日志采样比例超出范围",
+			config: &Config{
+				Default:     DefaultConfig{Algorithm: "fixed_window"},
+				DecisionLog: DecisionLogConfig{Enabled: true, Path: "stdout", SampleRate: 1.5},
+			},
+			wantErr: true,
+		},
+		{
+			name: "决策日志无效大小上限",
+			config: &Config{
+				Default:     DefaultConfig{Algorithm: "fixed_window"},
+				DecisionLog: DecisionLogConfig{Enabled: true, Path: "stdout", MaxSize: "100XB"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "决策日志无效保留数量",
+			config: &Config{
+				Default:     DefaultConfig{Algorithm: "fixed_window"},
+				DecisionLog: DecisionLogConfig{Enabled: true, Path: "logs/decision.log", MaxBackups: -2},
+			},
+			wantErr: true,
+		},
+		{
+			name: "决策日志不保留历史文件",
+			config: &Config{
+				Default:     DefaultConfig{Algorithm: "fixed_window"},
+				DecisionLog: DecisionLogConfig{Enabled: true, Path: "logs/decision.log", MaxBackups: -1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "有效的决策日志配置",
+			config: &Config{
+				Default:     DefaultConfig{Algorithm: "fixed_window"},
+				DecisionLog: DecisionLogConfig{Enabled: true, Path: "logs/decision.log", SampleRate: 0.1, MaxSize: "10MB"},
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
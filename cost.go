@@ -0,0 +1,126 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 规则cost表达式（为空时使用 Request.Cost）：
+//   - 固定值，如 "5"
+//   - header:<name>，取请求头的整数值，如 header:X-Batch-Size
+//   - attr:<name>，取自定义属性的整数值，如 attr:batch_size
+//   - content_length 或 content_length:<unit>，按请求体字节数计算，每unit字节计1（不足1个unit按1计算）
+const (
+	costHeaderPrefix    = "header:"
+	costAttrPrefix      = "attr:"
+	costContentLength   = "content_length"
+	costContentLengthAt = "content_length:"
+)
+
+// validateCost 检查cost表达式是否有效
+func validateCost(expr string) error {
+	if expr == "" {
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(expr, costHeaderPrefix):
+		if strings.TrimPrefix(expr, costHeaderPrefix) == "" {
+			return fmt.Errorf("cost缺少请求头名称: %s", expr)
+		}
+	case strings.HasPrefix(expr, costAttrPrefix):
+		if strings.TrimPrefix(expr, costAttrPrefix) == "" {
+			return fmt.Errorf("cost缺少属性名称: %s", expr)
+		}
+	case expr == costContentLength:
+	case strings.HasPrefix(expr, costContentLengthAt):
+		unit, err := strconv.ParseInt(strings.TrimPrefix(expr, costContentLengthAt), 10, 64)
+		if err != nil || unit <= 0 {
+			return fmt.Errorf("无效的cost字节单位: %s", expr)
+		}
+	default:
+		value, err := strconv.ParseInt(expr, 10, 64)
+		if err != nil {
+			return fmt.Errorf("无效的cost: %s", expr)
+		}
+		if value <= 0 {
+			return fmt.Errorf("cost必须大于0: %s", expr)
+		}
+	}
+	return nil
+}
+
+// requestCost 计算请求在规则上消耗的配额数量（至少为1）
+// 规则未配置cost或无法从请求中取到有效值时使用 Request.Cost
+func requestCost(rule *Rule, req *Request) int64 {
+	if cost, ok := evalCost(rule.Cost, req); ok {
+		return cost
+	}
+	if req.Cost > 0 {
+		return req.Cost
+	}
+	return 1
+}
+
+// maxCost 规则单次请求最多能消耗的配额（窗口类为limit，桶类和gcra为capacity）
+// 并发限制按请求占用名额，不使用cost，没有上限
+func maxCost(rule *Rule) int64 {
+	switch rule.Algorithm {
+	case AlgorithmTokenBucket, AlgorithmLeakyBucket, AlgorithmLeakyBucketQueue, AlgorithmGCRA:
+		return rule.Capacity
+	case AlgorithmConcurrency:
+		return math.MaxInt64
+	default:
+		return rule.Limit
+	}
+}
+
+// evalCost 计算cost表达式，返回值和是否有效
+func evalCost(expr string, req *Request) (int64, bool) {
+	var value int64
+	switch {
+	case expr == "":
+		return 0, false
+	case strings.HasPrefix(expr, costHeaderPrefix):
+		v, ok := headerExtractor(req, strings.TrimPrefix(expr, costHeaderPrefix))
+		if !ok {
+			return 0, false
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		value = n
+	case strings.HasPrefix(expr, costAttrPrefix):
+		n, err := strconv.ParseInt(req.Attribute(strings.TrimPrefix(expr, costAttrPrefix)), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		value = n
+	case expr == costContentLength || strings.HasPrefix(expr, costContentLengthAt):
+		if req.ContentLength < 0 {
+			// 长度未知（如分块传输）
+			return 0, false
+		}
+		unit := int64(1)
+		if s, ok := strings.CutPrefix(expr, costContentLengthAt); ok {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+				unit = n
+			}
+		}
+		value = (req.ContentLength + unit - 1) / unit
+	default:
+		n, err := strconv.ParseInt(expr, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		value = n
+	}
+
+	// 空请求体等情况也至少消耗1个配额
+	if value < 1 {
+		value = 1
+	}
+	return value, true
+}
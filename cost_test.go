@@ -0,0 +1,69 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestValidateCost(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"", false},
+		{"5", false},
+		{"header:X-Batch-Size", false},
+		{"attr:batch_size", false},
+		{"content_length", false},
+		{"content_length:1024", false},
+		{"0", true},
+		{"-1", true},
+		{"abc", true},
+		{"header:", true},
+		{"attr:", true},
+		{"content_length:0", true},
+		{"content_length:kb", true},
+	}
+	for _, tt := range tests {
+		if err := validateCost(tt.expr); (err != nil) != tt.wantErr {
+			t.Errorf("validateCost(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRequestCost(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Batch-Size", "20")
+	req := &Request{
+		Header:        header,
+		Attributes:    map[string]string{"batch_size": "7", "bad": "x"},
+		ContentLength: 2500,
+		Cost:          3,
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		req  *Request
+		want int64
+	}{
+		{"未配置cost使用Request.Cost", "", req, 3},
+		{"未配置cost默认1", "", &Request{}, 1},
+		{"固定值", "5", req, 5},
+		{"请求头", "header:X-Batch-Size", req, 20},
+		{"请求头缺失时使用Request.Cost", "header:X-Missing", req, 3},
+		{"属性", "attr:batch_size", req, 7},
+		{"属性无法解析时使用Request.Cost", "attr:bad", req, 3},
+		{"请求体字节数", "content_length", req, 2500},
+		{"按KB计算向上取整", "content_length:1024", req, 3},
+		{"空请求体至少为1", "content_length:1024", &Request{}, 1},
+		{"长度未知时使用Request.Cost", "content_length", &Request{ContentLength: -1, Cost: 4}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requestCost(&Rule{Cost: tt.expr}, tt.req); got != tt.want {
+				t.Errorf("requestCost(%q) = %d, want %d", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 决策日志默认值
+const (
+	// DefaultDecisionLogMaxSize 单个日志文件的默认大小上限
+	DefaultDecisionLogMaxSize = 100 << 20
+	// DefaultDecisionLogMaxBackups 默认保留的历史文件数量
+	DefaultDecisionLogMaxBackups = 5
+)
+
+// DecisionLogger 决策日志，每个决策写入一条结构化日志
+// 实现 Observer 接口，拒绝的请求全部记录，通过的请求按采样比例记录
+// 使用 slog.NewJSONHandler 时每个决策输出一行JSON（JSON Lines）
+type DecisionLogger struct {
+	NopObserver
+	handler slog.Handler
+	// sampleRate 返回当前的采样比例（由配置创建时随配置重新加载更新）
+	sampleRate func() float64
+	// out 采样后的决策交给out写入（由配置创建时为异步队列）
+	out Observer
+}
+
+// NewDecisionLogger 使用slog.Handler创建决策日志，sampleRate为通过的请求的采样比例（0~1）
+// 日志在检查路径上同步写入，写入较慢时可使用 NewAsyncObserver 包装
+func NewDecisionLogger(handler slog.Handler, sampleRate float64) *DecisionLogger {
+	d := &DecisionLogger{
+		handler:    handler,
+		sampleRate: func() float64 { return sampleRate },
+	}
+	d.out = decisionWriter{logger: d}
+	return d
+}
+
+// OnAllowed 按采样比例记录通过的请求
+func (d *DecisionLogger) OnAllowed(event DecisionEvent) {
+	rate := d.sampleRate()
+	if rate <= 0 || (rate < 1 && rand.Float64() >= rate) {
+		return
+	}
+	d.out.OnAllowed(event)
+}
+
+// OnDenied 记录被拒绝的请求
+func (d *DecisionLogger) OnDenied(event DecisionEvent) {
+	d.out.OnDenied(event)
+}
+
+// decisionWriter 不经采样直接写入决策日志
+type decisionWriter struct {
+	NopObserver
+	logger *DecisionLogger
+}
+
+func (w decisionWriter) OnAllowed(event DecisionEvent) {
+	w.logger.log(slog.LevelInfo, event)
+}
+
+func (w decisionWriter) OnDenied(event DecisionEvent) {
+	w.logger.log(slog.LevelWarn, event)
+}
+
+// log 写入一条决策日志
+func (d *DecisionLogger) log(level slog.Level, event DecisionEvent) {
+	ctx := context.Background()
+	if !d.handler.Enabled(ctx, level) {
+		return
+	}
+
+	result := event.Result
+	record := slog.NewRecord(event.Time, level, "ratelimit.decision", 0)
+	record.AddAttrs(
+		slog.String("decision", decision(result.Allowed)),
+		slog.String("rule", event.Rule),
+		slog.String("dimension", result.Dimension),
+		slog.String("key", event.Key),
+		slog.String("algorithm", string(event.Algorithm)),
+		slog.Int64("limit", result.Limit),
+		slog.Int64("remaining", result.Remaining),
+	)
+	if !result.Allowed {
+		record.AddAttrs(
+			slog.String("reason", string(result.Reason)),
+			slog.Int64("retry_after_ms", result.RetryAfterMillis),
+		)
+	}
+
+	req := event.Request
+	record.AddAttrs(
+		slog.String("path", req.Path),
+		slog.String("method", req.Method),
+		slog.String("ip", req.IP),
+		slog.String("user_id", req.UserID),
+	)
+	if len(req.Attributes) > 0 {
+		keys := make([]string, 0, len(req.Attributes))
+		for k := range req.Attributes {
+			keys = append(keys, k)
+		}
+		attrs := make([]any, 0, len(keys))
+		sort.Strings(keys)
+		for _, k := range keys {
+			attrs = append(attrs, slog.String(k, req.Attributes[k]))
+		}
+		record.AddAttrs(slog.Group("attributes", attrs...))
+	}
+
+	_ = d.handler.Handle(ctx, record)
+}
+
+// openDecisionLog 按配置打开决策日志，先采样再交给异步队列写入，避免磁盘IO阻塞检查路径
+// 返回的关闭函数写完队列中的日志并关闭文件
+func (l *Limiter) openDecisionLog(config DecisionLogConfig) (*DecisionLogger, func() error, error) {
+	var w io.Writer
+	var file *RotatingFile
+	switch config.Path {
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		maxSize := int64(DefaultDecisionLogMaxSize)
+		if config.MaxSize != "" {
+			size, err := parseSize(config.MaxSize)
+			if err != nil {
+				return nil, nil, fmt.Errorf("无效的决策日志大小上限: %s", config.MaxSize)
+			}
+			maxSize = size
+		}
+		// 0表示使用默认值，-1表示不保留历史文件
+		maxBackups := config.MaxBackups
+		switch maxBackups {
+		case 0:
+			maxBackups = DefaultDecisionLogMaxBackups
+		case -1:
+			maxBackups = 0
+		}
+		var err error
+		file, err = NewRotatingFile(config.Path, maxSize, maxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = file
+	}
+
+	logger := &DecisionLogger{
+		handler: slog.NewJSONHandler(w, nil),
+		// 采样比例随配置重新加载生效，路径和轮转设置变化时重新加载会失败（见 decisionLogChanged）
+		sampleRate: func() float64 { return l.snapshot().config.DecisionLog.SampleRate },
+	}
+	queue := NewAsyncObserver(decisionWriter{logger: logger}, 0)
+	logger.out = queue
+
+	closeFn := func() error {
+		queue.Close()
+		if file != nil {
+			return file.Close()
+		}
+		return nil
+	}
+	return logger, closeFn, nil
+}
+
+// decisionLogChanged 判断决策日志的输出设置是否变化（采样比例除外）
+// 这些设置只在创建限流器时读取，重新加载时无法生效
+func decisionLogChanged(old, new DecisionLogConfig) bool {
+	if old.Enabled != new.Enabled {
+		return true
+	}
+	if !new.Enabled {
+		return false
+	}
+	return old.Path != new.Path || old.MaxSize != new.MaxSize || old.MaxBackups != new.MaxBackups
+}
+
+// Close 释放限流器持有的资源（写完并关闭决策日志）
+func (l *Limiter) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		for _, fn := range l.closers {
+			if e := fn(); e != nil && err == nil {
+				err = e
+			}
+		}
+	})
+	return err
+}
+
+// RotatingFile 按大小轮转的日志文件
+// 文件超出大小上限时重命名为 path.1（已有的历史文件依次后移），超出保留数量的历史文件被删除
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile 打开（或创建）日志文件，maxSize<=0 表示不轮转，maxBackups<=0 表示轮转时直接删除不保留历史文件
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open 以追加方式打开日志文件
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("读取日志文件信息失败: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write 写入日志，写入后超出大小上限时先轮转
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		// 轮转失败时继续写入原文件（下次写入时重试轮转），只有重新打开也失败时才丢弃日志
+		if rotateErr = f.rotate(); f.file == nil {
+			return 0, rotateErr
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// rotate 关闭当前文件，历史文件依次后移后重新打开
+// 重命名或删除失败时重新打开原文件，避免之后的写入全部返回 os.ErrClosed
+func (f *RotatingFile) rotate() error {
+	closeErr := f.file.Close()
+	f.file = nil
+	if closeErr != nil {
+		return errors.Join(closeErr, f.open())
+	}
+
+	var err error
+	if f.maxBackups > 0 {
+		_ = os.Remove(f.backupPath(f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			_ = os.Rename(f.backupPath(i), f.backupPath(i+1))
+		}
+		err = os.Rename(f.path, f.backupPath(1))
+	} else {
+		err = os.Remove(f.path)
+	}
+	if err != nil {
+		return errors.Join(fmt.Errorf("轮转日志文件失败: %w", err), f.open())
+	}
+	return f.open()
+}
+
+// backupPath 第i个历史文件的路径
+func (f *RotatingFile) backupPath(i int) string {
+	return f.path + "." + strconv.Itoa(i)
+}
+
+// Close 关闭日志文件
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// parseSize 解析大小（如：512KB、100MB、1GB，不带单位时为字节）
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("大小必须大于0")
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,314 @@
+package ratelimiter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+// decodeLines 解析JSON Lines
+func decodeLines(t *testing.T, data []byte) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("无效的JSON行 %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestDecisionLogger(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	var buf bytes.Buffer
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "登录", Path: "/login", By: "ip", Params: []string{"1", "1m"}},
+		},
+		Blacklist: BlacklistConfig{IPs: []string{"10.0.0.1"}},
+	}
+	logger := NewDecisionLogger(slog.NewJSONHandler(&buf, nil), 0)
+	limiter, err := NewFromConfig(config, store, WithObserver(logger))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	ctx := context.Background()
+	req := &Request{Path: "/login", Method: "POST", IP: "1.2.3.4", Attributes: map[string]string{"tenant": "acme"}}
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.CheckRequestContext(ctx, req); err != nil {
+			t.Fatalf("CheckRequestContext() error = %v", err)
+		}
+	}
+	if _, err := limiter.CheckRequestContext(ctx, &Request{Path: "/login", Method: "POST", IP: "10.0.0.1"}); err != nil {
+		t.Fatalf("CheckRequestContext() error = %v", err)
+	}
+
+	// 采样比例为0时只记录拒绝的请求
+	lines := decodeLines(t, buf.Bytes())
+	if len(lines) != 2 {
+		t.Fatalf("日志行数 = %d, want 2", len(lines))
+	}
+
+	limited := lines[0]
+	want := map[string]interface{}{
+		"level":     "WARN",
+		"msg":       "ratelimit.decision",
+		"decision":  "denied",
+		"rule":      "登录",
+		"algorithm": "fixed_window",
+		"reason":    string(ReasonRuleLimit),
+		"dimension": "ip",
+		"path":      "/login",
+		"method":    "POST",
+		"ip":        "1.2.3.4",
+		"limit":     float64(1),
+		"remaining": float64(0),
+	}
+	for k, v := range want {
+		if limited[k] != v {
+			t.Errorf("%s = %v, want %v", k, limited[k], v)
+		}
+	}
+	if key, _ := limited["key"].(string); !strings.Contains(key, "1.2.3.4") {
+		t.Errorf("key = %v, want containing IP", limited["key"])
+	}
+	if attrs, _ := limited["attributes"].(map[string]interface{}); attrs["tenant"] != "acme" {
+		t.Errorf("attributes = %v, want tenant=acme", limited["attributes"])
+	}
+	if _, ok := limited["time"]; !ok {
+		t.Error("缺少time字段")
+	}
+
+	blacklisted := lines[1]
+	if blacklisted["reason"] != string(ReasonBlacklisted) || blacklisted["rule"] != "" {
+		t.Errorf("黑名单日志 reason = %v, rule = %v", blacklisted["reason"], blacklisted["rule"])
+	}
+}
+
+func TestDecisionLogger_Sampling(t *testing.T) {
+	event := DecisionEvent{Rule: "api", Result: Result{Allowed: true, Limit: 10, Remaining: 9}}
+
+	var all bytes.Buffer
+	full := NewDecisionLogger(slog.NewJSONHandler(&all, nil), 1)
+	for i := 0; i < 10; i++ {
+		full.OnAllowed(event)
+	}
+	lines := decodeLines(t, all.Bytes())
+	if len(lines) != 10 {
+		t.Fatalf("采样比例为1时日志行数 = %d, want 10", len(lines))
+	}
+	if lines[0]["level"] != "INFO" || lines[0]["decision"] != "allowed" {
+		t.Errorf("level = %v, decision = %v", lines[0]["level"], lines[0]["decision"])
+	}
+	if _, ok := lines[0]["reason"]; ok {
+		t.Error("通过的请求不应包含reason字段")
+	}
+
+	var none bytes.Buffer
+	off := NewDecisionLogger(slog.NewJSONHandler(&none, nil), 0)
+	for i := 0; i < 10; i++ {
+		off.OnAllowed(event)
+	}
+	if none.Len() != 0 {
+		t.Errorf("采样比例为0时不应记录通过的请求: %s", none.String())
+	}
+}
+
+func TestDecisionLog_FromConfig(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	path := filepath.Join(t.TempDir(), "logs", "decision.log")
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "api", Path: "/api", By: "ip", Params: []string{"1", "1m"}},
+		},
+		DecisionLog: DecisionLogConfig{Enabled: true, Path: path, SampleRate: 1},
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.Check("/api", "GET", "1.2.3.4", ""); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+
+	// 重新加载后采样比例生效
+	reloaded := *config
+	reloaded.DecisionLog.SampleRate = 0
+	if err := limiter.ReloadConfig(&reloaded); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+	if _, err := limiter.Check("/api", "GET", "5.6.7.8", ""); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if err := limiter.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := limiter.Close(); err != nil {
+		t.Fatalf("重复Close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	lines := decodeLines(t, data)
+	if len(lines) != 2 {
+		t.Fatalf("日志行数 = %d, want 2", len(lines))
+	}
+	if lines[0]["decision"] != "allowed" || lines[1]["decision"] != "denied" {
+		t.Errorf("decision = %v, %v", lines[0]["decision"], lines[1]["decision"])
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decision.log")
+	f, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingFile() error = %v", err)
+	}
+
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := f.Write([]byte("x")); err == nil {
+		t.Error("关闭后写入应返回错误")
+	}
+
+	want := map[string]string{
+		path:        "dddddddd\n",
+		path + ".1": "cccccccc\n",
+		path + ".2": "bbbbbbbb\n",
+	}
+	for p, content := range want {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", p, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", p, data, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("超出保留数量的历史文件应被删除")
+	}
+}
+
+func TestRotatingFile_NoBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decision.log")
+	f, err := NewRotatingFile(path, 10, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile() error = %v", err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != "bbbbbbbb\n" {
+		t.Errorf("%s = %q, want %q", path, data, "bbbbbbbb\n")
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Error("不保留历史文件时不应生成 .1")
+	}
+}
+
+func TestRotatingFile_RotateFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decision.log")
+	f, err := NewRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatalf("NewRotatingFile() error = %v", err)
+	}
+	defer f.Close()
+
+	// 历史文件路径被非空目录占用，重命名失败
+	if err := os.MkdirAll(filepath.Join(path+".1", "busy"), 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if _, err := f.Write([]byte("aaaaaaaa\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if _, err := f.Write([]byte("bbbbbbbb\n")); err == nil {
+		t.Error("轮转失败时应返回错误")
+	}
+
+	// 轮转失败后仍写入原文件，不会返回 os.ErrClosed
+	if _, err := f.Write([]byte("cccccccc\n")); errors.Is(err, os.ErrClosed) {
+		t.Fatalf("轮转失败后 Write() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "aaaaaaaa\nbbbbbbbb\ncccccccc\n" {
+		t.Errorf("%s = %q", path, data)
+	}
+
+	// 故障恢复后重新轮转
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatalf("RemoveAll() error = %v", err)
+	}
+	if _, err := f.Write([]byte("dddddddd\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "dddddddd\n" {
+		t.Errorf("%s = %q, want %q", path, data, "dddddddd\n")
+	}
+	if data, _ := os.ReadFile(path + ".1"); string(data) != "aaaaaaaa\nbbbbbbbb\ncccccccc\n" {
+		t.Errorf("%s.1 = %q", path, data)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"100MB", 100 << 20, false},
+		{"512kb", 512 << 10, false},
+		{"1GB", 1 << 30, false},
+		{"2048", 2048, false},
+		{"10 MB", 10 << 20, false},
+		{"0MB", 0, true},
+		{"MB", 0, true},
+		{"10XB", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
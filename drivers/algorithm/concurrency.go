@@ -0,0 +1,230 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// ConcurrencyAcquireScript 并发限制获取Lua脚本（清理过期租约、计数和占用在一次调用中原子完成）
+// KEYS[1]=key, ARGV=[limit, now(毫秒), expires_at(毫秒), lease_id, lease(毫秒)]
+// 返回 {allowed, count, earliest}，earliest为最早到期的租约时间（没有租约时为false）
+const ConcurrencyAcquireScript = `
+	local key = KEYS[1]
+	local limit = tonumber(ARGV[1])
+
+	-- 清理已过期的租约（持有者崩溃未释放）
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', ARGV[2])
+
+	local count = redis.call('ZCARD', key)
+	local allowed = 0
+	if count < limit then
+		redis.call('ZADD', key, ARGV[3], ARGV[4])
+		redis.call('PEXPIRE', key, ARGV[5])
+		count = count + 1
+		allowed = 1
+	end
+
+	local earliest = false
+	local first = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+	if #first == 2 then
+		earliest = first[2]
+	end
+
+	return {allowed, count, earliest}
+`
+
+// ConcurrencyReleaseScript 并发限制释放Lua脚本
+// KEYS[1]=key, ARGV=[lease_id]，返回是否释放了租约（1/0）
+const ConcurrencyReleaseScript = `
+	return redis.call('ZREM', KEYS[1], ARGV[1])
+`
+
+// ConcurrencyRenewScript 并发限制续期Lua脚本（延长仍然有效的租约）
+// KEYS[1]=key, ARGV=[lease_id, now(毫秒), expires_at(毫秒), lease(毫秒)]，返回是否续期成功（1/0）
+// 租约已到期或已被回收时不续期，避免复活已经让出的名额
+const ConcurrencyRenewScript = `
+	local key = KEYS[1]
+	local score = redis.call('ZSCORE', key, ARGV[1])
+	if not score or tonumber(score) <= tonumber(ARGV[2]) then
+		return 0
+	end
+
+	redis.call('ZADD', key, ARGV[3], ARGV[1])
+	if redis.call('PTTL', key) < tonumber(ARGV[4]) then
+		redis.call('PEXPIRE', key, ARGV[4])
+	end
+	return 1
+`
+
+// ConcurrencyPeekScript 并发限制只读Lua脚本（不清理、不占用）
+// KEYS[1]=key, ARGV=[limit, now(毫秒)]，返回值与 ConcurrencyAcquireScript 相同，count为当前有效租约数
+const ConcurrencyPeekScript = `
+	local key = KEYS[1]
+	local limit = tonumber(ARGV[1])
+	local now = '(' .. ARGV[2]
+
+	local count = redis.call('ZCOUNT', key, now, '+inf')
+	local allowed = 0
+	if count < limit then
+		allowed = 1
+	end
+
+	local earliest = false
+	local first = redis.call('ZRANGEBYSCORE', key, now, '+inf', 'WITHSCORES', 'LIMIT', 0, 1)
+	if #first == 2 then
+		earliest = first[2]
+	end
+
+	return {allowed, count, earliest}
+`
+
+// ConcurrencyLimiter 并发限流器（限制同时处理中的请求数）
+// 每个占用的名额是一个带到期时间的租约，持有者崩溃未释放时租约到期后自动回收
+type ConcurrencyLimiter struct {
+	store ContextStore
+}
+
+// NewConcurrencyLimiter 创建并发限流器
+func NewConcurrencyLimiter(store Store) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		store: toContextStore(store),
+	}
+}
+
+// Acquire 占用一个名额，允许时返回的LeaseID用于Release
+func (l *ConcurrencyLimiter) Acquire(key string, limit int64, lease time.Duration) (*Context, error) {
+	return l.AcquireContext(context.Background(), key, limit, lease)
+}
+
+// AcquireContext 占用一个名额（支持context）
+func (l *ConcurrencyLimiter) AcquireContext(ctx context.Context, key string, limit int64, lease time.Duration) (*Context, error) {
+	now := time.Now()
+	leaseMillis := durationMillis(lease)
+	leaseID := strconv.FormatInt(now.UnixNano(), 36) + "-" + strconv.FormatUint(rand.Uint64(), 36)
+
+	// 执行Lua脚本
+	result, err := l.store.EvalContext(ctx, ConcurrencyAcquireScript, []string{key},
+		limit, now.UnixMilli(), now.UnixMilli()+leaseMillis, leaseID, leaseMillis)
+	if err != nil {
+		return nil, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+	ctxResult, err := parseConcurrency(result, now, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	if ctxResult.Allowed {
+		ctxResult.LeaseID = leaseID
+	}
+	return ctxResult, nil
+}
+
+// Peek 检查是否还有空闲名额（不占用）
+func (l *ConcurrencyLimiter) Peek(key string, limit int64, lease time.Duration) (*Context, error) {
+	return l.PeekContext(context.Background(), key, limit, lease)
+}
+
+// PeekContext 检查是否还有空闲名额（不占用，支持context）
+func (l *ConcurrencyLimiter) PeekContext(ctx context.Context, key string, limit int64, lease time.Duration) (*Context, error) {
+	now := time.Now()
+
+	// 执行Lua脚本
+	result, err := l.store.EvalContext(ctx, ConcurrencyPeekScript, []string{key}, limit, now.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+	return parseConcurrency(result, now, limit, lease)
+}
+
+// parseConcurrency 解析并发限制脚本的返回值 {allowed, count, earliest}
+func parseConcurrency(result interface{}, now time.Time, limit int64, lease time.Duration) (*Context, error) {
+	// 解析结果
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 3 {
+		return nil, fmt.Errorf("Lua脚本返回格式错误")
+	}
+	allowedFlag, err := toInt64(values[0])
+	if err != nil {
+		return nil, err
+	}
+	count, err := toInt64(values[1])
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := allowedFlag == 1
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	// 最早的租约到期时一定会空出名额（通常请求结束时就会提前释放）
+	resetAt := now.Add(lease)
+	if values[2] != nil {
+		earliest, err := toFloat64(values[2])
+		if err != nil {
+			return nil, err
+		}
+		resetAt = time.UnixMilli(int64(earliest))
+	}
+
+	ctxResult := &Context{
+		Allowed:     allowed,
+		Limit:       limit,
+		Remaining:   remaining,
+		Reset:       resetAt.Unix(),
+		ResetMillis: resetAt.UnixMilli(),
+	}
+	if !allowed {
+		// 最迟在最早的租约到期时空出名额，持有者提前释放时可以更早重试
+		wait := resetAt.Sub(now)
+		if wait < time.Millisecond {
+			wait = time.Millisecond
+		}
+		ctxResult.RetryAfter = ceilSeconds(wait)
+		ctxResult.RetryAfterMillis = (wait + time.Millisecond - 1).Milliseconds()
+	}
+	return ctxResult, nil
+}
+
+// Renew 将租约延长到当前时间之后lease，返回租约是否仍然有效（已到期被回收时返回false，不再续期）
+// 处理时间可能超过租约时长的持有者应在租约到期前定期续期
+func (l *ConcurrencyLimiter) Renew(key, leaseID string, lease time.Duration) (bool, error) {
+	return l.RenewContext(context.Background(), key, leaseID, lease)
+}
+
+// RenewContext 延长租约（支持context）
+func (l *ConcurrencyLimiter) RenewContext(ctx context.Context, key, leaseID string, lease time.Duration) (bool, error) {
+	now := time.Now()
+	leaseMillis := durationMillis(lease)
+	result, err := l.store.EvalContext(ctx, ConcurrencyRenewScript, []string{key},
+		leaseID, now.UnixMilli(), now.UnixMilli()+leaseMillis, leaseMillis)
+	if err != nil {
+		return false, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+	renewed, err := toInt64(result)
+	if err != nil {
+		return false, err
+	}
+	return renewed > 0, nil
+}
+
+// Release 释放名额，返回租约是否仍然有效（已到期被回收时返回false）
+func (l *ConcurrencyLimiter) Release(key, leaseID string) (bool, error) {
+	return l.ReleaseContext(context.Background(), key, leaseID)
+}
+
+// ReleaseContext 释放名额（支持context）
+func (l *ConcurrencyLimiter) ReleaseContext(ctx context.Context, key, leaseID string) (bool, error) {
+	result, err := l.store.EvalContext(ctx, ConcurrencyReleaseScript, []string{key}, leaseID)
+	if err != nil {
+		return false, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+	released, err := toInt64(result)
+	if err != nil {
+		return false, err
+	}
+	return released > 0, nil
+}
@@ -0,0 +1,105 @@
+package algorithm
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter_Acquire(t *testing.T) {
+	store := &MockStoreWithResult{MockStore: *NewMockStore()}
+	limiter := NewConcurrencyLimiter(store)
+
+	// 占用成功，返回租约ID
+	earliest := time.Now().Add(30 * time.Second).UnixMilli()
+	// Redis返回的zset分数为字符串
+	store.result = []interface{}{int64(1), int64(2), strconv.FormatInt(earliest, 10)}
+	result, err := limiter.Acquire("test", 3, time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if !result.Allowed || result.Remaining != 1 || result.Limit != 3 || result.LeaseID == "" {
+		t.Errorf("Acquire() = %+v", result)
+	}
+	if result.Reset != time.UnixMilli(earliest).Unix() {
+		t.Errorf("Reset = %d, want %d", result.Reset, time.UnixMilli(earliest).Unix())
+	}
+	if lease, _ := store.gotArgs[4].(int64); lease != 60000 {
+		t.Errorf("lease = %v, want 60000", store.gotArgs[4])
+	}
+
+	// 每次占用的租约ID不同
+	first := result.LeaseID
+	result, err = limiter.Acquire("test", 3, time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if result.LeaseID == first {
+		t.Errorf("租约ID重复: %s", first)
+	}
+
+	// 名额已满时拒绝，不返回租约ID
+	store.result = []interface{}{int64(0), int64(3), earliest}
+	result, err = limiter.Acquire("test", 3, time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if result.Allowed || result.Remaining != 0 || result.LeaseID != "" {
+		t.Errorf("Acquire() = %+v", result)
+	}
+	// 建议重试时间为最早的租约到期时间
+	if result.RetryAfter != 30 || result.RetryAfterMillis < 29000 || result.RetryAfterMillis > 30000 {
+		t.Errorf("RetryAfter = %d (%dms), want 30s", result.RetryAfter, result.RetryAfterMillis)
+	}
+}
+
+func TestConcurrencyLimiter_Renew(t *testing.T) {
+	store := &MockStoreWithResult{MockStore: *NewMockStore(), result: int64(1)}
+	limiter := NewConcurrencyLimiter(store)
+
+	renewed, err := limiter.Renew("test", "lease-1", time.Minute)
+	if err != nil || !renewed {
+		t.Errorf("Renew() = %v, %v, want true", renewed, err)
+	}
+	if id, _ := store.gotArgs[0].(string); id != "lease-1" {
+		t.Errorf("lease_id = %v, want lease-1", store.gotArgs[0])
+	}
+	now, _ := store.gotArgs[1].(int64)
+	if expiresAt, _ := store.gotArgs[2].(int64); expiresAt-now != 60000 {
+		t.Errorf("expires_at - now = %d, want 60000", expiresAt-now)
+	}
+
+	// 租约已到期被回收，不再续期
+	store.result = int64(0)
+	renewed, err = limiter.Renew("test", "lease-1", time.Minute)
+	if err != nil || renewed {
+		t.Errorf("Renew() = %v, %v, want false", renewed, err)
+	}
+}
+
+func TestConcurrencyLimiter_Release(t *testing.T) {
+	store := &MockStoreWithResult{MockStore: *NewMockStore(), result: int64(1)}
+	limiter := NewConcurrencyLimiter(store)
+
+	released, err := limiter.Release("test", "lease-1")
+	if err != nil || !released {
+		t.Errorf("Release() = %v, %v, want true", released, err)
+	}
+	if id, _ := store.gotArgs[0].(string); id != "lease-1" {
+		t.Errorf("lease_id = %v, want lease-1", store.gotArgs[0])
+	}
+
+	// 租约已到期被回收
+	store.result = int64(0)
+	released, err = limiter.Release("test", "lease-1")
+	if err != nil || released {
+		t.Errorf("Release() = %v, %v, want false", released, err)
+	}
+}
+
+func TestConcurrencyLimiter_InvalidResult(t *testing.T) {
+	store := &MockStoreWithResult{MockStore: *NewMockStore(), result: []interface{}{int64(1)}}
+	if _, err := NewConcurrencyLimiter(store).Acquire("test", 3, time.Minute); err == nil {
+		t.Error("返回格式错误时应该返回错误")
+	}
+}
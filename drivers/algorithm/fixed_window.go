@@ -1,58 +1,260 @@
 package algorithm
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"time"
 )
 
+// FixedWindowScript 固定窗口算法Lua脚本（检查、计数、设置过期和读取TTL在一次调用中原子完成）
+// KEYS[1]=key, ARGV=[limit, window(毫秒), cost]，返回 {allowed, count, ttl(毫秒), 窗口结束时间(Redis时间，Unix毫秒)}
+// 只有未超限的请求才计入窗口，被拒绝时count为当前计数；窗口结束时间用于标识本次计数所在的窗口
+const FixedWindowScript = `
+	local key = KEYS[1]
+	local limit = tonumber(ARGV[1])
+	local window = tonumber(ARGV[2])
+	local cost = tonumber(ARGV[3] or 1)
+
+	local count = tonumber(redis.call('GET', key) or 0)
+	local allowed = 0
+	if count + cost <= limit then
+		count = redis.call('INCRBY', key, cost)
+		if count == cost then
+			redis.call('PEXPIRE', key, window)
+		end
+		allowed = 1
+	end
+
+	local ttl = redis.call('PTTL', key)
+	if ttl < 0 then
+		-- 键没有过期时间（如旧版本遗留），重新设置，避免永久封锁
+		if ttl == -1 then
+			redis.call('PEXPIRE', key, window)
+		end
+		ttl = window
+	end
+
+	local now = redis.call('TIME')
+	local windowEnd = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000) + ttl
+	return {allowed, count, ttl, windowEnd}
+`
+
+// FixedWindowPeekScript 固定窗口只读Lua脚本（不计数）
+// KEYS[1]=key，返回 {count, ttl(毫秒)}，键不存在时ttl为负数
+const FixedWindowPeekScript = `
+	local count = tonumber(redis.call('GET', KEYS[1]) or 0)
+	local ttl = redis.call('PTTL', KEYS[1])
+	return {count, ttl}
+`
+
+// FixedWindowRefundScript 固定窗口退还配额Lua脚本
+// KEYS[1]=key, ARGV=[cost, 窗口结束时间(Unix毫秒), window(毫秒)]，返回实际退还的数量
+// 键的过期时间在窗口开始时设置后不再改变，窗口互不重叠，下一个窗口的结束时间至少晚一个窗口长度，
+// 因此按Redis时间算出的当前窗口结束时间与凭证相差不到半个窗口时即为同一窗口，否则不退还；
+// 退还到0时保留键和过期时间，不改变窗口边界
+const FixedWindowRefundScript = `
+	local key = KEYS[1]
+	local cost = tonumber(ARGV[1])
+	local windowEnd = tonumber(ARGV[2])
+	local window = tonumber(ARGV[3])
+
+	local ttl = redis.call('PTTL', key)
+	if ttl < 0 then
+		return 0
+	end
+	local now = redis.call('TIME')
+	local currentEnd = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000) + ttl
+	if math.abs(currentEnd - windowEnd) * 2 >= window then
+		return 0
+	end
+
+	local count = tonumber(redis.call('GET', key) or 0)
+	local refund = math.min(cost, count)
+	if refund <= 0 then
+		return 0
+	end
+	redis.call('DECRBY', key, refund)
+	return refund
+`
+
 // FixedWindowLimiter 固定窗口限流器
 type FixedWindowLimiter struct {
-	store Store
+	store ContextStore
 }
 
 // NewFixedWindowLimiter 创建固定窗口限流器
 func NewFixedWindowLimiter(store Store) *FixedWindowLimiter {
 	return &FixedWindowLimiter{
-		store: store,
+		store: toContextStore(store),
 	}
 }
 
 // Allow 检查是否允许请求
 func (l *FixedWindowLimiter) Allow(key string, limit int64, window time.Duration) (*Context, error) {
-	// 递增计数
-	count, err := l.store.Incr(key)
-	if err != nil {
-		return nil, fmt.Errorf("递增计数失败: %w", err)
+	return l.AllowContext(context.Background(), key, limit, window)
+}
+
+// AllowContext 检查是否允许请求（支持context）
+func (l *FixedWindowLimiter) AllowContext(ctx context.Context, key string, limit int64, window time.Duration) (*Context, error) {
+	return l.AllowNContext(ctx, key, limit, window, 1)
+}
+
+// AllowN 检查是否允许消耗n个配额的请求
+func (l *FixedWindowLimiter) AllowN(key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	return l.AllowNContext(context.Background(), key, limit, window, n)
+}
+
+// AllowNContext 检查是否允许消耗n个配额的请求（支持context）
+// 被拒绝的请求不计入窗口计数，超大的请求不会阻塞后续请求
+func (l *FixedWindowLimiter) AllowNContext(ctx context.Context, key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	if n < 1 {
+		n = 1
 	}
+	now := time.Now()
 
-	// 如果是第一次请求，设置过期时间
-	if count == 1 {
-		if err := l.store.Expire(key, window); err != nil {
-			return nil, fmt.Errorf("设置过期时间失败: %w", err)
-		}
+	// 执行Lua脚本
+	result, err := l.store.EvalContext(ctx, FixedWindowScript, []string{key}, limit, durationMillis(window), n)
+	if err != nil {
+		return nil, fmt.Errorf("执行Lua脚本失败: %w", err)
 	}
 
-	// 获取剩余时间
-	ttl, err := l.store.TTL(key)
+	// 解析结果
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, fmt.Errorf("Lua脚本返回格式错误")
+	}
+	allowedFlag, err := toInt64(values[0])
 	if err != nil {
-		return nil, fmt.Errorf("获取TTL失败: %w", err)
+		return nil, err
+	}
+	count, err := toInt64(values[1])
+	if err != nil {
+		return nil, err
+	}
+	ttlMillis, err := toInt64(values[2])
+	if err != nil {
+		return nil, err
+	}
+	windowEnd, err := toInt64(values[3])
+	if err != nil {
+		return nil, err
 	}
 
 	// 计算重置时间
-	reset := time.Now().Add(ttl).Unix()
+	ttl := time.Duration(ttlMillis) * time.Millisecond
+	reset := now.Add(ttl).Unix()
 
-	// 判断是否超过限制
-	allowed := count <= limit
+	allowed := allowedFlag == 1
 	remaining := limit - count
 	if remaining < 0 {
 		remaining = 0
 	}
 
-	return &Context{
+	ctxResult := &Context{
 		Allowed:    allowed,
 		Limit:      limit,
 		Remaining:  remaining,
 		Reset:      reset,
-		RetryAfter: int64(ttl.Seconds()),
-	}, nil
+		RetryAfter: ceilSeconds(ttl),
+	}
+	if allowed {
+		// 凭证记录Redis时间下的窗口结束时间，退还时用于确认仍是同一个窗口
+		ctxResult.RefundToken = strconv.FormatInt(windowEnd, 10)
+	}
+	return ctxResult, nil
+}
+
+// Refund 退还AllowN消耗的n个配额，token为AllowN返回的RefundToken
+// 返回是否退还成功，已进入新窗口时不退还
+func (l *FixedWindowLimiter) Refund(key string, window time.Duration, n int64, token string) (bool, error) {
+	return l.RefundContext(context.Background(), key, window, n, token)
+}
+
+// RefundContext 退还AllowN消耗的n个配额（支持context）
+func (l *FixedWindowLimiter) RefundContext(ctx context.Context, key string, window time.Duration, n int64, token string) (bool, error) {
+	if n < 1 {
+		n = 1
+	}
+	windowEnd, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("无效的退还凭证: %s", token)
+	}
+
+	result, err := l.store.EvalContext(ctx, FixedWindowRefundScript, []string{key}, n, windowEnd, durationMillis(window))
+	if err != nil {
+		return false, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+	refunded, err := toInt64(result)
+	if err != nil {
+		return false, err
+	}
+	return refunded > 0, nil
+}
+
+// Peek 检查请求是否会被允许（不消耗配额）
+func (l *FixedWindowLimiter) Peek(key string, limit int64, window time.Duration) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, limit, window, 1)
+}
+
+// PeekContext 检查请求是否会被允许（不消耗配额，支持context）
+func (l *FixedWindowLimiter) PeekContext(ctx context.Context, key string, limit int64, window time.Duration) (*Context, error) {
+	return l.PeekNContext(ctx, key, limit, window, 1)
+}
+
+// PeekN 检查消耗n个配额的请求是否会被允许（不消耗配额）
+func (l *FixedWindowLimiter) PeekN(key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, limit, window, n)
+}
+
+// PeekNContext 检查消耗n个配额的请求是否会被允许（不消耗配额，支持context）
+// 返回的Remaining为当前剩余配额
+func (l *FixedWindowLimiter) PeekNContext(ctx context.Context, key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	if n < 1 {
+		n = 1
+	}
+	now := time.Now()
+
+	// 执行Lua脚本
+	result, err := l.store.EvalContext(ctx, FixedWindowPeekScript, []string{key})
+	if err != nil {
+		return nil, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+
+	// 解析结果
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("Lua脚本返回格式错误")
+	}
+	count, err := toInt64(values[0])
+	if err != nil {
+		return nil, err
+	}
+	ttlMillis, err := toInt64(values[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// 窗口尚未开始时，下一个请求开始新的窗口
+	ttl := time.Duration(ttlMillis) * time.Millisecond
+	if ttlMillis < 0 {
+		ttl = window
+	}
+
+	allowed := count+n <= limit
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	ctxResult := &Context{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     now.Add(ttl).Unix(),
+	}
+	if !allowed {
+		ctxResult.RetryAfter = ceilSeconds(ttl)
+	}
+	return ctxResult, nil
 }
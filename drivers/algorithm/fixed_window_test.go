@@ -1,6 +1,8 @@
 package algorithm
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -66,8 +68,22 @@ func (m *MockStore) ZCount(key string, min, max float64) (int64, error) {
 	return 0, nil
 }
 
+// Eval 模拟固定窗口的Lua脚本
 func (m *MockStore) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
-	return nil, nil
+	if script != FixedWindowScript {
+		return nil, nil
+	}
+	// 返回格式: [allowed, count, ttl(毫秒)]
+	key := keys[0]
+	limit, cost := args[0].(int64), args[2].(int64)
+	if m.data[key]+cost > limit {
+		return []interface{}{int64(0), m.data[key], m.ttl[key].Milliseconds(), time.Now().Add(m.ttl[key]).UnixMilli()}, nil
+	}
+	m.data[key] += cost
+	if m.data[key] == cost {
+		m.ttl[key] = time.Duration(args[1].(int64)) * time.Millisecond
+	}
+	return []interface{}{int64(1), m.data[key], m.ttl[key].Milliseconds(), time.Now().Add(m.ttl[key]).UnixMilli()}, nil
 }
 
 // MockStoreWithEval 支持Eval的mock store（用于令牌桶测试）
@@ -266,3 +282,108 @@ func TestFixedWindowLimiter_Reset(t *testing.T) {
 		t.Error("RetryAfter应该大于0")
 	}
 }
+
+func TestFixedWindowLimiter_AllowContextCanceled(t *testing.T) {
+	store := NewMockStore()
+	limiter := NewFixedWindowLimiter(store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := limiter.AllowContext(ctx, "test:ctx", 3, time.Second)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("AllowContext() error = %v, want context.Canceled", err)
+	}
+	if store.data["test:ctx"] != 0 {
+		t.Error("context取消后不应修改计数")
+	}
+}
+
+func TestFixedWindowLimiter_Peek(t *testing.T) {
+	tests := []struct {
+		name          string
+		result        []interface{}
+		n             int64
+		wantAllowed   bool
+		wantRemaining int64
+		wantRetry     int64
+	}{
+		{"窗口未开始", []interface{}{int64(0), int64(-2)}, 1, true, 10, 0},
+		{"剩余配额足够", []interface{}{int64(7), int64(30000)}, 3, true, 3, 0},
+		{"剩余配额不足", []interface{}{int64(8), int64(30000)}, 3, false, 2, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MockStoreWithResult{MockStore: *NewMockStore(), result: tt.result}
+			limiter := NewFixedWindowLimiter(store)
+
+			result, err := limiter.PeekN("test", 10, time.Minute, tt.n)
+			if err != nil {
+				t.Fatalf("PeekN() error = %v", err)
+			}
+			if result.Allowed != tt.wantAllowed || result.Remaining != tt.wantRemaining || result.RetryAfter != tt.wantRetry {
+				t.Errorf("PeekN() = %+v, want allowed=%v remaining=%d retryAfter=%d",
+					result, tt.wantAllowed, tt.wantRemaining, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestFixedWindowLimiter_RefundToken(t *testing.T) {
+	store := &MockStoreWithResult{MockStore: *NewMockStore(), result: []interface{}{int64(1), int64(1), int64(30000), int64(1700000030000)}}
+	limiter := NewFixedWindowLimiter(store)
+
+	result, err := limiter.Allow("test", 10, time.Minute)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	// 凭证为脚本返回的窗口结束时间
+	if result.RefundToken != "1700000030000" {
+		t.Fatalf("RefundToken = %q, want 1700000030000", result.RefundToken)
+	}
+
+	if _, err := limiter.Refund("test", time.Minute, 1, "invalid"); err == nil {
+		t.Error("无效的凭证应返回错误")
+	}
+
+	store.result = int64(1)
+	if refunded, err := limiter.Refund("test", time.Minute, 1, result.RefundToken); err != nil || !refunded {
+		t.Errorf("Refund() = %v, %v, want true", refunded, err)
+	}
+	if end, _ := store.gotArgs[1].(int64); end != 1700000030000 {
+		t.Errorf("窗口结束时间参数 = %v, want 1700000030000", store.gotArgs[1])
+	}
+}
+
+func TestFixedWindowLimiter_DeniedCostNotCounted(t *testing.T) {
+	store := NewMockStore()
+	limiter := NewFixedWindowLimiter(store)
+
+	if _, err := limiter.AllowN("test:cost", 10, time.Minute, 3); err != nil {
+		t.Fatalf("AllowN() error = %v", err)
+	}
+
+	// 超大的请求被拒绝，不应占用窗口配额
+	result, err := limiter.AllowN("test:cost", 10, time.Minute, 100)
+	if err != nil {
+		t.Fatalf("AllowN() error = %v", err)
+	}
+	if result.Allowed {
+		t.Fatal("超出限制的请求应被拒绝")
+	}
+	if result.Remaining != 7 {
+		t.Errorf("Remaining = %d, want 7", result.Remaining)
+	}
+	if store.data["test:cost"] != 3 {
+		t.Errorf("count = %d, want 3", store.data["test:cost"])
+	}
+
+	result, err = limiter.AllowN("test:cost", 10, time.Minute, 7)
+	if err != nil {
+		t.Fatalf("AllowN() error = %v", err)
+	}
+	if !result.Allowed || result.Remaining != 0 {
+		t.Errorf("AllowN() = %+v, want allowed with remaining 0", result)
+	}
+}
@@ -0,0 +1,185 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// GCRAScript GCRA（通用信元速率算法）Lua脚本
+// 每个key只保存一个理论到达时间（TAT，微秒整数），请求到达时间早于 TAT-突发容忍度 时拒绝
+// KEYS[1]=key, ARGV=[interval(微秒), burst, now(微秒), cost]，返回 {allowed, tat}
+// 微秒时间戳超出Lua数值转字符串的精度，因此写入时使用 %d 格式化
+const GCRAScript = `
+	local key = KEYS[1]
+	local interval = tonumber(ARGV[1])
+	local burst = tonumber(ARGV[2])
+	local now = tonumber(ARGV[3])
+	local cost = tonumber(ARGV[4] or 1)
+
+	local tat = tonumber(redis.call('GET', key) or now)
+	if tat < now then
+		tat = now
+	end
+
+	-- 本次请求放行后的TAT（每个配额推进一个间隔），最多比当前时间超前 burst 个间隔
+	local new_tat = tat + interval * cost
+	if new_tat - burst * interval > now then
+		return {0, tat}
+	end
+
+	redis.call('SET', key, string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+	return {1, new_tat}
+`
+
+// GCRAPeekScript GCRA只读Lua脚本（不推进TAT）
+// 参数和返回值与 GCRAScript 相同，tat为当前的理论到达时间
+const GCRAPeekScript = `
+	local interval = tonumber(ARGV[1])
+	local burst = tonumber(ARGV[2])
+	local now = tonumber(ARGV[3])
+	local cost = tonumber(ARGV[4] or 1)
+
+	local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+	if tat < now then
+		tat = now
+	end
+
+	if tat + interval * cost - burst * interval > now then
+		return {0, tat}
+	end
+	return {1, tat}
+`
+
+// GCRALimiter GCRA限流器
+// 与令牌桶等价（burst为桶容量，rate为每秒补充速率），但只存储一个值，并以毫秒精度计算重试和重置时间
+type GCRALimiter struct {
+	store ContextStore
+}
+
+// NewGCRALimiter 创建GCRA限流器
+func NewGCRALimiter(store Store) *GCRALimiter {
+	return &GCRALimiter{
+		store: toContextStore(store),
+	}
+}
+
+// Allow 检查是否允许请求
+func (l *GCRALimiter) Allow(key string, burst int64, rate float64) (*Context, error) {
+	return l.AllowContext(context.Background(), key, burst, rate)
+}
+
+// AllowContext 检查是否允许请求（支持context）
+func (l *GCRALimiter) AllowContext(ctx context.Context, key string, burst int64, rate float64) (*Context, error) {
+	return l.AllowNContext(ctx, key, burst, rate, 1)
+}
+
+// AllowN 检查是否允许消耗n个配额的请求
+func (l *GCRALimiter) AllowN(key string, burst int64, rate float64, n int64) (*Context, error) {
+	return l.AllowNContext(context.Background(), key, burst, rate, n)
+}
+
+// AllowNContext 检查是否允许消耗n个配额的请求（支持context）
+func (l *GCRALimiter) AllowNContext(ctx context.Context, key string, burst int64, rate float64, n int64) (*Context, error) {
+	return l.eval(ctx, GCRAScript, key, burst, rate, n)
+}
+
+// Peek 检查请求是否会被允许（不消耗配额）
+func (l *GCRALimiter) Peek(key string, burst int64, rate float64) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, burst, rate, 1)
+}
+
+// PeekContext 检查请求是否会被允许（不消耗配额，支持context）
+func (l *GCRALimiter) PeekContext(ctx context.Context, key string, burst int64, rate float64) (*Context, error) {
+	return l.PeekNContext(ctx, key, burst, rate, 1)
+}
+
+// PeekN 检查消耗n个配额的请求是否会被允许（不消耗配额）
+func (l *GCRALimiter) PeekN(key string, burst int64, rate float64, n int64) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, burst, rate, n)
+}
+
+// PeekNContext 检查消耗n个配额的请求是否会被允许（不消耗配额，支持context）
+func (l *GCRALimiter) PeekNContext(ctx context.Context, key string, burst int64, rate float64, n int64) (*Context, error) {
+	return l.eval(ctx, GCRAPeekScript, key, burst, rate, n)
+}
+
+// eval 执行GCRA脚本并解析结果
+func (l *GCRALimiter) eval(ctx context.Context, script, key string, burst int64, rate float64, n int64) (*Context, error) {
+	if n < 1 {
+		n = 1
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("GCRA速率必须大于0")
+	}
+	if burst <= 0 {
+		return nil, fmt.Errorf("GCRA突发容量必须大于0")
+	}
+
+	now := time.Now().UnixMicro()
+	interval := int64(math.Round(1e6 / rate))
+	if interval < 1 {
+		interval = 1
+	}
+
+	// 执行Lua脚本
+	result, err := l.store.EvalContext(ctx, script, []string{key}, interval, burst, now, n)
+	if err != nil {
+		return nil, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+
+	// 解析结果
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("Lua脚本返回格式错误")
+	}
+	allowedFlag, err := toInt64(values[0])
+	if err != nil {
+		return nil, err
+	}
+	tat, err := toInt64(values[1])
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := allowedFlag == 1
+
+	// 剩余配额 = 当前时间距离允许的最大TAT还能容纳的间隔数
+	remaining := (now + burst*interval - tat) / interval
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	// TAT之后桶完全恢复
+	resetMillis := ceilDiv(tat, 1000)
+	ctxResult := &Context{
+		Allowed:     allowed,
+		Limit:       burst,
+		Remaining:   remaining,
+		Reset:       ceilDiv(tat, 1e6),
+		ResetMillis: resetMillis,
+	}
+	if !allowed {
+		// 本次请求在 TAT+n*interval-burst*interval 时才能放行
+		wait := tat + n*interval - burst*interval - now
+		ctxResult.RetryAfterMillis = ceilDiv(wait, 1000)
+		if ctxResult.RetryAfterMillis < 1 {
+			ctxResult.RetryAfterMillis = 1
+		}
+		ctxResult.RetryAfter = ceilDiv(wait, 1e6)
+		if ctxResult.RetryAfter < 1 {
+			ctxResult.RetryAfter = 1
+		}
+	}
+	return ctxResult, nil
+}
+
+// ceilDiv 向上取整的整数除法（b>0）
+func ceilDiv(a, b int64) int64 {
+	q := a / b
+	if a%b > 0 {
+		q++
+	}
+	return q
+}
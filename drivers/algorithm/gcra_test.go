@@ -0,0 +1,96 @@
+package algorithm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGCRALimiter_Allow(t *testing.T) {
+	store := &MockStoreWithResult{MockStore: *NewMockStore()}
+	limiter := NewGCRALimiter(store)
+
+	// 速率每秒50个（间隔20毫秒），突发容量5
+	now := time.Now().UnixMicro()
+
+	// 放行后TAT比当前时间超前2个间隔，还能容纳3个请求
+	store.result = []interface{}{int64(1), now + 40000}
+	result, err := limiter.Allow("test", 5, 50)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if !result.Allowed || result.Remaining < 2 || result.Remaining > 3 || result.Limit != 5 {
+		t.Errorf("Allow() = %+v", result)
+	}
+	if interval, _ := store.gotArgs[0].(int64); interval != 20000 {
+		t.Errorf("interval = %v, want 20000", store.gotArgs[0])
+	}
+	if result.ResetMillis < (now+40000)/1000 || result.ResetMillis > (now+40000)/1000+1 {
+		t.Errorf("ResetMillis = %d, want ~%d", result.ResetMillis, (now+40000)/1000)
+	}
+
+	// 拒绝时TAT比当前时间超前5个间隔，需要等待约1个间隔
+	store.result = []interface{}{int64(0), now + 100000}
+	result, err = limiter.Allow("test", 5, 50)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed || result.Remaining != 0 {
+		t.Errorf("Allow() = %+v", result)
+	}
+	if result.RetryAfterMillis < 1 || result.RetryAfterMillis > 20 {
+		t.Errorf("RetryAfterMillis = %d, want (0, 20]", result.RetryAfterMillis)
+	}
+	if result.RetryAfter != 1 {
+		t.Errorf("RetryAfter = %d, want 1", result.RetryAfter)
+	}
+}
+
+func TestGCRALimiter_InvalidArguments(t *testing.T) {
+	limiter := NewGCRALimiter(NewMockStore())
+	if _, err := limiter.Allow("test", 5, 0); err == nil {
+		t.Error("速率为0应该返回错误")
+	}
+	if _, err := limiter.Allow("test", 0, 1); err == nil {
+		t.Error("突发容量为0应该返回错误")
+	}
+
+	store := &MockStoreWithResult{MockStore: *NewMockStore(), result: []interface{}{int64(1)}}
+	if _, err := NewGCRALimiter(store).Allow("test", 5, 1); err == nil {
+		t.Error("返回格式错误时应该返回错误")
+	}
+}
+
+func TestCeilDiv(t *testing.T) {
+	tests := []struct{ a, b, want int64 }{
+		{0, 1000, 0},
+		{1, 1000, 1},
+		{1000, 1000, 1},
+		{1001, 1000, 2},
+		{-500, 1000, 0},
+	}
+	for _, tt := range tests {
+		if got := ceilDiv(tt.a, tt.b); got != tt.want {
+			t.Errorf("ceilDiv(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestGCRALimiter_AllowN(t *testing.T) {
+	store := &MockStoreWithResult{MockStore: *NewMockStore()}
+	limiter := NewGCRALimiter(store)
+
+	// 速率每秒50个（间隔20毫秒），突发容量5，当前TAT超前2个间隔
+	now := time.Now().UnixMicro()
+	store.result = []interface{}{int64(0), now + 40000}
+	result, err := limiter.AllowN("test", 5, 50, 4)
+	if err != nil {
+		t.Fatalf("AllowN() error = %v", err)
+	}
+	if cost, _ := store.gotArgs[3].(int64); cost != 4 {
+		t.Errorf("cost = %v, want 4", store.gotArgs[3])
+	}
+	// 需要 TAT+4*20ms-5*20ms <= now，即等待约1个间隔
+	if result.Allowed || result.RetryAfterMillis < 1 || result.RetryAfterMillis > 20 {
+		t.Errorf("AllowN() = %+v", result)
+	}
+}
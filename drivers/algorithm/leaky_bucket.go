@@ -0,0 +1,326 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// LeakyBucketScript 漏桶算法Lua脚本（计量模式，桶满时拒绝）
+// KEYS[1]=key, ARGV=[capacity, rate(每秒), now(毫秒), requested]
+// 返回 {allowed, level}，level为本次请求后桶内水量（浮点数以字符串返回）
+const LeakyBucketScript = `
+	local key = KEYS[1]
+	local capacity = tonumber(ARGV[1])
+	local rate = tonumber(ARGV[2])
+	local now = tonumber(ARGV[3])
+	local requested = tonumber(ARGV[4])
+
+	-- 按流出速率扣减自上次请求以来漏出的水量
+	local level = tonumber(redis.call('HGET', key, 'level') or 0)
+	local last_time = tonumber(redis.call('HGET', key, 'last_time') or now)
+	local leaked = math.max(0, now - last_time) * rate / 1000
+	level = math.max(0, level - leaked)
+
+	local allowed = 0
+	if level + requested <= capacity then
+		level = level + requested
+		allowed = 1
+	end
+
+	redis.call('HSET', key, 'level', tostring(level), 'last_time', ARGV[3])
+	-- 桶漏空后记录没有意义
+	redis.call('PEXPIRE', key, math.ceil(level / rate * 1000) + 1000)
+
+	return {allowed, tostring(level)}
+`
+
+// LeakyBucketQueueScript 漏桶算法Lua脚本（队列模式，请求按固定间隔排队流出）
+// KEYS[1]=key, ARGV=[capacity, interval(毫秒), now(毫秒), cost]，消耗cost个配额的请求在队列中占用cost个间隔
+// 返回 {allowed, delay}，delay为请求需要等待的毫秒数（浮点数以字符串返回）
+const LeakyBucketQueueScript = `
+	local key = KEYS[1]
+	local capacity = tonumber(ARGV[1])
+	local interval = tonumber(ARGV[2])
+	local now = tonumber(ARGV[3])
+	local cost = tonumber(ARGV[4] or 1)
+
+	-- tat为队列中最后一个请求流出后的时间
+	local tat = tonumber(redis.call('HGET', key, 'tat') or now)
+	local start = math.max(tat, now)
+	local delay = start - now
+
+	-- 排在前面的请求数加上本次请求超过容量时拒绝
+	local allowed = 0
+	if delay + (cost - 1) * interval < capacity * interval then
+		tat = start + interval * cost
+		redis.call('HSET', key, 'tat', tostring(tat))
+		redis.call('PEXPIRE', key, math.ceil(tat - now) + 1000)
+		allowed = 1
+	end
+
+	return {allowed, tostring(delay)}
+`
+
+// LeakyBucketPeekScript 漏桶只读Lua脚本（计量模式，不写入）
+// 参数和返回值与 LeakyBucketScript 相同，level为当前桶内水量
+const LeakyBucketPeekScript = `
+	local key = KEYS[1]
+	local capacity = tonumber(ARGV[1])
+	local rate = tonumber(ARGV[2])
+	local now = tonumber(ARGV[3])
+	local requested = tonumber(ARGV[4])
+
+	local level = tonumber(redis.call('HGET', key, 'level') or 0)
+	local last_time = tonumber(redis.call('HGET', key, 'last_time') or now)
+	level = math.max(0, level - math.max(0, now - last_time) * rate / 1000)
+
+	local allowed = 0
+	if level + requested <= capacity then
+		allowed = 1
+	end
+
+	return {allowed, tostring(level)}
+`
+
+// LeakyBucketQueuePeekScript 漏桶只读Lua脚本（队列模式，不入队）
+// 参数和返回值与 LeakyBucketQueueScript 相同
+const LeakyBucketQueuePeekScript = `
+	local capacity = tonumber(ARGV[1])
+	local interval = tonumber(ARGV[2])
+	local now = tonumber(ARGV[3])
+	local cost = tonumber(ARGV[4] or 1)
+
+	local tat = tonumber(redis.call('HGET', KEYS[1], 'tat') or now)
+	local delay = math.max(tat, now) - now
+
+	local allowed = 0
+	if delay + (cost - 1) * interval < capacity * interval then
+		allowed = 1
+	end
+
+	return {allowed, tostring(delay)}
+`
+
+// LeakyBucketLimiter 漏桶限流器
+// 计量模式（Allow）桶满时拒绝，队列模式（Queue）返回请求需要等待的时间，使请求以恒定速率流出
+type LeakyBucketLimiter struct {
+	store ContextStore
+}
+
+// NewLeakyBucketLimiter 创建漏桶限流器
+func NewLeakyBucketLimiter(store Store) *LeakyBucketLimiter {
+	return &LeakyBucketLimiter{
+		store: toContextStore(store),
+	}
+}
+
+// Allow 检查是否允许请求（计量模式）
+func (l *LeakyBucketLimiter) Allow(key string, capacity int64, rate float64) (*Context, error) {
+	return l.AllowContext(context.Background(), key, capacity, rate)
+}
+
+// AllowContext 检查是否允许请求（计量模式，支持context）
+func (l *LeakyBucketLimiter) AllowContext(ctx context.Context, key string, capacity int64, rate float64) (*Context, error) {
+	return l.AllowNContext(ctx, key, capacity, rate, 1)
+}
+
+// AllowN 检查是否允许消耗n个配额的请求（计量模式）
+func (l *LeakyBucketLimiter) AllowN(key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.AllowNContext(context.Background(), key, capacity, rate, n)
+}
+
+// AllowNContext 检查是否允许消耗n个配额的请求（计量模式，支持context）
+func (l *LeakyBucketLimiter) AllowNContext(ctx context.Context, key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.meter(ctx, LeakyBucketScript, key, capacity, rate, n)
+}
+
+// Peek 检查请求是否会被允许（计量模式，不消耗配额）
+func (l *LeakyBucketLimiter) Peek(key string, capacity int64, rate float64) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, capacity, rate, 1)
+}
+
+// PeekContext 检查请求是否会被允许（计量模式，不消耗配额，支持context）
+func (l *LeakyBucketLimiter) PeekContext(ctx context.Context, key string, capacity int64, rate float64) (*Context, error) {
+	return l.PeekNContext(ctx, key, capacity, rate, 1)
+}
+
+// PeekN 检查消耗n个配额的请求是否会被允许（计量模式，不消耗配额）
+func (l *LeakyBucketLimiter) PeekN(key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, capacity, rate, n)
+}
+
+// PeekNContext 检查消耗n个配额的请求是否会被允许（计量模式，不消耗配额，支持context）
+func (l *LeakyBucketLimiter) PeekNContext(ctx context.Context, key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.meter(ctx, LeakyBucketPeekScript, key, capacity, rate, n)
+}
+
+// meter 执行计量模式脚本并解析结果
+func (l *LeakyBucketLimiter) meter(ctx context.Context, script, key string, capacity int64, rate float64, n int64) (*Context, error) {
+	if n < 1 {
+		n = 1
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("漏桶流出速率必须大于0")
+	}
+	now := time.Now()
+
+	// 执行Lua脚本
+	result, err := l.store.EvalContext(ctx, script, []string{key}, capacity, rate, now.UnixMilli(), n)
+	if err != nil {
+		return nil, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+
+	// 解析结果
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("Lua脚本返回格式错误")
+	}
+	allowedFlag, err := toInt64(values[0])
+	if err != nil {
+		return nil, err
+	}
+	level, err := toFloat64(values[1])
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := allowedFlag == 1
+	remaining := int64(math.Floor(float64(capacity) - level))
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	// 桶内水量漏出到可以容纳本次请求时才能重试
+	var retryAfter int64
+	if !allowed {
+		retryAfter = ceilSeconds(rateDuration(level+float64(n)-float64(capacity), rate))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+	}
+
+	return &Context{
+		Allowed:    allowed,
+		Limit:      capacity,
+		Remaining:  remaining,
+		Reset:      now.Add(rateDuration(level, rate)).Unix(),
+		RetryAfter: retryAfter,
+	}, nil
+}
+
+// Queue 将请求加入队列（队列模式），允许时返回的Delay为请求需要等待的时间
+func (l *LeakyBucketLimiter) Queue(key string, capacity int64, rate float64) (*Context, error) {
+	return l.QueueContext(context.Background(), key, capacity, rate)
+}
+
+// QueueContext 将请求加入队列（队列模式，支持context）
+// capacity为队列中最多容纳的请求数，请求以rate的速率依次流出
+func (l *LeakyBucketLimiter) QueueContext(ctx context.Context, key string, capacity int64, rate float64) (*Context, error) {
+	return l.QueueNContext(ctx, key, capacity, rate, 1)
+}
+
+// QueueN 将消耗n个配额的请求加入队列（队列模式）
+func (l *LeakyBucketLimiter) QueueN(key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.QueueNContext(context.Background(), key, capacity, rate, n)
+}
+
+// QueueNContext 将消耗n个配额的请求加入队列（队列模式，支持context）
+// 请求在队列中占用n个位置，流出时间为n个间隔
+func (l *LeakyBucketLimiter) QueueNContext(ctx context.Context, key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.queue(ctx, LeakyBucketQueueScript, key, capacity, rate, n, true)
+}
+
+// PeekQueue 检查请求是否可以入队（队列模式，不入队），允许时Delay为入队后需要等待的时间
+func (l *LeakyBucketLimiter) PeekQueue(key string, capacity int64, rate float64) (*Context, error) {
+	return l.PeekQueueNContext(context.Background(), key, capacity, rate, 1)
+}
+
+// PeekQueueContext 检查请求是否可以入队（队列模式，不入队，支持context）
+func (l *LeakyBucketLimiter) PeekQueueContext(ctx context.Context, key string, capacity int64, rate float64) (*Context, error) {
+	return l.PeekQueueNContext(ctx, key, capacity, rate, 1)
+}
+
+// PeekQueueN 检查消耗n个配额的请求是否可以入队（队列模式，不入队）
+func (l *LeakyBucketLimiter) PeekQueueN(key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.PeekQueueNContext(context.Background(), key, capacity, rate, n)
+}
+
+// PeekQueueNContext 检查消耗n个配额的请求是否可以入队（队列模式，不入队，支持context）
+// 返回的Remaining为当前队列的剩余容量
+func (l *LeakyBucketLimiter) PeekQueueNContext(ctx context.Context, key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.queue(ctx, LeakyBucketQueuePeekScript, key, capacity, rate, n, false)
+}
+
+// queue 执行队列模式脚本并解析结果，enqueue表示允许时请求是否已入队
+func (l *LeakyBucketLimiter) queue(ctx context.Context, script, key string, capacity int64, rate float64, n int64, enqueue bool) (*Context, error) {
+	if n < 1 {
+		n = 1
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("漏桶流出速率必须大于0")
+	}
+	now := time.Now()
+	interval := 1000 / rate
+
+	// 执行Lua脚本
+	result, err := l.store.EvalContext(ctx, script, []string{key}, capacity, interval, now.UnixMilli(), n)
+	if err != nil {
+		return nil, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+
+	// 解析结果
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("Lua脚本返回格式错误")
+	}
+	allowedFlag, err := toInt64(values[0])
+	if err != nil {
+		return nil, err
+	}
+	delayMillis, err := toFloat64(values[1])
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := allowedFlag == 1
+	delay := time.Duration(delayMillis * float64(time.Millisecond))
+
+	// 队列中的请求数（已入队时含本次请求）
+	backlog := delayMillis
+	if allowed && enqueue {
+		backlog += interval * float64(n)
+	}
+	queued := int64(math.Ceil(backlog/interval - 1e-9))
+	remaining := capacity - queued
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	ctxResult := &Context{
+		Allowed:   allowed,
+		Limit:     capacity,
+		Remaining: remaining,
+		Reset:     now.Add(time.Duration(backlog * float64(time.Millisecond))).Unix(),
+	}
+	if allowed {
+		ctxResult.Delay = delay
+	} else {
+		// 队首的请求流出到可以容纳本次请求时才有空位
+		wait := delayMillis + float64(n-1)*interval - float64(capacity)*interval
+		ctxResult.RetryAfter = ceilSeconds(time.Duration(wait * float64(time.Millisecond)))
+		if ctxResult.RetryAfter < 1 {
+			ctxResult.RetryAfter = 1
+		}
+	}
+	return ctxResult, nil
+}
+
+// rateDuration 按每秒速率计算流出指定数量所需的时间
+func rateDuration(amount, rate float64) time.Duration {
+	if amount <= 0 {
+		return 0
+	}
+	return time.Duration(amount / rate * float64(time.Second))
+}
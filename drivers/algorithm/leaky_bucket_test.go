@@ -0,0 +1,109 @@
+package algorithm
+
+import (
+	"testing"
+	"time"
+)
+
+// MockStoreWithResult 返回固定脚本结果的模拟存储（用于验证结果解析）
+type MockStoreWithResult struct {
+	MockStore
+	result  interface{}
+	gotArgs []interface{}
+}
+
+func (m *MockStoreWithResult) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	m.gotArgs = args
+	return m.result, nil
+}
+
+func TestLeakyBucketLimiter_Allow(t *testing.T) {
+	tests := []struct {
+		name          string
+		result        []interface{}
+		wantAllowed   bool
+		wantRemaining int64
+		wantRetry     int64
+	}{
+		{"桶未满", []interface{}{int64(1), "3"}, true, 7, 0},
+		{"水量为小数", []interface{}{int64(1), "2.5"}, true, 7, 0},
+		{"桶满拒绝", []interface{}{int64(0), "10"}, false, 0, 1},
+		// 速率为每秒2个，水量需要漏出3个才能容纳请求
+		{"桶满重试时间", []interface{}{int64(0), "12"}, false, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MockStoreWithResult{MockStore: *NewMockStore(), result: tt.result}
+			limiter := NewLeakyBucketLimiter(store)
+
+			result, err := limiter.Allow("test", 10, 2)
+			if err != nil {
+				t.Fatalf("Allow() error = %v", err)
+			}
+			if result.Allowed != tt.wantAllowed || result.Remaining != tt.wantRemaining || result.RetryAfter != tt.wantRetry {
+				t.Errorf("Allow() = %+v, want allowed=%v remaining=%d retryAfter=%d",
+					result, tt.wantAllowed, tt.wantRemaining, tt.wantRetry)
+			}
+			if result.Limit != 10 || result.Delay != 0 {
+				t.Errorf("Allow() = %+v", result)
+			}
+		})
+	}
+}
+
+func TestLeakyBucketLimiter_Queue(t *testing.T) {
+	// 速率为每秒10个，间隔100毫秒
+	tests := []struct {
+		name          string
+		result        []interface{}
+		wantAllowed   bool
+		wantDelay     time.Duration
+		wantRemaining int64
+		wantRetry     int64
+	}{
+		{"队列为空立即放行", []interface{}{int64(1), "0"}, true, 0, 4, 0},
+		{"排队等待", []interface{}{int64(1), "250"}, true, 250 * time.Millisecond, 1, 0},
+		{"队列已满", []interface{}{int64(0), "500"}, false, 0, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MockStoreWithResult{MockStore: *NewMockStore(), result: tt.result}
+			limiter := NewLeakyBucketLimiter(store)
+
+			result, err := limiter.Queue("test", 5, 10)
+			if err != nil {
+				t.Fatalf("Queue() error = %v", err)
+			}
+			if result.Allowed != tt.wantAllowed || result.Delay != tt.wantDelay ||
+				result.Remaining != tt.wantRemaining || result.RetryAfter != tt.wantRetry {
+				t.Errorf("Queue() = %+v", result)
+			}
+			if interval, _ := store.gotArgs[1].(float64); interval != 100 {
+				t.Errorf("interval = %v, want 100", store.gotArgs[1])
+			}
+		})
+	}
+}
+
+func TestLeakyBucketLimiter_InvalidRate(t *testing.T) {
+	limiter := NewLeakyBucketLimiter(NewMockStore())
+	if _, err := limiter.Allow("test", 10, 0); err == nil {
+		t.Error("速率为0应该返回错误")
+	}
+	if _, err := limiter.Queue("test", 10, 0); err == nil {
+		t.Error("速率为0应该返回错误")
+	}
+}
+
+func TestLeakyBucketLimiter_InvalidResult(t *testing.T) {
+	store := &MockStoreWithResult{MockStore: *NewMockStore(), result: []interface{}{int64(1)}}
+	limiter := NewLeakyBucketLimiter(store)
+	if _, err := limiter.Allow("test", 10, 1); err == nil {
+		t.Error("返回格式错误时应该返回错误")
+	}
+	if _, err := limiter.Queue("test", 10, 1); err == nil {
+		t.Error("返回格式错误时应该返回错误")
+	}
+}
@@ -1,74 +1,295 @@
 package algorithm
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
+	"strconv"
 	"time"
 )
 
+// SlidingWindowScript 滑动窗口算法Lua脚本（清理、计数和记录在一次调用中原子完成）
+// KEYS=[记录有序集合key, 配额总量key], ARGV=[limit, now(纳秒), windowStart(纳秒), member, window(毫秒), cost]
+// 每个请求对应一个成员，成员名以 ":cost" 结尾，窗口内的配额总量单独计数，避免按成员数统计
+// 返回 {allowed, count, oldest}，oldest为窗口内最早请求的分数（窗口为空时为false）
+// 纳秒时间戳超出Lua数值转字符串的精度，因此分数直接使用参数原文而不在脚本内计算
+const SlidingWindowScript = `
+	local key = KEYS[1]
+	local total_key = KEYS[2]
+	local limit = tonumber(ARGV[1])
+	local now = ARGV[2]
+	local window_start = ARGV[3]
+	local member = ARGV[4]
+	local window = tonumber(ARGV[5])
+	local cost = tonumber(ARGV[6] or 1)
+
+	-- 删除窗口之外的记录，并从总量中扣除它们的配额
+	local count = 0
+	if redis.call('ZCARD', key) > 0 then
+		count = tonumber(redis.call('GET', total_key) or 0)
+		local expired = redis.call('ZRANGEBYSCORE', key, 0, window_start)
+		if #expired > 0 then
+			for _, m in ipairs(expired) do
+				count = count - tonumber(string.match(m, ':(%d+)$') or 1)
+			end
+			redis.call('ZREMRANGEBYSCORE', key, 0, window_start)
+		end
+		if count < 0 or redis.call('ZCARD', key) == 0 then
+			count = 0
+		end
+	end
+
+	-- 未超限时记录当前请求
+	local allowed = 0
+	if count + cost <= limit then
+		redis.call('ZADD', key, now, member)
+		count = count + cost
+		allowed = 1
+	end
+
+	-- 窗口外的记录没有意义，过期时间与窗口一致即可
+	if count > 0 then
+		redis.call('SET', total_key, count, 'PX', window)
+		redis.call('PEXPIRE', key, window)
+	else
+		redis.call('DEL', total_key)
+	end
+
+	local oldest = false
+	local first = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+	if #first == 2 then
+		oldest = first[2]
+	end
+
+	return {allowed, count, oldest}
+`
+
+// SlidingWindowPeekScript 滑动窗口只读Lua脚本（不清理、不记录）
+// KEYS=[记录有序集合key, 配额总量key], ARGV=[windowStart(纳秒), limit, cost]
+// 返回 {allowed, count, score}：允许时score为窗口内最早请求的分数；
+// 拒绝时为需要滑出窗口才能容纳本次请求的那条记录的分数（无法容纳时为false）
+const SlidingWindowPeekScript = `
+	local key = KEYS[1]
+	local total_key = KEYS[2]
+	local window_start = ARGV[1]
+	local limit = tonumber(ARGV[2])
+	local cost = tonumber(ARGV[3])
+
+	-- 总量中仍包含尚未清理的窗口外记录，需要扣除
+	local count = 0
+	if redis.call('ZCARD', key) > 0 then
+		count = tonumber(redis.call('GET', total_key) or 0)
+		local expired = redis.call('ZRANGEBYSCORE', key, 0, window_start)
+		for _, m in ipairs(expired) do
+			count = count - tonumber(string.match(m, ':(%d+)$') or 1)
+		end
+		if count < 0 then
+			count = 0
+		end
+	end
+
+	local allowed = 0
+	local need = 0
+	if count + cost <= limit then
+		allowed = 1
+	else
+		need = count + cost - limit
+	end
+
+	-- 按时间顺序累计滑出窗口释放的配额，找到足以容纳本次请求的那条记录
+	local score = false
+	local entries = redis.call('ZRANGEBYSCORE', key, '(' .. window_start, '+inf', 'WITHSCORES')
+	local freed = 0
+	for i = 1, #entries, 2 do
+		freed = freed + tonumber(string.match(entries[i], ':(%d+)$') or 1)
+		if freed >= need then
+			score = entries[i + 1]
+			break
+		end
+	end
+
+	return {allowed, count, score}
+`
+
+// SlidingWindowRefundScript 滑动窗口退还配额Lua脚本（删除消耗时记录的成员）
+// KEYS=[记录有序集合key, 配额总量key], ARGV=[member]，返回实际退还的配额数
+const SlidingWindowRefundScript = `
+	local key = KEYS[1]
+	local total_key = KEYS[2]
+	local member = ARGV[1]
+
+	if redis.call('ZREM', key, member) == 0 then
+		return 0
+	end
+	local cost = tonumber(string.match(member, ':(%d+)$') or 1)
+	if redis.call('DECRBY', total_key, cost) <= 0 then
+		redis.call('DEL', total_key)
+	end
+	return cost
+`
+
 // SlidingWindowLimiter 滑动窗口限流器
 type SlidingWindowLimiter struct {
-	store Store
+	store ContextStore
 }
 
 // NewSlidingWindowLimiter 创建滑动窗口限流器
 func NewSlidingWindowLimiter(store Store) *SlidingWindowLimiter {
 	return &SlidingWindowLimiter{
-		store: store,
+		store: toContextStore(store),
 	}
 }
 
 // Allow 检查是否允许请求
 func (l *SlidingWindowLimiter) Allow(key string, limit int64, window time.Duration) (*Context, error) {
+	return l.AllowContext(context.Background(), key, limit, window)
+}
+
+// AllowContext 检查是否允许请求（支持context）
+func (l *SlidingWindowLimiter) AllowContext(ctx context.Context, key string, limit int64, window time.Duration) (*Context, error) {
+	return l.AllowNContext(ctx, key, limit, window, 1)
+}
+
+// AllowN 检查是否允许消耗n个配额的请求
+func (l *SlidingWindowLimiter) AllowN(key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	return l.AllowNContext(context.Background(), key, limit, window, n)
+}
+
+// AllowNContext 检查是否允许消耗n个配额的请求（支持context）
+// 每个请求在窗口内只记录一条成员，脚本耗时与n无关
+func (l *SlidingWindowLimiter) AllowNContext(ctx context.Context, key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	if n < 1 {
+		n = 1
+	}
 	now := time.Now()
-	windowStart := now.Add(-window)
 
-	// 使用时间戳作为分数和成员
-	score := float64(now.UnixNano())
-	member := fmt.Sprintf("%d", now.UnixNano())
+	// 使用时间戳作为分数，成员附加随机后缀避免多实例同一纳秒内冲突，末尾记录消耗的配额
+	member := strconv.FormatInt(now.UnixNano(), 10) + "-" + strconv.FormatUint(rand.Uint64(), 36) +
+		":" + strconv.FormatInt(n, 10)
+
+	// 执行Lua脚本
+	result, err := l.store.EvalContext(ctx, SlidingWindowScript, slidingWindowKeys(key),
+		limit, now.UnixNano(), now.Add(-window).UnixNano(), member, durationMillis(window), n)
+	if err != nil {
+		return nil, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+	ctxResult, err := parseSlidingWindow(result, now, limit, window)
+	if err != nil {
+		return nil, err
+	}
+	if ctxResult.Allowed {
+		ctxResult.RefundToken = member
+	}
+	return ctxResult, nil
+}
+
+// Refund 退还AllowN消耗的n个配额，token为AllowN返回的RefundToken
+// 返回是否退还成功，记录已滑出窗口时不退还；退还的数量以凭证中记录的为准
+func (l *SlidingWindowLimiter) Refund(key string, n int64, token string) (bool, error) {
+	return l.RefundContext(context.Background(), key, n, token)
+}
 
-	// 删除窗口之外的记录
-	minScore := float64(0)
-	maxScore := float64(windowStart.UnixNano())
-	if err := l.store.ZRemRangeByScore(key, minScore, maxScore); err != nil {
-		return nil, fmt.Errorf("删除过期记录失败: %w", err)
+// RefundContext 退还AllowN消耗的n个配额（支持context）
+func (l *SlidingWindowLimiter) RefundContext(ctx context.Context, key string, n int64, token string) (bool, error) {
+	if token == "" {
+		return false, fmt.Errorf("无效的退还凭证: %s", token)
 	}
 
-	// 统计当前窗口内的请求数
-	count, err := l.store.ZCount(key, float64(windowStart.UnixNano()), float64(now.UnixNano())*2)
+	result, err := l.store.EvalContext(ctx, SlidingWindowRefundScript, slidingWindowKeys(key), token)
+	if err != nil {
+		return false, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+	removed, err := toInt64(result)
 	if err != nil {
-		return nil, fmt.Errorf("统计请求数失败: %w", err)
+		return false, err
 	}
+	return removed > 0, nil
+}
 
-	// 判断是否允许
-	allowed := count < limit
+// Peek 检查请求是否会被允许（不消耗配额）
+func (l *SlidingWindowLimiter) Peek(key string, limit int64, window time.Duration) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, limit, window, 1)
+}
 
-	// 如果允许，添加当前请求
-	if allowed {
-		if err := l.store.ZAdd(key, score, member); err != nil {
-			return nil, fmt.Errorf("添加请求记录失败: %w", err)
-		}
-		count++
+// PeekContext 检查请求是否会被允许（不消耗配额，支持context）
+func (l *SlidingWindowLimiter) PeekContext(ctx context.Context, key string, limit int64, window time.Duration) (*Context, error) {
+	return l.PeekNContext(ctx, key, limit, window, 1)
+}
+
+// PeekN 检查消耗n个配额的请求是否会被允许（不消耗配额）
+func (l *SlidingWindowLimiter) PeekN(key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, limit, window, n)
+}
+
+// PeekNContext 检查消耗n个配额的请求是否会被允许（不消耗配额，支持context）
+// 返回的Remaining为当前剩余配额
+func (l *SlidingWindowLimiter) PeekNContext(ctx context.Context, key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	if n < 1 {
+		n = 1
 	}
+	now := time.Now()
 
-	// 设置过期时间（窗口大小的2倍，确保数据清理）
-	if err := l.store.Expire(key, window*2); err != nil {
-		return nil, fmt.Errorf("设置过期时间失败: %w", err)
+	// 执行Lua脚本
+	result, err := l.store.EvalContext(ctx, SlidingWindowPeekScript, slidingWindowKeys(key), now.Add(-window).UnixNano(), limit, n)
+	if err != nil {
+		return nil, fmt.Errorf("执行Lua脚本失败: %w", err)
 	}
+	return parseSlidingWindow(result, now, limit, window)
+}
 
+// slidingWindowKeys 返回记录有序集合和配额总量的key
+// 两个key使用相同的hash tag，Redis Cluster下位于同一个slot
+func slidingWindowKeys(key string) []string {
+	tagged := hashTag(key)
+	return []string{tagged, tagged + ":total"}
+}
+
+// parseSlidingWindow 解析滑动窗口脚本的返回值 {allowed, count, score}
+// score对应的记录滑出窗口时配额得到释放
+func parseSlidingWindow(result interface{}, now time.Time, limit int64, window time.Duration) (*Context, error) {
+	// 解析结果
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 3 {
+		return nil, fmt.Errorf("Lua脚本返回格式错误")
+	}
+	allowedFlag, err := toInt64(values[0])
+	if err != nil {
+		return nil, err
+	}
+	count, err := toInt64(values[1])
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := allowedFlag == 1
 	remaining := limit - count
 	if remaining < 0 {
 		remaining = 0
 	}
 
-	// 计算重置时间（窗口结束时间）
-	reset := now.Add(window).Unix()
-	retryAfter := int64(window.Seconds())
+	// 窗口内最早的请求滑出窗口时才会释放配额
+	resetAt := now.Add(window)
+	if values[2] != nil {
+		oldest, err := toFloat64(values[2])
+		if err != nil {
+			return nil, err
+		}
+		resetAt = time.Unix(0, int64(oldest)).Add(window)
+	}
+
+	var retryAfter int64
+	if !allowed {
+		retryAfter = ceilSeconds(resetAt.Sub(now))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+	}
 
 	return &Context{
 		Allowed:    allowed,
 		Limit:      limit,
 		Remaining:  remaining,
-		Reset:      reset,
+		Reset:      resetAt.Unix(),
 		RetryAfter: retryAfter,
 	}, nil
 }
@@ -0,0 +1,203 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// SlidingWindowCounterScript 滑动窗口计数器Lua脚本（近似滑动窗口，每个key只保存两个计数）
+// 估算值 = 上一窗口计数 × 上一窗口仍在滑动窗口内的比例 + 当前窗口计数
+// KEYS=[{key}:当前窗口序号, {key}:上一窗口序号], ARGV=[limit, window(毫秒), elapsed(当前窗口已过去的毫秒数), cost]
+// 返回 {allowed, current, previous}
+const SlidingWindowCounterScript = `
+	local limit = tonumber(ARGV[1])
+	local window = tonumber(ARGV[2])
+	local elapsed = tonumber(ARGV[3])
+	local cost = tonumber(ARGV[4] or 1)
+
+	local current = tonumber(redis.call('GET', KEYS[1]) or 0)
+	local previous = tonumber(redis.call('GET', KEYS[2]) or 0)
+	local estimated = previous * (window - elapsed) / window + current
+
+	local allowed = 0
+	if estimated + cost <= limit then
+		current = redis.call('INCRBY', KEYS[1], cost)
+		-- 当前窗口的计数在下一个窗口中作为上一窗口计数使用
+		if current == cost then
+			redis.call('PEXPIRE', KEYS[1], window * 2)
+		end
+		allowed = 1
+	end
+
+	return {allowed, current, previous}
+`
+
+// SlidingWindowCounterPeekScript 滑动窗口计数器只读Lua脚本（不计数）
+// 参数和返回值与 SlidingWindowCounterScript 相同
+const SlidingWindowCounterPeekScript = `
+	local limit = tonumber(ARGV[1])
+	local window = tonumber(ARGV[2])
+	local elapsed = tonumber(ARGV[3])
+	local cost = tonumber(ARGV[4] or 1)
+
+	local current = tonumber(redis.call('GET', KEYS[1]) or 0)
+	local previous = tonumber(redis.call('GET', KEYS[2]) or 0)
+	local estimated = previous * (window - elapsed) / window + current
+
+	local allowed = 0
+	if estimated + cost <= limit then
+		allowed = 1
+	end
+
+	return {allowed, current, previous}
+`
+
+// SlidingWindowCounterLimiter 滑动窗口计数器限流器
+// 按上一窗口的剩余比例加权估算请求数，精度接近滑动窗口，但每个key只需两个整数
+type SlidingWindowCounterLimiter struct {
+	store ContextStore
+}
+
+// NewSlidingWindowCounterLimiter 创建滑动窗口计数器限流器
+func NewSlidingWindowCounterLimiter(store Store) *SlidingWindowCounterLimiter {
+	return &SlidingWindowCounterLimiter{
+		store: toContextStore(store),
+	}
+}
+
+// Allow 检查是否允许请求
+func (l *SlidingWindowCounterLimiter) Allow(key string, limit int64, window time.Duration) (*Context, error) {
+	return l.AllowContext(context.Background(), key, limit, window)
+}
+
+// AllowContext 检查是否允许请求（支持context）
+func (l *SlidingWindowCounterLimiter) AllowContext(ctx context.Context, key string, limit int64, window time.Duration) (*Context, error) {
+	return l.AllowNContext(ctx, key, limit, window, 1)
+}
+
+// AllowN 检查是否允许消耗n个配额的请求
+func (l *SlidingWindowCounterLimiter) AllowN(key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	return l.AllowNContext(context.Background(), key, limit, window, n)
+}
+
+// AllowNContext 检查是否允许消耗n个配额的请求（支持context）
+func (l *SlidingWindowCounterLimiter) AllowNContext(ctx context.Context, key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	return l.eval(ctx, SlidingWindowCounterScript, key, limit, window, n)
+}
+
+// Peek 检查请求是否会被允许（不消耗配额）
+func (l *SlidingWindowCounterLimiter) Peek(key string, limit int64, window time.Duration) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, limit, window, 1)
+}
+
+// PeekContext 检查请求是否会被允许（不消耗配额，支持context）
+func (l *SlidingWindowCounterLimiter) PeekContext(ctx context.Context, key string, limit int64, window time.Duration) (*Context, error) {
+	return l.PeekNContext(ctx, key, limit, window, 1)
+}
+
+// PeekN 检查消耗n个配额的请求是否会被允许（不消耗配额）
+func (l *SlidingWindowCounterLimiter) PeekN(key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, limit, window, n)
+}
+
+// PeekNContext 检查消耗n个配额的请求是否会被允许（不消耗配额，支持context）
+func (l *SlidingWindowCounterLimiter) PeekNContext(ctx context.Context, key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	return l.eval(ctx, SlidingWindowCounterPeekScript, key, limit, window, n)
+}
+
+// eval 执行滑动窗口计数器脚本并解析结果
+func (l *SlidingWindowCounterLimiter) eval(ctx context.Context, script, key string, limit int64, window time.Duration, n int64) (*Context, error) {
+	if n < 1 {
+		n = 1
+	}
+	now := time.Now().UnixMilli()
+	windowMillis := durationMillis(window)
+
+	// 按窗口序号区分当前窗口和上一窗口的计数
+	// 两个key使用相同的hash tag，Redis Cluster下位于同一个slot
+	index := now / windowMillis
+	elapsed := now - index*windowMillis
+	tagged := hashTag(key)
+	keys := []string{
+		tagged + ":" + strconv.FormatInt(index, 10),
+		tagged + ":" + strconv.FormatInt(index-1, 10),
+	}
+
+	// 执行Lua脚本
+	result, err := l.store.EvalContext(ctx, script, keys, limit, windowMillis, elapsed, n)
+	if err != nil {
+		return nil, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+
+	// 解析结果
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 3 {
+		return nil, fmt.Errorf("Lua脚本返回格式错误")
+	}
+	allowedFlag, err := toInt64(values[0])
+	if err != nil {
+		return nil, err
+	}
+	current, err := toInt64(values[1])
+	if err != nil {
+		return nil, err
+	}
+	previous, err := toInt64(values[2])
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := allowedFlag == 1
+	weight := float64(windowMillis-elapsed) / float64(windowMillis)
+	estimated := float64(previous)*weight + float64(current)
+
+	remaining := int64(math.Floor(float64(limit) - estimated))
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	windowEnd := (index + 1) * windowMillis
+	ctxResult := &Context{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     ceilDiv(windowEnd, 1000),
+	}
+	if !allowed {
+		ctxResult.RetryAfter = ceilSeconds(time.Duration(counterRetryMillis(limit, windowMillis, elapsed, current, previous, n)) * time.Millisecond)
+		if ctxResult.RetryAfter < 1 {
+			ctxResult.RetryAfter = 1
+		}
+	}
+	return ctxResult, nil
+}
+
+// counterRetryMillis 计算估算值降到可以放行消耗cost个配额的请求所需的毫秒数
+func counterRetryMillis(limit, window, elapsed, current, previous, cost int64) int64 {
+	// 当前窗口已用完配额，需要等到下一个窗口，此时当前计数成为上一窗口计数
+	if current+cost > limit {
+		untilNext := window - elapsed
+		if current == 0 {
+			return untilNext
+		}
+		// 下一窗口中需要 current*(window-e)/window + cost <= limit
+		need := window - int64(math.Floor(float64(limit-cost)*float64(window)/float64(current)))
+		if need < 0 {
+			need = 0
+		}
+		return untilNext + need
+	}
+
+	// 上一窗口的权重随时间降低：previous*(window-e)/window + current + cost <= limit
+	if previous == 0 {
+		return 0
+	}
+	target := window - int64(math.Floor(float64(limit-cost-current)*float64(window)/float64(previous)))
+	if target <= elapsed {
+		return 0
+	}
+	return target - elapsed
+}
@@ -0,0 +1,97 @@
+package algorithm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowCounterLimiter_Allow(t *testing.T) {
+	tests := []struct {
+		name          string
+		result        []interface{}
+		wantAllowed   bool
+		wantRetryMin  int64
+		wantRemaining int64
+	}{
+		{"窗口为空", []interface{}{int64(1), int64(1), int64(0)}, true, 0, 9},
+		{"当前窗口已满", []interface{}{int64(0), int64(10), int64(0)}, false, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MockStoreWithResult{MockStore: *NewMockStore(), result: tt.result}
+			limiter := NewSlidingWindowCounterLimiter(store)
+
+			result, err := limiter.Allow("test", 10, time.Minute)
+			if err != nil {
+				t.Fatalf("Allow() error = %v", err)
+			}
+			if result.Allowed != tt.wantAllowed || result.Remaining != tt.wantRemaining || result.RetryAfter < tt.wantRetryMin {
+				t.Errorf("Allow() = %+v", result)
+			}
+			if result.Reset <= time.Now().Unix() || result.Reset > time.Now().Add(time.Minute).Unix()+1 {
+				t.Errorf("Reset = %d, 应该在当前窗口结束时", result.Reset)
+			}
+		})
+	}
+}
+
+func TestSlidingWindowCounterLimiter_Keys(t *testing.T) {
+	var gotKeys []string
+	store := &MockStoreWithResult{MockStore: *NewMockStore(), result: []interface{}{int64(1), int64(1), int64(0)}}
+	limiter := NewSlidingWindowCounterLimiter(&keyRecorder{MockStoreWithResult: store, keys: &gotKeys})
+
+	if _, err := limiter.Allow("api", 10, time.Minute); err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	// 两个key使用相同的hash tag，Redis Cluster下位于同一个slot
+	if len(gotKeys) != 2 || !strings.HasPrefix(gotKeys[0], "{api}:") || !strings.HasPrefix(gotKeys[1], "{api}:") || gotKeys[0] == gotKeys[1] {
+		t.Errorf("keys = %v, want 当前窗口和上一窗口两个带hash tag的key", gotKeys)
+	}
+}
+
+// keyRecorder 记录脚本调用的key
+type keyRecorder struct {
+	*MockStoreWithResult
+	keys *[]string
+}
+
+func (r *keyRecorder) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	*r.keys = keys
+	return r.MockStoreWithResult.Eval(script, keys, args...)
+}
+
+func TestCounterRetryMillis(t *testing.T) {
+	tests := []struct {
+		name                                      string
+		limit, window, elapsed, current, previous int64
+		want                                      int64
+	}{
+		// 上一窗口计数10，当前窗口计数0，limit=10：权重降到0.9以下才能放行
+		{"等待上一窗口权重降低", 10, 1000, 0, 0, 10, 100},
+		{"上一窗口权重已足够低", 10, 1000, 500, 0, 10, 0},
+		// 当前窗口已满：等到下一窗口，且当前计数作为上一窗口计数时权重需降到0.9
+		{"当前窗口已满", 10, 1000, 400, 10, 0, 700},
+		{"没有计数", 10, 1000, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := counterRetryMillis(tt.limit, tt.window, tt.elapsed, tt.current, tt.previous, 1)
+			if got != tt.want {
+				t.Errorf("counterRetryMillis() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterRetryMillis_Cost(t *testing.T) {
+	// 阈值10，当前窗口已有4个，上一窗口无请求：消耗6个可以立即放行，消耗7个需要等到下一个窗口之后
+	if got := counterRetryMillis(10, 1000, 200, 4, 0, 6); got != 0 {
+		t.Errorf("counterRetryMillis(cost=6) = %d, want 0", got)
+	}
+	// 下一窗口中需要 4*(1000-e)/1000 + 7 <= 10，即 e >= 250
+	if got := counterRetryMillis(10, 1000, 200, 4, 0, 7); got != 800+250 {
+		t.Errorf("counterRetryMillis(cost=7) = %d, want %d", got, 800+250)
+	}
+}
@@ -1,6 +1,9 @@
 package algorithm
 
 import (
+	"math"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -71,8 +74,45 @@ func (m *MockStoreWithZSet) ZCount(key string, min, max float64) (int64, error)
 	return count, nil
 }
 
+// Eval 模拟滑动窗口的Lua脚本
+// KEYS=[记录有序集合key, 配额总量key], ARGV=[limit, now, windowStart, member, window, cost]
 func (m *MockStoreWithZSet) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
-	return nil, nil
+	if script != SlidingWindowScript {
+		return nil, nil
+	}
+	// 返回格式: [allowed(0/1), count, oldest]
+	key, totalKey := keys[0], keys[1]
+	limit := args[0].(int64)
+	now := float64(args[1].(int64))
+	windowStart := float64(args[2].(int64))
+	member := args[3].(string)
+	cost := args[5].(int64)
+
+	// 删除窗口之外的记录，并从总量中扣除它们的配额
+	for m2, score := range m.zsets[key] {
+		if score <= windowStart {
+			n, _ := strconv.ParseInt(m2[strings.LastIndex(m2, ":")+1:], 10, 64)
+			m.data[totalKey] -= n
+			delete(m.zsets[key], m2)
+		}
+	}
+	allowed := int64(0)
+	if m.data[totalKey]+cost <= limit {
+		m.ZAdd(key, now, member)
+		m.data[totalKey] += cost
+		allowed = 1
+	}
+
+	// Lua的false在Redis返回值中为nil
+	var oldest interface{}
+	first := math.Inf(1)
+	for _, score := range m.zsets[key] {
+		if score < first {
+			first = score
+			oldest = strconv.FormatFloat(score, 'f', -1, 64)
+		}
+	}
+	return []interface{}{allowed, m.data[totalKey], oldest}, nil
 }
 
 func TestSlidingWindowLimiter_Allow(t *testing.T) {
@@ -167,7 +207,41 @@ func TestSlidingWindowLimiter_WindowSliding(t *testing.T) {
 	if result.Allowed {
 		t.Error("第4个请求应该被拒绝")
 	}
+	if result.RetryAfter <= 0 {
+		t.Errorf("RetryAfter = %d, want > 0", result.RetryAfter)
+	}
 
-	t.Logf("限流结果: Allowed=%v, Remaining=%d, Reset=%d",
-		result.Allowed, result.Remaining, result.Reset)
+	// 最早的记录滑出窗口后释放配额
+	time.Sleep(window + 10*time.Millisecond)
+	result, err = limiter.Allow(key, limit, window)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if !result.Allowed {
+		t.Error("窗口滑过后应该允许")
+	}
+}
+
+func TestSlidingWindowLimiter_Cost(t *testing.T) {
+	store := NewMockStoreWithZSet()
+	limiter := NewSlidingWindowLimiter(store)
+
+	if result, err := limiter.AllowN("test:cost", 10, time.Minute, 7); err != nil || !result.Allowed || result.Remaining != 3 {
+		t.Fatalf("AllowN() = %+v, %v, want allowed with remaining 3", result, err)
+	}
+	// 每个请求只记录一条成员，配额总量按cost累计
+	if n := len(store.zsets["{test:cost}"]); n != 1 {
+		t.Errorf("成员数量 = %d, want 1", n)
+	}
+	if total := store.data["{test:cost}:total"]; total != 7 {
+		t.Errorf("配额总量 = %d, want 7", total)
+	}
+
+	// 超出剩余配额的请求被拒绝，不占用配额
+	if result, err := limiter.AllowN("test:cost", 10, time.Minute, 4); err != nil || result.Allowed {
+		t.Fatalf("AllowN() = %+v, %v, want denied", result, err)
+	}
+	if result, err := limiter.AllowN("test:cost", 10, time.Minute, 3); err != nil || !result.Allowed || result.Remaining != 0 {
+		t.Errorf("AllowN() = %+v, %v, want allowed with remaining 0", result, err)
+	}
 }
@@ -1,65 +1,168 @@
 package algorithm
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
 
+// TokenBucketScript 令牌桶算法Lua脚本
+// KEYS[1]=key, ARGV=[capacity, rate, now, requested]，返回 {allowed, remaining, capacity}
+const TokenBucketScript = `
+	local key = KEYS[1]
+	local capacity = tonumber(ARGV[1])
+	local rate = tonumber(ARGV[2])
+	local now = tonumber(ARGV[3])
+	local requested = tonumber(ARGV[4])
+
+	-- 获取上次更新时间和当前令牌数
+	local last_time = tonumber(redis.call('HGET', key, 'last_time') or now)
+	local tokens = tonumber(redis.call('HGET', key, 'tokens') or capacity)
+
+	-- 计算新增的令牌数
+	local delta = math.max(0, now - last_time)
+	local new_tokens = math.min(capacity, tokens + delta * rate)
+
+	-- 判断是否有足够的令牌
+	local allowed = new_tokens >= requested
+	local remaining = new_tokens
+
+	if allowed then
+		remaining = new_tokens - requested
+		-- 更新令牌数和时间
+		redis.call('HSET', key, 'tokens', remaining)
+		redis.call('HSET', key, 'last_time', now)
+		-- 设置过期时间
+		redis.call('EXPIRE', key, math.ceil(capacity / rate) + 60)
+	else
+		-- 即使不允许，也更新令牌数（但不消耗）
+		redis.call('HSET', key, 'tokens', new_tokens)
+		redis.call('HSET', key, 'last_time', now)
+		redis.call('EXPIRE', key, math.ceil(capacity / rate) + 60)
+	end
+
+	return {allowed and 1 or 0, remaining, capacity}
+`
+
+// TokenBucketPeekScript 令牌桶只读Lua脚本（计算当前令牌数但不消耗、不写入）
+// 参数和返回值与 TokenBucketScript 相同，remaining为当前令牌数
+const TokenBucketPeekScript = `
+	local key = KEYS[1]
+	local capacity = tonumber(ARGV[1])
+	local rate = tonumber(ARGV[2])
+	local now = tonumber(ARGV[3])
+	local requested = tonumber(ARGV[4])
+
+	local last_time = tonumber(redis.call('HGET', key, 'last_time') or now)
+	local tokens = tonumber(redis.call('HGET', key, 'tokens') or capacity)
+	local new_tokens = math.min(capacity, tokens + math.max(0, now - last_time) * rate)
+
+	return {new_tokens >= requested and 1 or 0, new_tokens, capacity}
+`
+
+// TokenBucketRefundScript 令牌桶退还令牌Lua脚本
+// KEYS[1]=key, ARGV=[capacity, requested]，返回实际退还的令牌数
+// 桶不存在时已恢复为满桶，无需退还；退还后不超过桶容量
+const TokenBucketRefundScript = `
+	local key = KEYS[1]
+	local capacity = tonumber(ARGV[1])
+	local requested = tonumber(ARGV[2])
+
+	local tokens = tonumber(redis.call('HGET', key, 'tokens'))
+	if not tokens then
+		return 0
+	end
+
+	local refund = math.max(0, math.min(requested, capacity - tokens))
+	if refund > 0 then
+		redis.call('HSET', key, 'tokens', tokens + refund)
+	end
+	return math.ceil(refund)
+`
+
 // TokenBucketLimiter 令牌桶限流器
 type TokenBucketLimiter struct {
-	store Store
+	store ContextStore
 }
 
 // NewTokenBucketLimiter 创建令牌桶限流器
 func NewTokenBucketLimiter(store Store) *TokenBucketLimiter {
 	return &TokenBucketLimiter{
-		store: store,
+		store: toContextStore(store),
 	}
 }
 
 // Allow 检查是否允许请求
 func (l *TokenBucketLimiter) Allow(key string, capacity int64, rate float64) (*Context, error) {
-	now := time.Now().Unix()
+	return l.AllowContext(context.Background(), key, capacity, rate)
+}
+
+// AllowContext 检查是否允许请求（支持context）
+func (l *TokenBucketLimiter) AllowContext(ctx context.Context, key string, capacity int64, rate float64) (*Context, error) {
+	return l.AllowNContext(ctx, key, capacity, rate, 1)
+}
+
+// AllowN 检查是否允许消耗n个令牌的请求
+func (l *TokenBucketLimiter) AllowN(key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.AllowNContext(context.Background(), key, capacity, rate, n)
+}
+
+// AllowNContext 检查是否允许消耗n个令牌的请求（支持context）
+func (l *TokenBucketLimiter) AllowNContext(ctx context.Context, key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.eval(ctx, TokenBucketScript, key, capacity, rate, n)
+}
 
-	// Lua脚本实现令牌桶算法
-	script := `
-		local key = KEYS[1]
-		local capacity = tonumber(ARGV[1])
-		local rate = tonumber(ARGV[2])
-		local now = tonumber(ARGV[3])
-		local requested = tonumber(ARGV[4])
-
-		-- 获取上次更新时间和当前令牌数
-		local last_time = tonumber(redis.call('HGET', key, 'last_time') or now)
-		local tokens = tonumber(redis.call('HGET', key, 'tokens') or capacity)
-
-		-- 计算新增的令牌数
-		local delta = math.max(0, now - last_time)
-		local new_tokens = math.min(capacity, tokens + delta * rate)
-
-		-- 判断是否有足够的令牌
-		local allowed = new_tokens >= requested
-		local remaining = new_tokens
-
-		if allowed then
-			remaining = new_tokens - requested
-			-- 更新令牌数和时间
-			redis.call('HSET', key, 'tokens', remaining)
-			redis.call('HSET', key, 'last_time', now)
-			-- 设置过期时间
-			redis.call('EXPIRE', key, math.ceil(capacity / rate) + 60)
-		else
-			-- 即使不允许，也更新令牌数（但不消耗）
-			redis.call('HSET', key, 'tokens', new_tokens)
-			redis.call('HSET', key, 'last_time', now)
-			redis.call('EXPIRE', key, math.ceil(capacity / rate) + 60)
-		end
-
-		return {allowed and 1 or 0, remaining, capacity}
-	`
+// Refund 退还AllowN消耗的n个令牌，返回是否退还成功（桶已满时不退还）
+func (l *TokenBucketLimiter) Refund(key string, capacity int64, n int64) (bool, error) {
+	return l.RefundContext(context.Background(), key, capacity, n)
+}
+
+// RefundContext 退还AllowN消耗的n个令牌（支持context）
+func (l *TokenBucketLimiter) RefundContext(ctx context.Context, key string, capacity int64, n int64) (bool, error) {
+	if n < 1 {
+		n = 1
+	}
+
+	result, err := l.store.EvalContext(ctx, TokenBucketRefundScript, []string{key}, capacity, n)
+	if err != nil {
+		return false, fmt.Errorf("执行Lua脚本失败: %w", err)
+	}
+	refunded, err := toInt64(result)
+	if err != nil {
+		return false, err
+	}
+	return refunded > 0, nil
+}
+
+// Peek 检查请求是否会被允许（不消耗令牌）
+func (l *TokenBucketLimiter) Peek(key string, capacity int64, rate float64) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, capacity, rate, 1)
+}
+
+// PeekContext 检查请求是否会被允许（不消耗令牌，支持context）
+func (l *TokenBucketLimiter) PeekContext(ctx context.Context, key string, capacity int64, rate float64) (*Context, error) {
+	return l.PeekNContext(ctx, key, capacity, rate, 1)
+}
+
+// PeekN 检查消耗n个令牌的请求是否会被允许（不消耗令牌）
+func (l *TokenBucketLimiter) PeekN(key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.PeekNContext(context.Background(), key, capacity, rate, n)
+}
+
+// PeekNContext 检查消耗n个令牌的请求是否会被允许（不消耗令牌，支持context）
+func (l *TokenBucketLimiter) PeekNContext(ctx context.Context, key string, capacity int64, rate float64, n int64) (*Context, error) {
+	return l.eval(ctx, TokenBucketPeekScript, key, capacity, rate, n)
+}
+
+// eval 执行令牌桶脚本并解析结果
+func (l *TokenBucketLimiter) eval(ctx context.Context, script, key string, capacity int64, rate float64, n int64) (*Context, error) {
+	if n < 1 {
+		n = 1
+	}
+	now := time.Now().Unix()
 
 	// 执行Lua脚本
-	result, err := l.store.Eval(script, []string{key}, capacity, rate, now, 1)
+	result, err := l.store.EvalContext(ctx, script, []string{key}, capacity, rate, now, n)
 	if err != nil {
 		return nil, fmt.Errorf("执行Lua脚本失败: %w", err)
 	}
@@ -78,7 +181,7 @@ func (l *TokenBucketLimiter) Allow(key string, capacity int64, rate float64) (*C
 	var retryAfter int64
 	if !allowed {
 		// 需要等待的时间 = (需要的令牌数 - 当前令牌数) / 速率
-		tokensNeeded := 1 - remaining
+		tokensNeeded := n - remaining
 		if tokensNeeded > 0 {
 			retryAfter = int64(float64(tokensNeeded) / rate)
 			if retryAfter < 1 {
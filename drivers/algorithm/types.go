@@ -1,14 +1,23 @@
 package algorithm
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Context 限流上下文（独立类型，不依赖核心包）
 type Context struct {
-	Allowed    bool  // 是否允许请求
-	Limit      int64 // 限流阈值
-	Remaining  int64 // 剩余配额
-	Reset      int64 // 重置时间戳
-	RetryAfter int64 // 建议重试时间（秒）
+	Allowed     bool          // 是否允许请求
+	Limit       int64         // 限流阈值
+	Remaining   int64         // 剩余配额
+	Reset       int64         // 重置时间戳
+	RetryAfter  int64         // 建议重试时间（秒）
+	Delay       time.Duration // 排队等待时间（仅漏桶队列模式）
+	LeaseID     string        // 占用的租约ID（仅并发限流，用于释放名额）
+	RefundToken string        // 退还配额的凭证（仅固定窗口和滑动窗口，用于Refund）
+
+	ResetMillis      int64 // 重置时间戳（毫秒，仅GCRA和并发限制提供）
+	RetryAfterMillis int64 // 建议重试时间（毫秒，仅GCRA和并发限制提供）
 }
 
 // Store 存储接口（algorithm包需要的最小接口）
@@ -24,7 +33,96 @@ type Store interface {
 	Eval(script string, keys []string, args ...interface{}) (interface{}, error)
 }
 
+// ContextStore 支持context的存储接口（algorithm包需要的最小接口）
+type ContextStore interface {
+	GetContext(ctx context.Context, key string) (int64, error)
+	IncrContext(ctx context.Context, key string) (int64, error)
+	IncrByContext(ctx context.Context, key string, value int64) (int64, error)
+	ExpireContext(ctx context.Context, key string, expiration time.Duration) error
+	TTLContext(ctx context.Context, key string) (time.Duration, error)
+	ZAddContext(ctx context.Context, key string, score float64, member string) error
+	ZRemRangeByScoreContext(ctx context.Context, key string, min, max float64) error
+	ZCountContext(ctx context.Context, key string, min, max float64) (int64, error)
+	EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
 // Algorithm 限流算法接口
 type Algorithm interface {
 	Allow(key string, limit int64, window time.Duration) (*Context, error)
 }
+
+// toContextStore 将Store转换为ContextStore（store已实现ContextStore时直接使用）
+func toContextStore(store Store) ContextStore {
+	if cs, ok := store.(ContextStore); ok {
+		return cs
+	}
+	return &contextStore{store: store}
+}
+
+// contextStore 为不支持context的Store提供适配，调用前检查context是否已结束
+type contextStore struct {
+	store Store
+}
+
+func (s *contextStore) GetContext(ctx context.Context, key string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.Get(key)
+}
+
+func (s *contextStore) IncrContext(ctx context.Context, key string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.Incr(key)
+}
+
+func (s *contextStore) IncrByContext(ctx context.Context, key string, value int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.IncrBy(key, value)
+}
+
+func (s *contextStore) ExpireContext(ctx context.Context, key string, expiration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.Expire(key, expiration)
+}
+
+func (s *contextStore) TTLContext(ctx context.Context, key string) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.TTL(key)
+}
+
+func (s *contextStore) ZAddContext(ctx context.Context, key string, score float64, member string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.ZAdd(key, score, member)
+}
+
+func (s *contextStore) ZRemRangeByScoreContext(ctx context.Context, key string, min, max float64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.ZRemRangeByScore(key, min, max)
+}
+
+func (s *contextStore) ZCountContext(ctx context.Context, key string, min, max float64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.ZCount(key, min, max)
+}
+
+func (s *contextStore) EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.store.Eval(script, keys, args...)
+}
@@ -0,0 +1,55 @@
+package algorithm
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// durationMillis 将时间转换为毫秒（向上取整，至少1毫秒），用于PEXPIRE等命令
+func durationMillis(d time.Duration) int64 {
+	ms := int64((d + time.Millisecond - 1) / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return ms
+}
+
+// hashTag 将key包装为Redis Cluster的hash tag，以它为前缀的多个key位于同一个slot
+func hashTag(key string) string {
+	return "{" + key + "}"
+}
+
+// ceilSeconds 将时间转换为秒（向上取整），用于RetryAfter
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// toInt64 将Lua脚本返回值转换为int64
+func toInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int64:
+		return n, nil
+	case string:
+		return strconv.ParseInt(n, 10, 64)
+	default:
+		return 0, fmt.Errorf("无效的整数返回值: %v", v)
+	}
+}
+
+// toFloat64 将Lua脚本返回值转换为float64（Redis以字符串返回有序集合分数等浮点数）
+func toFloat64(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	case string:
+		return strconv.ParseFloat(n, 64)
+	default:
+		return 0, fmt.Errorf("无效的数值返回值: %v", v)
+	}
+}
@@ -0,0 +1,346 @@
+package ban
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// 存储结构：
+//   violation:<dim>:<id>      违规分数（达到阈值后自动封禁）
+//   offenses:<dim>:<id>       历史封禁次数（用于递增封禁时长）
+//   blacklist:{<dim>:<id>}    封禁标记（值为1，过期时间即封禁时长，限流检查只读取该键）
+//   ban:{<dim>:<id>}          封禁详情哈希（reason/source/created_at/expires_at）
+//   bans:<dim>                封禁索引有序集合（成员为标识，分数为到期时间毫秒，永久封禁为+inf）
+//
+// 封禁标记和详情以 {<dim>:<id>} 为hash tag，在Redis Cluster中位于同一个槽，由一个脚本原子写入；
+// 索引单独占用一个槽，每个脚本只访问通过KEYS声明的键，封禁和解封先更新标记和详情，再更新索引
+
+// BanScript 封禁Lua脚本（写入标记和详情）
+// KEYS=[blacklist, ban], ARGV=[created_at(毫秒), expires_at(毫秒，0表示永久), ttl(毫秒), reason, source]
+const BanScript = `
+	local ttl = tonumber(ARGV[3])
+
+	redis.call('SET', KEYS[1], 1)
+	redis.call('DEL', KEYS[2])
+	redis.call('HSET', KEYS[2], 'reason', ARGV[4], 'source', ARGV[5], 'created_at', ARGV[1], 'expires_at', ARGV[2])
+
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[1], ttl)
+		redis.call('PEXPIRE', KEYS[2], ttl)
+	end
+
+	return 1
+`
+
+// UnbanScript 解除封禁Lua脚本（删除标记和详情）
+// KEYS=[blacklist, ban]，返回删除前是否处于封禁状态（1/0）
+const UnbanScript = `
+	local existed = redis.call('DEL', KEYS[1])
+	redis.call('DEL', KEYS[2])
+	return existed
+`
+
+// IndexAddScript 封禁索引添加成员Lua脚本
+// KEYS=[bans], ARGV=[id, now(毫秒), 到期时间(毫秒，+inf表示永久)]
+// 写入前清理已到期的成员，避免从不调用List时索引无限增长
+const IndexAddScript = `
+	redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[2])
+	redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+	return 1
+`
+
+// IndexRemoveScript 封禁索引删除成员Lua脚本（同时清理已到期的成员）
+// KEYS=[bans], ARGV=[id, now(毫秒)]
+const IndexRemoveScript = `
+	redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[2])
+	return redis.call('ZREM', KEYS[1], ARGV[1])
+`
+
+// ListScript 分页列出封禁标识Lua脚本（先清理已到期的成员）
+// KEYS=[bans], ARGV=[now(毫秒), offset, limit]，按到期时间升序返回标识
+const ListScript = `
+	redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+	local offset = tonumber(ARGV[2])
+	return redis.call('ZRANGE', KEYS[1], offset, offset + tonumber(ARGV[3]) - 1)
+`
+
+// RecordScript 读取封禁详情Lua脚本
+// KEYS=[ban]，返回 {reason, source, created_at, expires_at}，详情不存在时created_at为nil
+const RecordScript = `
+	return redis.call('HMGET', KEYS[1], 'reason', 'source', 'created_at', 'expires_at')
+`
+
+// listBatchSize 列出全部封禁时每次读取的标识数量，避免单次脚本调用返回整个索引
+const listBatchSize = 500
+
+// 封禁来源
+const (
+	// SourceManual 手动封禁
+	SourceManual = "manual"
+	// SourceAuto 自动拉黑
+	SourceAuto = "auto"
+)
+
+// Store 存储接口（ban包需要的最小接口）
+type Store interface {
+	Eval(script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// ContextStore 支持context的存储接口（ban包需要的最小接口）
+type ContextStore interface {
+	EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// Record 封禁记录
+type Record struct {
+	// Identifier 被封禁的标识（IP或用户ID）
+	Identifier string
+	// Reason 封禁原因
+	Reason string
+	// Source 封禁来源（manual/auto）
+	Source string
+	// CreatedAt 封禁时间（Unix毫秒）
+	CreatedAt int64
+	// ExpiresAt 到期时间（Unix毫秒，0表示永久）
+	ExpiresAt int64
+}
+
+// Manager 封禁管理器
+type Manager struct {
+	store ContextStore
+}
+
+// NewManager 创建封禁管理器
+func NewManager(store Store) *Manager {
+	return &Manager{store: toContextStore(store)}
+}
+
+// toContextStore 将Store转换为ContextStore（store已实现ContextStore时直接使用）
+func toContextStore(store Store) ContextStore {
+	if cs, ok := store.(ContextStore); ok {
+		return cs
+	}
+	return &contextStore{store: store}
+}
+
+// contextStore 为不支持context的Store提供适配，调用前检查context是否已结束
+type contextStore struct {
+	store Store
+}
+
+func (s *contextStore) EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.store.Eval(script, keys, args...)
+}
+
+// BlacklistKey 封禁标记的key
+func BlacklistKey(dimension, identifier string) string {
+	return "blacklist:" + recordTag(dimension, identifier)
+}
+
+// ViolationKey 违规分数的key
+func ViolationKey(dimension, identifier string) string {
+	return "violation:" + dimension + ":" + identifier
+}
+
+// OffenseKey 历史封禁次数的key
+func OffenseKey(dimension, identifier string) string {
+	return "offenses:" + dimension + ":" + identifier
+}
+
+// RecordKey 封禁详情的key
+func RecordKey(dimension, identifier string) string {
+	return "ban:" + recordTag(dimension, identifier)
+}
+
+// IndexKey 封禁索引的key
+func IndexKey(dimension string) string {
+	return "bans:" + dimension
+}
+
+// recordTag 封禁标记和详情共用的hash tag，保证两者在Redis Cluster中位于同一个槽
+func recordTag(dimension, identifier string) string {
+	return "{" + dimension + ":" + identifier + "}"
+}
+
+// Ban 封禁标识（duration<=0 表示永久封禁），重复封禁会覆盖原有记录
+func (m *Manager) Ban(ctx context.Context, dimension, identifier string, duration time.Duration, reason, source string) error {
+	now := time.Now()
+
+	var ttl, expiresAt int64
+	score := "+inf"
+	if duration > 0 {
+		ttl = duration.Milliseconds()
+		if ttl == 0 {
+			ttl = 1
+		}
+		expiresAt = now.UnixMilli() + ttl
+		score = strconv.FormatInt(expiresAt, 10)
+	}
+
+	_, err := m.store.EvalContext(ctx, BanScript,
+		[]string{BlacklistKey(dimension, identifier), RecordKey(dimension, identifier)},
+		now.UnixMilli(), expiresAt, ttl, reason, source,
+	)
+	if err != nil {
+		return fmt.Errorf("执行封禁脚本失败: %w", err)
+	}
+
+	_, err = m.store.EvalContext(ctx, IndexAddScript, []string{IndexKey(dimension)}, identifier, now.UnixMilli(), score)
+	if err != nil {
+		return fmt.Errorf("更新封禁索引失败: %w", err)
+	}
+	return nil
+}
+
+// Unban 解除封禁，返回解除前是否处于封禁状态
+func (m *Manager) Unban(ctx context.Context, dimension, identifier string) (bool, error) {
+	result, err := m.store.EvalContext(ctx, UnbanScript,
+		[]string{BlacklistKey(dimension, identifier), RecordKey(dimension, identifier)},
+	)
+	if err != nil {
+		return false, fmt.Errorf("执行解封脚本失败: %w", err)
+	}
+	existed, ok := result.(int64)
+	if !ok {
+		return false, fmt.Errorf("Lua脚本返回格式错误")
+	}
+
+	if err := m.removeFromIndex(ctx, dimension, identifier); err != nil {
+		return false, err
+	}
+	return existed > 0, nil
+}
+
+// List 分页列出维度下未到期的封禁记录（按到期时间升序，永久封禁在最后）
+// offset为跳过的记录数，limit<=0时列出offset之后的全部记录（分批读取）
+func (m *Manager) List(ctx context.Context, dimension string, offset, limit int) ([]Record, error) {
+	if offset < 0 {
+		offset = 0
+	}
+
+	var records []Record
+	for limit <= 0 || len(records) < limit {
+		batch := listBatchSize
+		if limit > 0 {
+			batch = min(batch, limit-len(records))
+		}
+
+		ids, err := m.listIdentifiers(ctx, dimension, offset, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			record, ok, err := m.record(ctx, dimension, id)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				// 详情已被删除（如直接删除了键），同步清理索引，后续成员前移
+				if err := m.removeFromIndex(ctx, dimension, id); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			records = append(records, record)
+			offset++
+		}
+		if len(ids) < batch {
+			break
+		}
+	}
+	return records, nil
+}
+
+// listIdentifiers 从索引中按到期时间升序读取一批标识
+func (m *Manager) listIdentifiers(ctx context.Context, dimension string, offset, limit int) ([]string, error) {
+	result, err := m.store.EvalContext(ctx, ListScript, []string{IndexKey(dimension)}, time.Now().UnixMilli(), offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("执行封禁列表脚本失败: %w", err)
+	}
+	values, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Lua脚本返回格式错误")
+	}
+	ids := make([]string, len(values))
+	for i, v := range values {
+		ids[i] = toString(v)
+	}
+	return ids, nil
+}
+
+// record 读取封禁详情，详情不存在时返回false
+func (m *Manager) record(ctx context.Context, dimension, identifier string) (Record, bool, error) {
+	result, err := m.store.EvalContext(ctx, RecordScript, []string{RecordKey(dimension, identifier)})
+	if err != nil {
+		return Record{}, false, fmt.Errorf("读取封禁详情失败: %w", err)
+	}
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 4 {
+		return Record{}, false, fmt.Errorf("Lua脚本返回格式错误")
+	}
+	if values[2] == nil {
+		return Record{}, false, nil
+	}
+
+	createdAt, err := toInt64(values[2])
+	if err != nil {
+		return Record{}, false, err
+	}
+	var expiresAt int64
+	if values[3] != nil {
+		if expiresAt, err = toInt64(values[3]); err != nil {
+			return Record{}, false, err
+		}
+	}
+	return Record{
+		Identifier: identifier,
+		Reason:     toString(values[0]),
+		Source:     toString(values[1]),
+		CreatedAt:  createdAt,
+		ExpiresAt:  expiresAt,
+	}, true, nil
+}
+
+// removeFromIndex 从索引中删除标识
+func (m *Manager) removeFromIndex(ctx context.Context, dimension, identifier string) error {
+	_, err := m.store.EvalContext(ctx, IndexRemoveScript, []string{IndexKey(dimension)}, identifier, time.Now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("更新封禁索引失败: %w", err)
+	}
+	return nil
+}
+
+// toString 将脚本返回值转换为字符串
+func toString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// toInt64 将脚本返回值转换为int64（Redis以字符串返回哈希字段）
+func toInt64(v interface{}) (int64, error) {
+	switch val := v.(type) {
+	case int64:
+		return val, nil
+	case string:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("无效的数值: %s", val)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("无效的数值类型: %T", v)
+	}
+}
@@ -0,0 +1,249 @@
+// 使用外部测试包：memory 存储依赖 ban 包注册脚本实现
+package ban_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/ban"
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+func newTestManager(t *testing.T) (*ban.Manager, *memory.Store) {
+	t.Helper()
+	store := memory.NewStore(0)
+	t.Cleanup(func() { store.Close() })
+	return ban.NewManager(store), store
+}
+
+func TestKeys(t *testing.T) {
+	if got := ban.BlacklistKey("ip", "1.2.3.4"); got != "blacklist:{ip:1.2.3.4}" {
+		t.Errorf("BlacklistKey() = %s", got)
+	}
+	if got := ban.ViolationKey("user", "u1"); got != "violation:user:u1" {
+		t.Errorf("ViolationKey() = %s", got)
+	}
+	if got := ban.OffenseKey("ip", "1.2.3.4"); got != "offenses:ip:1.2.3.4" {
+		t.Errorf("OffenseKey() = %s", got)
+	}
+	if got := ban.RecordKey("ip", "1.2.3.4"); got != "ban:{ip:1.2.3.4}" {
+		t.Errorf("RecordKey() = %s", got)
+	}
+	if got := ban.IndexKey("ip"); got != "bans:ip" {
+		t.Errorf("IndexKey() = %s", got)
+	}
+}
+
+func TestManager_BanAndList(t *testing.T) {
+	m, store := newTestManager(t)
+	ctx := context.Background()
+
+	before := time.Now().UnixMilli()
+	if err := m.Ban(ctx, "ip", "1.1.1.1", time.Hour, "刷接口", ban.SourceManual); err != nil {
+		t.Fatalf("Ban() error = %v", err)
+	}
+	if err := m.Ban(ctx, "ip", "2.2.2.2", 0, "攻击", ban.SourceAuto); err != nil {
+		t.Fatalf("Ban() error = %v", err)
+	}
+
+	// 封禁标记带过期时间，永久封禁没有过期时间
+	if v, _ := store.Get(ban.BlacklistKey("ip", "1.1.1.1")); v != 1 {
+		t.Errorf("封禁标记 = %d, want 1", v)
+	}
+	if ttl, _ := store.TTL(ban.BlacklistKey("ip", "1.1.1.1")); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("封禁标记TTL = %v, want ~1h", ttl)
+	}
+	if ttl, _ := store.TTL(ban.BlacklistKey("ip", "2.2.2.2")); ttl != -1*time.Second {
+		t.Errorf("永久封禁TTL = %v, want -1s", ttl)
+	}
+
+	records, err := m.List(ctx, "ip", 0, 0)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records) = %d, want 2", len(records))
+	}
+
+	// 按到期时间排序，永久封禁在最后
+	r := records[0]
+	if r.Identifier != "1.1.1.1" || r.Reason != "刷接口" || r.Source != ban.SourceManual {
+		t.Errorf("records[0] = %+v", r)
+	}
+	if r.CreatedAt < before || r.ExpiresAt != r.CreatedAt+time.Hour.Milliseconds() {
+		t.Errorf("records[0] 时间 = %d -> %d", r.CreatedAt, r.ExpiresAt)
+	}
+	if records[1].Identifier != "2.2.2.2" || records[1].ExpiresAt != 0 || records[1].Source != ban.SourceAuto {
+		t.Errorf("records[1] = %+v", records[1])
+	}
+
+	// 其他维度互不影响
+	if records, _ := m.List(ctx, "user", 0, 0); len(records) != 0 {
+		t.Errorf("user维度 = %v, want 空", records)
+	}
+}
+
+func TestManager_BanOverwrites(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+
+	m.Ban(ctx, "user", "u1", time.Hour, "第一次", ban.SourceAuto)
+	m.Ban(ctx, "user", "u1", 0, "第二次", ban.SourceManual)
+
+	records, err := m.List(ctx, "user", 0, 0)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(records) != 1 || records[0].Reason != "第二次" || records[0].ExpiresAt != 0 {
+		t.Errorf("重复封禁应该覆盖原记录，records = %+v", records)
+	}
+}
+
+func TestManager_Unban(t *testing.T) {
+	m, store := newTestManager(t)
+	ctx := context.Background()
+
+	m.Ban(ctx, "ip", "1.1.1.1", time.Hour, "test", ban.SourceManual)
+
+	existed, err := m.Unban(ctx, "ip", "1.1.1.1")
+	if err != nil || !existed {
+		t.Fatalf("Unban() = %v, %v, want true", existed, err)
+	}
+	if v, _ := store.Get(ban.BlacklistKey("ip", "1.1.1.1")); v != 0 {
+		t.Error("解封后封禁标记应该被删除")
+	}
+	if records, _ := m.List(ctx, "ip", 0, 0); len(records) != 0 {
+		t.Errorf("解封后不应出现在列表中，records = %+v", records)
+	}
+
+	existed, err = m.Unban(ctx, "ip", "1.1.1.1")
+	if err != nil || existed {
+		t.Errorf("重复解封 = %v, %v, want false", existed, err)
+	}
+}
+
+func TestManager_ListDropsExpired(t *testing.T) {
+	m, store := newTestManager(t)
+	ctx := context.Background()
+
+	m.Ban(ctx, "ip", "1.1.1.1", 20*time.Millisecond, "short", ban.SourceAuto)
+	m.Ban(ctx, "ip", "2.2.2.2", time.Hour, "long", ban.SourceAuto)
+
+	time.Sleep(40 * time.Millisecond)
+
+	records, err := m.List(ctx, "ip", 0, 0)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(records) != 1 || records[0].Identifier != "2.2.2.2" {
+		t.Errorf("records = %+v, want 只有2.2.2.2", records)
+	}
+
+	// 详情被直接删除时同步清理索引
+	store.Del(ban.RecordKey("ip", "2.2.2.2"))
+	if records, _ := m.List(ctx, "ip", 0, 0); len(records) != 0 {
+		t.Errorf("records = %+v, want 空", records)
+	}
+	if n, _ := store.ZCount(ban.IndexKey("ip"), 0, 1e18); n != 0 {
+		t.Errorf("索引成员数 = %d, want 0", n)
+	}
+}
+
+func TestManager_BanPrunesExpiredIndex(t *testing.T) {
+	m, store := newTestManager(t)
+	ctx := context.Background()
+
+	m.Ban(ctx, "ip", "1.1.1.1", 20*time.Millisecond, "short", ban.SourceAuto)
+	m.Ban(ctx, "ip", "2.2.2.2", 20*time.Millisecond, "short", ban.SourceAuto)
+	time.Sleep(40 * time.Millisecond)
+
+	// 不调用List时，封禁和解封也会清理已到期的索引成员
+	m.Ban(ctx, "ip", "3.3.3.3", time.Hour, "long", ban.SourceAuto)
+	if n, _ := store.ZCount(ban.IndexKey("ip"), 0, 1e18); n != 1 {
+		t.Errorf("封禁后索引成员数 = %d, want 1", n)
+	}
+
+	m.Ban(ctx, "ip", "4.4.4.4", 20*time.Millisecond, "short", ban.SourceAuto)
+	time.Sleep(40 * time.Millisecond)
+	m.Unban(ctx, "ip", "3.3.3.3")
+	if n, _ := store.ZCount(ban.IndexKey("ip"), 0, 1e18); n != 0 {
+		t.Errorf("解封后索引成员数 = %d, want 0", n)
+	}
+}
+
+// slotStore 检查每次脚本调用的所有键是否具有相同的hash tag（Redis Cluster要求同一个槽）
+type slotStore struct {
+	*memory.Store
+	t *testing.T
+}
+
+func (s *slotStore) EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	for _, key := range keys[1:] {
+		if hashSlotKey(key) != hashSlotKey(keys[0]) {
+			s.t.Errorf("脚本访问的键不在同一个槽: %v", keys)
+		}
+	}
+	return s.Store.EvalContext(ctx, script, keys, args...)
+}
+
+// hashSlotKey 返回Redis计算槽位时使用的部分（存在非空hash tag时为tag内容）
+func hashSlotKey(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+func TestManager_ClusterSlots(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+	m := ban.NewManager(&slotStore{Store: store, t: t})
+	ctx := context.Background()
+
+	m.Ban(ctx, "ip", "2001:db8::1", time.Hour, "test", ban.SourceManual)
+	m.Ban(ctx, "user", "u1", 0, "test", ban.SourceAuto)
+	if records, err := m.List(ctx, "ip", 0, 0); err != nil || len(records) != 1 {
+		t.Errorf("List() = %+v, %v", records, err)
+	}
+	if existed, err := m.Unban(ctx, "user", "u1"); err != nil || !existed {
+		t.Errorf("Unban() = %v, %v", existed, err)
+	}
+}
+
+func TestManager_ListPage(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+
+	// 超过单批读取数量，验证分批读取和分页
+	const total = 1200
+	for i := 0; i < total; i++ {
+		m.Ban(ctx, "user", fmt.Sprintf("u%04d", i), time.Duration(i+1)*time.Minute, "test", ban.SourceAuto)
+	}
+
+	all, err := m.List(ctx, "user", 0, 0)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(all) != total || all[0].Identifier != "u0000" || all[total-1].Identifier != "u1199" {
+		t.Fatalf("len(all) = %d", len(all))
+	}
+
+	page, err := m.List(ctx, "user", 10, 5)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(page) != 5 || page[0].Identifier != "u0010" || page[4].Identifier != "u0014" {
+		t.Errorf("page = %+v", page)
+	}
+	if page, _ := m.List(ctx, "user", total-2, 10); len(page) != 2 {
+		t.Errorf("最后一页 len = %d, want 2", len(page))
+	}
+	if page, _ := m.List(ctx, "user", total, 10); len(page) != 0 {
+		t.Errorf("超出范围 len = %d, want 0", len(page))
+	}
+}
@@ -0,0 +1,294 @@
+// Package metrics 以Prometheus文本格式导出限流指标，不依赖任何指标库
+//
+// 使用方式：
+//
+//	collector := metrics.New()
+//	store := metrics.NewStore(redis.NewStore(client, "app"), collector)
+//	limiter, _ := ratelimiter.NewFromFile("rate_limit.yaml", store, ratelimiter.WithMetrics(collector))
+//	http.Handle("/metrics", collector)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter"
+)
+
+// DefaultBuckets 存储耗时直方图的默认桶（秒）
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// contentType Prometheus文本格式的Content-Type
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collector 限流指标收集器
+// 实现 ratelimiter.Metrics 接口，并作为 http.Handler 输出Prometheus文本格式的指标
+type Collector struct {
+	decisions       *counterVec
+	violations      *counterVec
+	violationWeight *counterVec
+	bans            *counterVec
+	storeDuration   *histogramVec
+	storeErrors     *counterVec
+}
+
+// New 创建指标收集器，buckets为存储耗时直方图的桶（秒），为空时使用 DefaultBuckets
+func New(buckets ...float64) *Collector {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Collector{
+		decisions: newCounterVec("ratelimiter_decisions_total",
+			"规则检查次数", "rule", "algorithm", "decision"),
+		violations: newCounterVec("ratelimiter_violations_total",
+			"违规次数", "dimension"),
+		violationWeight: newCounterVec("ratelimiter_violation_weight_total",
+			"违规分数", "dimension"),
+		bans: newCounterVec("ratelimiter_bans_total",
+			"封禁次数", "dimension", "source"),
+		storeDuration: newHistogramVec("ratelimiter_store_duration_seconds",
+			"存储调用耗时（秒）", buckets, "operation"),
+		storeErrors: newCounterVec("ratelimiter_store_errors_total",
+			"存储调用失败次数", "operation"),
+	}
+}
+
+// ObserveDecision 记录规则的检查结果
+func (c *Collector) ObserveDecision(rule string, algorithm ratelimiter.Algorithm, decision string) {
+	c.decisions.add(1, rule, string(algorithm), decision)
+}
+
+// ObserveViolation 记录违规
+func (c *Collector) ObserveViolation(dimension string, weight int) {
+	c.violations.add(1, dimension)
+	c.violationWeight.add(float64(weight), dimension)
+}
+
+// ObserveBan 记录封禁
+func (c *Collector) ObserveBan(dimension, source string) {
+	c.bans.add(1, dimension, source)
+}
+
+// ObserveStore 记录一次存储调用的耗时和结果
+func (c *Collector) ObserveStore(operation string, duration time.Duration, err error) {
+	c.storeDuration.observe(duration.Seconds(), operation)
+	if err != nil {
+		c.storeErrors.add(1, operation)
+	}
+}
+
+// ServeHTTP 输出Prometheus文本格式的指标
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_, _ = c.WriteTo(w)
+}
+
+// WriteTo 将全部指标以Prometheus文本格式写入w
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	c.decisions.write(cw)
+	c.violations.write(cw)
+	c.violationWeight.write(cw)
+	c.bans.write(cw)
+	c.storeDuration.write(cw)
+	c.storeErrors.write(cw)
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// counterVec 带标签的计数器
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*counter
+}
+
+// counter 一组标签值对应的计数
+type counter struct {
+	labelValues []string
+	value       float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]*counter),
+	}
+}
+
+// add 按标签值增加计数
+func (v *counterVec) add(delta float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.values[key]
+	if !ok {
+		c = &counter{labelValues: labelValues}
+		v.values[key] = c
+	}
+	c.value += delta
+}
+
+// write 按标签值排序输出
+func (v *counterVec) write(w *countingWriter) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	w.printf("# HELP %s %s\n# TYPE %s counter\n", v.name, v.help, v.name)
+	for _, key := range sortedKeys(v.values) {
+		c := v.values[key]
+		w.printf("%s%s %s\n", v.name, formatLabels(v.labels, c.labelValues, "", ""), formatValue(c.value))
+	}
+}
+
+// histogramVec 带标签的直方图
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+// histogram 一组标签值对应的直方图（counts为各桶的非累计计数）
+type histogram struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		values:  make(map[string]*histogram),
+	}
+}
+
+// observe 按标签值记录一次观测
+func (v *histogramVec) observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	i := sort.SearchFloat64s(v.buckets, value)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, ok := v.values[key]
+	if !ok {
+		h = &histogram{labelValues: labelValues, counts: make([]uint64, len(v.buckets))}
+		v.values[key] = h
+	}
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += value
+	h.count++
+}
+
+// write 按标签值排序输出，桶计数为累计值
+func (v *histogramVec) write(w *countingWriter) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	w.printf("# HELP %s %s\n# TYPE %s histogram\n", v.name, v.help, v.name)
+	for _, key := range sortedKeys(v.values) {
+		h := v.values[key]
+		var cumulative uint64
+		for i, upper := range v.buckets {
+			cumulative += h.counts[i]
+			w.printf("%s_bucket%s %d\n", v.name, formatLabels(v.labels, h.labelValues, "le", formatValue(upper)), cumulative)
+		}
+		w.printf("%s_bucket%s %d\n", v.name, formatLabels(v.labels, h.labelValues, "le", "+Inf"), h.count)
+		w.printf("%s_sum%s %s\n", v.name, formatLabels(v.labels, h.labelValues, "", ""), formatValue(h.sum))
+		w.printf("%s_count%s %d\n", v.name, formatLabels(v.labels, h.labelValues, "", ""), h.count)
+	}
+}
+
+// formatLabels 格式化标签，extraName不为空时追加额外的标签（如直方图的le）
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(values[i]))
+		b.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extraName)
+		b.WriteString(`="`)
+		b.WriteString(extraValue)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// labelEscaper 标签值需要转义反斜杠、双引号和换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// formatValue 格式化指标值
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sortedKeys 返回排序后的键，保证输出顺序稳定
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// countingWriter 记录写入字节数和第一个错误
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter"
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+// render 返回全部指标的文本
+func render(t *testing.T, c *Collector) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := c.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	return b.String()
+}
+
+// assertContains 检查输出包含全部指定行
+func assertContains(t *testing.T, output string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("输出缺少 %q\n%s", line, output)
+		}
+	}
+}
+
+func TestCollector_Counters(t *testing.T) {
+	c := New()
+	c.ObserveDecision("登录", ratelimiter.AlgorithmFixedWindow, ratelimiter.DecisionAllowed)
+	c.ObserveDecision("登录", ratelimiter.AlgorithmFixedWindow, ratelimiter.DecisionAllowed)
+	c.ObserveDecision("登录", ratelimiter.AlgorithmFixedWindow, ratelimiter.DecisionDenied)
+	c.ObserveViolation("ip", 3)
+	c.ObserveViolation("ip", 2)
+	c.ObserveBan("user", "auto")
+
+	assertContains(t, render(t, c),
+		"# TYPE ratelimiter_decisions_total counter",
+		`ratelimiter_decisions_total{rule="登录",algorithm="fixed_window",decision="allowed"} 2`,
+		`ratelimiter_decisions_total{rule="登录",algorithm="fixed_window",decision="denied"} 1`,
+		`ratelimiter_violations_total{dimension="ip"} 2`,
+		`ratelimiter_violation_weight_total{dimension="ip"} 5`,
+		`ratelimiter_bans_total{dimension="user",source="auto"} 1`,
+	)
+}
+
+func TestCollector_Histogram(t *testing.T) {
+	c := New(0.01, 0.1)
+	c.ObserveStore("eval", 5*time.Millisecond, nil)
+	c.ObserveStore("eval", 50*time.Millisecond, nil)
+	c.ObserveStore("eval", time.Second, errTest)
+
+	assertContains(t, render(t, c),
+		"# TYPE ratelimiter_store_duration_seconds histogram",
+		`ratelimiter_store_duration_seconds_bucket{operation="eval",le="0.01"} 1`,
+		`ratelimiter_store_duration_seconds_bucket{operation="eval",le="0.1"} 2`,
+		`ratelimiter_store_duration_seconds_bucket{operation="eval",le="+Inf"} 3`,
+		`ratelimiter_store_duration_seconds_sum{operation="eval"} 1.055`,
+		`ratelimiter_store_duration_seconds_count{operation="eval"} 3`,
+		`ratelimiter_store_errors_total{operation="eval"} 1`,
+	)
+}
+
+func TestCollector_EscapeLabelValue(t *testing.T) {
+	c := New()
+	c.ObserveDecision("a\"b\\c\nd", ratelimiter.AlgorithmGCRA, ratelimiter.DecisionDenied)
+
+	assertContains(t, render(t, c),
+		`ratelimiter_decisions_total{rule="a\"b\\c\nd",algorithm="gcra",decision="denied"} 1`,
+	)
+}
+
+func TestCollector_ServeHTTP(t *testing.T) {
+	c := New()
+	c.ObserveBan("ip", "manual")
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", got)
+	}
+	assertContains(t, w.Body.String(), `ratelimiter_bans_total{dimension="ip",source="manual"} 1`)
+}
+
+func TestCollector_WithLimiter(t *testing.T) {
+	collector := New()
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &ratelimiter.Config{
+		Default: ratelimiter.DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []ratelimiter.RuleConfig{
+			{Name: "登录", Path: "/login", By: "ip", Params: []string{"1", "1m"}, RecordViolation: true, ViolationWeight: 2},
+		},
+		AutoBan: ratelimiter.AutoBanConfig{
+			Enabled:            true,
+			Dimensions:         []string{"ip"},
+			ViolationThreshold: 4,
+			ViolationWindow:    "1m",
+			BanDuration:        "1h",
+		},
+	}
+	limiter, err := ratelimiter.NewFromConfig(config, NewStore(store, collector), ratelimiter.WithMetrics(collector))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		limiter.Check("/login", "POST", "1.1.1.1", "")
+	}
+	// 预检不记录
+	limiter.Peek("/login", "POST", "2.2.2.2", "")
+
+	output := render(t, collector)
+	assertContains(t, output,
+		`ratelimiter_decisions_total{rule="登录",algorithm="fixed_window",decision="allowed"} 1`,
+		`ratelimiter_decisions_total{rule="登录",algorithm="fixed_window",decision="denied"} 2`,
+		`ratelimiter_violations_total{dimension="ip"} 2`,
+		`ratelimiter_violation_weight_total{dimension="ip"} 4`,
+		`ratelimiter_bans_total{dimension="ip",source="auto"} 1`,
+	)
+	if !strings.Contains(output, `ratelimiter_store_duration_seconds_count{operation="eval"}`) {
+		t.Errorf("缺少存储耗时指标\n%s", output)
+	}
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter"
+)
+
+// Store 记录存储调用耗时和失败次数的存储装饰器，可包装任意存储驱动
+type Store struct {
+	ctxStore  ratelimiter.ContextStore
+	collector *Collector
+}
+
+// NewStore 包装存储，调用结果记录到collector
+func NewStore(store ratelimiter.Store, collector *Collector) *Store {
+	return &Store{
+		ctxStore:  ratelimiter.ToContextStore(store),
+		collector: collector,
+	}
+}
+
+// observe 记录从start开始的一次调用
+func (s *Store) observe(operation string, start time.Time, err error) {
+	s.collector.ObserveStore(operation, time.Since(start), err)
+}
+
+// Get 获取键的值
+func (s *Store) Get(key string) (int64, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// Set 设置键的值
+func (s *Store) Set(key string, value int64) error {
+	return s.SetContext(context.Background(), key, value)
+}
+
+// Del 删除键
+func (s *Store) Del(key string) error {
+	return s.DelContext(context.Background(), key)
+}
+
+// Incr 增加键的值
+func (s *Store) Incr(key string) (int64, error) {
+	return s.IncrContext(context.Background(), key)
+}
+
+// IncrBy 增加键的值指定数量
+func (s *Store) IncrBy(key string, value int64) (int64, error) {
+	return s.IncrByContext(context.Background(), key, value)
+}
+
+// Expire 设置键的过期时间
+func (s *Store) Expire(key string, expiration time.Duration) error {
+	return s.ExpireContext(context.Background(), key, expiration)
+}
+
+// TTL 获取键的剩余过期时间
+func (s *Store) TTL(key string) (time.Duration, error) {
+	return s.TTLContext(context.Background(), key)
+}
+
+// ZAdd 添加有序集合成员
+func (s *Store) ZAdd(key string, score float64, member string) error {
+	return s.ZAddContext(context.Background(), key, score, member)
+}
+
+// ZRemRangeByScore 删除有序集合中指定分数范围的成员
+func (s *Store) ZRemRangeByScore(key string, min, max float64) error {
+	return s.ZRemRangeByScoreContext(context.Background(), key, min, max)
+}
+
+// ZCount 统计有序集合中指定分数范围的成员数量
+func (s *Store) ZCount(key string, min, max float64) (int64, error) {
+	return s.ZCountContext(context.Background(), key, min, max)
+}
+
+// Eval 执行Lua脚本
+func (s *Store) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return s.EvalContext(context.Background(), script, keys, args...)
+}
+
+// GetContext 获取键的值
+func (s *Store) GetContext(ctx context.Context, key string) (int64, error) {
+	start := time.Now()
+	value, err := s.ctxStore.GetContext(ctx, key)
+	s.observe("get", start, err)
+	return value, err
+}
+
+// SetContext 设置键的值
+func (s *Store) SetContext(ctx context.Context, key string, value int64) error {
+	start := time.Now()
+	err := s.ctxStore.SetContext(ctx, key, value)
+	s.observe("set", start, err)
+	return err
+}
+
+// DelContext 删除键
+func (s *Store) DelContext(ctx context.Context, key string) error {
+	start := time.Now()
+	err := s.ctxStore.DelContext(ctx, key)
+	s.observe("del", start, err)
+	return err
+}
+
+// IncrContext 增加键的值
+func (s *Store) IncrContext(ctx context.Context, key string) (int64, error) {
+	start := time.Now()
+	value, err := s.ctxStore.IncrContext(ctx, key)
+	s.observe("incr", start, err)
+	return value, err
+}
+
+// IncrByContext 增加键的值指定数量
+func (s *Store) IncrByContext(ctx context.Context, key string, value int64) (int64, error) {
+	start := time.Now()
+	result, err := s.ctxStore.IncrByContext(ctx, key, value)
+	s.observe("incrby", start, err)
+	return result, err
+}
+
+// ExpireContext 设置键的过期时间
+func (s *Store) ExpireContext(ctx context.Context, key string, expiration time.Duration) error {
+	start := time.Now()
+	err := s.ctxStore.ExpireContext(ctx, key, expiration)
+	s.observe("expire", start, err)
+	return err
+}
+
+// TTLContext 获取键的剩余过期时间
+func (s *Store) TTLContext(ctx context.Context, key string) (time.Duration, error) {
+	start := time.Now()
+	ttl, err := s.ctxStore.TTLContext(ctx, key)
+	s.observe("ttl", start, err)
+	return ttl, err
+}
+
+// ZAddContext 添加有序集合成员
+func (s *Store) ZAddContext(ctx context.Context, key string, score float64, member string) error {
+	start := time.Now()
+	err := s.ctxStore.ZAddContext(ctx, key, score, member)
+	s.observe("zadd", start, err)
+	return err
+}
+
+// ZRemRangeByScoreContext 删除有序集合中指定分数范围的成员
+func (s *Store) ZRemRangeByScoreContext(ctx context.Context, key string, min, max float64) error {
+	start := time.Now()
+	err := s.ctxStore.ZRemRangeByScoreContext(ctx, key, min, max)
+	s.observe("zremrangebyscore", start, err)
+	return err
+}
+
+// ZCountContext 统计有序集合中指定分数范围的成员数量
+func (s *Store) ZCountContext(ctx context.Context, key string, min, max float64) (int64, error) {
+	start := time.Now()
+	count, err := s.ctxStore.ZCountContext(ctx, key, min, max)
+	s.observe("zcount", start, err)
+	return count, err
+}
+
+// EvalContext 执行Lua脚本
+func (s *Store) EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	start := time.Now()
+	result, err := s.ctxStore.EvalContext(ctx, script, keys, args...)
+	s.observe("eval", start, err)
+	return result, err
+}
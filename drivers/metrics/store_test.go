@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("存储不可用")
+
+// failingStore 所有调用都失败的存储
+type failingStore struct{}
+
+func (failingStore) Get(string) (int64, error)                       { return 0, errTest }
+func (failingStore) Set(string, int64) error                         { return errTest }
+func (failingStore) Del(string) error                                { return errTest }
+func (failingStore) Incr(string) (int64, error)                      { return 0, errTest }
+func (failingStore) IncrBy(string, int64) (int64, error)             { return 0, errTest }
+func (failingStore) Expire(string, time.Duration) error              { return errTest }
+func (failingStore) TTL(string) (time.Duration, error)               { return 0, errTest }
+func (failingStore) ZAdd(string, float64, string) error              { return errTest }
+func (failingStore) ZRemRangeByScore(string, float64, float64) error { return errTest }
+func (failingStore) ZCount(string, float64, float64) (int64, error)  { return 0, errTest }
+func (failingStore) Eval(string, []string, ...interface{}) (interface{}, error) {
+	return nil, errTest
+}
+
+func TestStore_ObserveErrors(t *testing.T) {
+	collector := New()
+	store := NewStore(failingStore{}, collector)
+
+	if _, err := store.Get("key"); !errors.Is(err, errTest) {
+		t.Errorf("Get() error = %v, want errTest", err)
+	}
+	store.IncrBy("key", 2)
+	store.IncrBy("key", 2)
+	store.Eval("return 1", []string{"key"})
+
+	output := render(t, collector)
+	assertContains(t, output,
+		`ratelimiter_store_errors_total{operation="get"} 1`,
+		`ratelimiter_store_errors_total{operation="incrby"} 2`,
+		`ratelimiter_store_errors_total{operation="eval"} 1`,
+		`ratelimiter_store_duration_seconds_count{operation="incrby"} 2`,
+	)
+	if strings.Contains(output, `operation="set"`) {
+		t.Error("未调用的操作不应输出")
+	}
+}
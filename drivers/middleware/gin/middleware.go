@@ -1,7 +1,9 @@
 package gin
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/Fischlvor/go-ratelimiter"
 	"github.com/gin-gonic/gin"
@@ -12,12 +14,47 @@ type Limiter interface {
 	Check(path, method, ip, userID string) (*ratelimiter.Result, error)
 }
 
+// ContextLimiter 支持context的限流器接口
+// Limiter 同时实现该接口时，中间件会传入请求的context，使限流检查遵循请求的取消和超时
+type ContextLimiter interface {
+	CheckContext(ctx context.Context, path, method, ip, userID string) (*ratelimiter.Result, error)
+}
+
+// RequestLimiter 支持请求描述的限流器接口
+// Limiter 同时实现该接口时，中间件会传入完整的请求描述（含请求头和属性）
+type RequestLimiter interface {
+	CheckRequestContext(ctx context.Context, req *ratelimiter.Request) (*ratelimiter.Result, error)
+}
+
+// Releaser 支持释放并发名额的限流器接口
+// Limiter 同时实现该接口时，中间件会在请求处理完成后释放结果中占用的并发名额
+type Releaser interface {
+	ReleaseContext(ctx context.Context, result *ratelimiter.Result) error
+}
+
+// Renewer 支持续期并发名额的限流器接口
+// Limiter 同时实现该接口时，中间件会在处理函数执行期间定期续期结果中占用的并发名额，避免长请求的名额到期被回收
+type Renewer interface {
+	RenewContext(ctx context.Context, result *ratelimiter.Result) error
+}
+
+// Refunder 支持退还配额的限流器接口
+// 启用 WithRefundOnServerError 且 Limiter 实现该接口时，中间件会在处理函数返回5xx时退还消耗的配额
+type Refunder interface {
+	RefundContext(ctx context.Context, result *ratelimiter.Result) error
+}
+
+// KeyGetter 从Gin上下文构造请求描述
+type KeyGetter func(*gin.Context) *ratelimiter.Request
+
 // Middleware Gin限流中间件
 type Middleware struct {
 	Limiter    Limiter
 	OnError    func(*gin.Context, error)
 	OnExceeded func(*gin.Context, *ratelimiter.Result)
-	KeyGetter  func(*gin.Context) (path, method, ip, userID string)
+	KeyGetter  KeyGetter
+	// RefundOnServerError 处理函数返回5xx或panic时退还消耗的配额
+	RefundOnServerError bool
 }
 
 // NewMiddleware 创建Gin中间件
@@ -40,9 +77,21 @@ func NewMiddleware(limiter Limiter, options ...Option) gin.HandlerFunc {
 
 // Handle 处理请求
 func (m *Middleware) Handle(c *gin.Context) {
-	path, method, ip, userID := m.KeyGetter(c)
+	req := m.KeyGetter(c)
+	if req == nil {
+		req = &ratelimiter.Request{}
+	}
 
-	result, err := m.Limiter.Check(path, method, ip, userID)
+	var result *ratelimiter.Result
+	var err error
+	switch l := m.Limiter.(type) {
+	case RequestLimiter:
+		result, err = l.CheckRequestContext(c.Request.Context(), req)
+	case ContextLimiter:
+		result, err = l.CheckContext(c.Request.Context(), req.Path, req.Method, req.IP, req.UserID)
+	default:
+		result, err = m.Limiter.Check(req.Path, req.Method, req.IP, req.UserID)
+	}
 	if err != nil {
 		m.OnError(c, err)
 		return
@@ -54,12 +103,101 @@ func (m *Middleware) Handle(c *gin.Context) {
 	c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", result.Reset))
 
 	if !result.Allowed {
-		c.Header("Retry-After", fmt.Sprintf("%d", result.RetryAfter))
+		// 静态黑名单和永久封禁没有重试时间，不设置Retry-After
+		if result.RetryAfter > 0 {
+			c.Header("Retry-After", fmt.Sprintf("%d", result.RetryAfter))
+		}
 		m.OnExceeded(c, result)
 		return
 	}
 
+	// 并发限制：请求处理完成（包括panic）后释放名额
+	// 请求的context此时可能已取消，释放使用不随请求取消的context
+	if releaser, ok := m.Limiter.(Releaser); ok && len(result.Leases) > 0 {
+		defer func() {
+			if err := releaser.ReleaseContext(context.WithoutCancel(c.Request.Context()), result); err != nil {
+				_ = c.Error(err)
+			}
+		}()
+	}
+	// 处理期间定期续期，在释放名额之前停止
+	if renewer, ok := m.Limiter.(Renewer); ok && len(result.Leases) > 0 {
+		stop := startRenewal(context.WithoutCancel(c.Request.Context()), renewer, result)
+		defer func() {
+			if err := stop(); err != nil {
+				_ = c.Error(err)
+			}
+		}()
+	}
+
+	// 服务端错误不应计入用户的配额：处理函数返回5xx、panic或未执行时退还
+	completed := false
+	if refunder, ok := m.Limiter.(Refunder); ok && m.RefundOnServerError && len(result.Refunds) > 0 {
+		defer func() {
+			if completed && c.Writer.Status() < 500 {
+				return
+			}
+			if err := refunder.RefundContext(context.WithoutCancel(c.Request.Context()), result); err != nil {
+				_ = c.Error(err)
+			}
+		}()
+	}
+
+	// 漏桶队列模式：排队等待后再处理请求，客户端断开时不再等待
+	if result.Delay > 0 {
+		timer := time.NewTimer(result.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-c.Request.Context().Done():
+			m.OnError(c, c.Request.Context().Err())
+			return
+		}
+	}
+
 	c.Next()
+	completed = true
+}
+
+// startRenewal 按最短租约时长的1/3间隔续期，返回停止续期的函数（返回续期过程中的错误）
+// 租约已到期被回收或续期失败时不再继续续期
+func startRenewal(ctx context.Context, renewer Renewer, result *ratelimiter.Result) func() error {
+	var lease time.Duration
+	for _, l := range result.Leases {
+		if lease == 0 || l.Duration < lease {
+			lease = l.Duration
+		}
+	}
+	if lease <= 0 {
+		return func() error { return nil }
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := renewer.RenewContext(ctx, result); err != nil {
+					if ctx.Err() != nil {
+						err = nil
+					}
+					done <- err
+					return
+				}
+			case <-ctx.Done():
+				done <- nil
+				return
+			}
+		}
+	}()
+
+	return func() error {
+		cancel()
+		return <-done
+	}
 }
 
 // Option 中间件选项
@@ -80,12 +218,19 @@ func WithExceededHandler(handler func(*gin.Context, *ratelimiter.Result)) Option
 }
 
 // WithKeyGetter 自定义key获取
-func WithKeyGetter(getter func(*gin.Context) (path, method, ip, userID string)) Option {
+func WithKeyGetter(getter KeyGetter) Option {
 	return func(m *Middleware) {
 		m.KeyGetter = getter
 	}
 }
 
+// WithRefundOnServerError 处理函数返回5xx或panic时退还消耗的配额（Limiter需实现Refunder接口）
+func WithRefundOnServerError() Option {
+	return func(m *Middleware) {
+		m.RefundOnServerError = true
+	}
+}
+
 // DefaultErrorHandler 默认错误处理
 func DefaultErrorHandler(c *gin.Context, err error) {
 	c.JSON(500, gin.H{
@@ -99,6 +244,7 @@ func DefaultErrorHandler(c *gin.Context, err error) {
 func DefaultExceededHandler(c *gin.Context, result *ratelimiter.Result) {
 	c.JSON(429, gin.H{
 		"error":     "请求过于频繁",
+		"reason":    result.Reason,
 		"limit":     result.Limit,
 		"remaining": result.Remaining,
 		"reset":     result.Reset,
@@ -107,6 +253,14 @@ func DefaultExceededHandler(c *gin.Context, result *ratelimiter.Result) {
 }
 
 // DefaultKeyGetter 默认key获取
-func DefaultKeyGetter(c *gin.Context) (path, method, ip, userID string) {
-	return c.Request.URL.Path, c.Request.Method, c.ClientIP(), c.GetString("user_id")
+// 用户ID取自上下文中的 user_id，请求头和请求体长度原样传入，供 header 提取器和规则的cost表达式使用
+func DefaultKeyGetter(c *gin.Context) *ratelimiter.Request {
+	return &ratelimiter.Request{
+		Path:          c.Request.URL.Path,
+		Method:        c.Request.Method,
+		IP:            c.ClientIP(),
+		UserID:        c.GetString("user_id"),
+		Header:        c.Request.Header,
+		ContentLength: c.Request.ContentLength,
+	}
 }
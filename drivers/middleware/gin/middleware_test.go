@@ -1,10 +1,12 @@
 package gin
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -24,6 +26,29 @@ func (m *MockLimiter) Check(path, method, ip, userID string) (*ratelimiter.Resul
 	return &ratelimiter.Result{Allowed: true}, nil
 }
 
+// MockContextLimiter 支持context的模拟限流器
+type MockContextLimiter struct {
+	MockLimiter
+	gotCtx context.Context
+}
+
+func (m *MockContextLimiter) CheckContext(ctx context.Context, path, method, ip, userID string) (*ratelimiter.Result, error) {
+	m.gotCtx = ctx
+	return &ratelimiter.Result{Allowed: true}, nil
+}
+
+// MockRequestLimiter 支持请求描述的模拟限流器
+type MockRequestLimiter struct {
+	MockContextLimiter
+	gotReq *ratelimiter.Request
+}
+
+func (m *MockRequestLimiter) CheckRequestContext(ctx context.Context, req *ratelimiter.Request) (*ratelimiter.Result, error) {
+	m.gotCtx = ctx
+	m.gotReq = req
+	return &ratelimiter.Result{Allowed: true}, nil
+}
+
 func TestMiddleware_Allow(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -97,6 +122,62 @@ func TestMiddleware_Exceeded(t *testing.T) {
 	}
 }
 
+func TestMiddleware_ExceededWithoutRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		result         *ratelimiter.Result
+		wantRetryAfter string
+	}{
+		{
+			name:           "静态黑名单",
+			result:         &ratelimiter.Result{Allowed: false, Reason: ratelimiter.ReasonBlacklisted},
+			wantRetryAfter: "",
+		},
+		{
+			name: "临时封禁",
+			result: &ratelimiter.Result{
+				Allowed:    false,
+				Reason:     ratelimiter.ReasonBanned,
+				BanTTL:     90 * time.Second,
+				RetryAfter: 90,
+			},
+			wantRetryAfter: "90",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLimiter := &MockLimiter{
+				checkFunc: func(path, method, ip, userID string) (*ratelimiter.Result, error) {
+					return tt.result, nil
+				},
+			}
+
+			r := gin.New()
+			r.Use(NewMiddleware(mockLimiter))
+			r.GET("/test", func(c *gin.Context) {
+				c.JSON(200, gin.H{"message": "success"})
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != 429 {
+				t.Errorf("期望状态码 429, 得到 %d", w.Code)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+			if !strings.Contains(w.Body.String(), string(tt.result.Reason)) {
+				t.Errorf("响应体应包含拒绝原因: %s", w.Body.String())
+			}
+		})
+	}
+}
+
 func TestMiddleware_CustomErrorHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -189,8 +270,10 @@ func TestMiddleware_CustomKeyGetter(t *testing.T) {
 
 	r := gin.New()
 	r.Use(NewMiddleware(mockLimiter,
-		WithKeyGetter(func(c *gin.Context) (path, method, ip, userID string) {
-			return c.Request.URL.Path, c.Request.Method, c.ClientIP(), "custom_user_123"
+		WithKeyGetter(func(c *gin.Context) *ratelimiter.Request {
+			req := DefaultKeyGetter(c)
+			req.UserID = "custom_user_123"
+			return req
 		}),
 	))
 	r.GET("/test", func(c *gin.Context) {
@@ -212,18 +295,21 @@ func TestDefaultKeyGetter(t *testing.T) {
 	r := gin.New()
 	r.GET("/test", func(c *gin.Context) {
 		c.Set("user_id", "test_user")
-		path, method, ip, userID := DefaultKeyGetter(c)
+		req := DefaultKeyGetter(c)
 
-		if path != "/test" {
-			t.Errorf("path = %s, want /test", path)
+		if req.Path != "/test" {
+			t.Errorf("path = %s, want /test", req.Path)
 		}
-		if method != "GET" {
-			t.Errorf("method = %s, want GET", method)
+		if req.Method != "GET" {
+			t.Errorf("method = %s, want GET", req.Method)
 		}
 		// IP可能为空（在测试环境中）
-		t.Logf("IP: %s", ip)
-		if userID != "test_user" {
-			t.Errorf("userID = %s, want test_user", userID)
+		t.Logf("IP: %s", req.IP)
+		if req.UserID != "test_user" {
+			t.Errorf("userID = %s, want test_user", req.UserID)
+		}
+		if req.Header.Get("X-Tenant-ID") != "t1" {
+			t.Errorf("header X-Tenant-ID = %s, want t1", req.Header.Get("X-Tenant-ID"))
 		}
 
 		c.JSON(200, gin.H{"ok": true})
@@ -231,7 +317,27 @@ func TestDefaultKeyGetter(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set("X-Tenant-ID", "t1")
+	r.ServeHTTP(w, req)
+}
+
+func TestDefaultKeyGetter_ContentLength(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var got int64
+	r := gin.New()
+	r.POST("/bulk", func(c *gin.Context) {
+		got = DefaultKeyGetter(c).ContentLength
+		c.Status(200)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/bulk", strings.NewReader("0123456789"))
 	r.ServeHTTP(w, req)
+
+	if got != 10 {
+		t.Errorf("ContentLength = %d, want 10", got)
+	}
 }
 
 func BenchmarkMiddleware(b *testing.B) {
@@ -284,3 +390,289 @@ func TestDefaultErrorHandler(t *testing.T) {
 		t.Errorf("期望响应包含'限流检查失败'，实际: %s", body)
 	}
 }
+
+func TestMiddleware_ContextLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type ctxKey struct{}
+	mockLimiter := &MockContextLimiter{
+		MockLimiter: MockLimiter{
+			checkFunc: func(path, method, ip, userID string) (*ratelimiter.Result, error) {
+				t.Error("实现了ContextLimiter时不应调用Check")
+				return &ratelimiter.Result{Allowed: true}, nil
+			},
+		},
+	}
+
+	r := gin.New()
+	r.Use(NewMiddleware(mockLimiter))
+	r.GET("/test", func(c *gin.Context) {
+		c.String(200, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "trace-id"))
+	r.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("期望状态码 200, 得到 %d", w.Code)
+	}
+	if mockLimiter.gotCtx == nil || mockLimiter.gotCtx.Value(ctxKey{}) != "trace-id" {
+		t.Error("应该传入请求的context")
+	}
+}
+
+func TestMiddleware_RequestLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockLimiter := &MockRequestLimiter{}
+
+	r := gin.New()
+	r.Use(NewMiddleware(mockLimiter,
+		WithKeyGetter(func(c *gin.Context) *ratelimiter.Request {
+			req := DefaultKeyGetter(c)
+			req.Attributes = map[string]string{ratelimiter.AttrTenantID: c.Query("tenant")}
+			return req
+		}),
+	))
+	r.GET("/test", func(c *gin.Context) {
+		c.String(200, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test?tenant=acme", nil)
+	req.Header.Set("X-API-Key", "k1")
+	r.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("期望状态码 200, 得到 %d", w.Code)
+	}
+	got := mockLimiter.gotReq
+	if got == nil {
+		t.Fatal("实现了RequestLimiter时应该调用CheckRequestContext")
+	}
+	if got.Path != "/test" || got.Method != "GET" {
+		t.Errorf("请求描述 = %+v", got)
+	}
+	if got.Attribute(ratelimiter.AttrTenantID) != "acme" {
+		t.Errorf("tenant = %s, want acme", got.Attribute(ratelimiter.AttrTenantID))
+	}
+	if got.Header.Get("X-API-Key") != "k1" {
+		t.Errorf("X-API-Key = %s, want k1", got.Header.Get("X-API-Key"))
+	}
+}
+
+func TestMiddleware_QueueDelay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	delay := 50 * time.Millisecond
+	mockLimiter := &MockLimiter{
+		checkFunc: func(path, method, ip, userID string) (*ratelimiter.Result, error) {
+			return &ratelimiter.Result{Allowed: true, Limit: 10, Remaining: 9, Delay: delay}, nil
+		},
+	}
+
+	r := gin.New()
+	r.Use(NewMiddleware(mockLimiter))
+	r.GET("/test", func(c *gin.Context) {
+		c.String(200, "ok")
+	})
+
+	// 排队等待后再处理请求
+	start := time.Now()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("期望状态码 200, 得到 %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("等待时间 = %v, want >= %v", elapsed, delay)
+	}
+
+	// 客户端断开时不再等待
+	delay = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(ctx, "GET", "/test", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != 500 {
+		t.Errorf("期望状态码 500, 得到 %d", w.Code)
+	}
+}
+
+// MockReleaser 支持释放并发名额的模拟限流器
+type MockReleaser struct {
+	MockLimiter
+	released []*ratelimiter.Result
+}
+
+func (m *MockReleaser) ReleaseContext(ctx context.Context, result *ratelimiter.Result) error {
+	m.released = append(m.released, result)
+	return nil
+}
+
+func TestMiddleware_ReleaseAfterHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockLimiter := &MockReleaser{}
+	mockLimiter.checkFunc = func(path, method, ip, userID string) (*ratelimiter.Result, error) {
+		return &ratelimiter.Result{
+			Allowed: true,
+			Leases:  []ratelimiter.Lease{{Key: "export:user:1", ID: "lease-1"}},
+		}, nil
+	}
+
+	r := gin.New()
+	r.Use(NewMiddleware(mockLimiter))
+	r.GET("/test", func(c *gin.Context) {
+		if len(mockLimiter.released) != 0 {
+			t.Error("处理函数执行期间不应释放名额")
+		}
+		c.String(200, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("期望状态码 200, 得到 %d", w.Code)
+	}
+	if len(mockLimiter.released) != 1 || mockLimiter.released[0].Leases[0].ID != "lease-1" {
+		t.Errorf("请求结束后应释放名额, released = %v", mockLimiter.released)
+	}
+
+	// 没有占用名额或被拒绝时不调用释放
+	mockLimiter.released = nil
+	mockLimiter.checkFunc = func(path, method, ip, userID string) (*ratelimiter.Result, error) {
+		return &ratelimiter.Result{Allowed: false, RetryAfter: 1}, nil
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != 429 {
+		t.Errorf("期望状态码 429, 得到 %d", w.Code)
+	}
+	if len(mockLimiter.released) != 0 {
+		t.Errorf("被拒绝的请求不应释放名额, released = %v", mockLimiter.released)
+	}
+}
+
+// MockRenewer 支持续期和释放并发名额的模拟限流器
+type MockRenewer struct {
+	MockReleaser
+	mu      sync.Mutex
+	renewed int
+}
+
+func (m *MockRenewer) RenewContext(ctx context.Context, result *ratelimiter.Result) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.renewed++
+	return nil
+}
+
+func (m *MockRenewer) renewCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.renewed
+}
+
+func TestMiddleware_RenewDuringHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockLimiter := &MockRenewer{}
+	mockLimiter.checkFunc = func(path, method, ip, userID string) (*ratelimiter.Result, error) {
+		return &ratelimiter.Result{
+			Allowed: true,
+			Leases:  []ratelimiter.Lease{{Key: "export:user:1", ID: "lease-1", Duration: 30 * time.Millisecond}},
+		}, nil
+	}
+
+	r := gin.New()
+	r.Use(NewMiddleware(mockLimiter))
+	r.GET("/test", func(c *gin.Context) {
+		// 处理时间超过租约时长
+		time.Sleep(100 * time.Millisecond)
+		c.String(200, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("期望状态码 200, 得到 %d", w.Code)
+	}
+	if got := mockLimiter.renewCount(); got < 3 {
+		t.Errorf("处理期间应定期续期, renewed = %d", got)
+	}
+	if len(mockLimiter.released) != 1 {
+		t.Errorf("请求结束后应释放名额, released = %v", mockLimiter.released)
+	}
+
+	// 请求结束后不再续期
+	renewed := mockLimiter.renewCount()
+	time.Sleep(50 * time.Millisecond)
+	if got := mockLimiter.renewCount(); got != renewed {
+		t.Errorf("请求结束后不应继续续期, renewed = %d, want %d", got, renewed)
+	}
+}
+
+// MockRefunder 支持退还配额的模拟限流器
+type MockRefunder struct {
+	MockLimiter
+	refunded []*ratelimiter.Result
+}
+
+func (m *MockRefunder) RefundContext(ctx context.Context, result *ratelimiter.Result) error {
+	m.refunded = append(m.refunded, result)
+	return nil
+}
+
+func TestMiddleware_RefundOnServerError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		options    []Option
+		handler    gin.HandlerFunc
+		wantRefund bool
+	}{
+		{"成功响应不退还", []Option{WithRefundOnServerError()}, func(c *gin.Context) { c.String(200, "ok") }, false},
+		{"客户端错误不退还", []Option{WithRefundOnServerError()}, func(c *gin.Context) { c.String(400, "bad") }, false},
+		{"服务端错误退还", []Option{WithRefundOnServerError()}, func(c *gin.Context) { c.String(503, "unavailable") }, true},
+		{"panic退还", []Option{WithRefundOnServerError()}, func(c *gin.Context) { panic("boom") }, true},
+		{"未启用时不退还", nil, func(c *gin.Context) { c.String(500, "error") }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLimiter := &MockRefunder{}
+			mockLimiter.checkFunc = func(path, method, ip, userID string) (*ratelimiter.Result, error) {
+				return &ratelimiter.Result{
+					Allowed: true,
+					Refunds: []ratelimiter.Refund{{Key: "api:ip:1.2.3.4", Cost: 1}},
+				}, nil
+			}
+
+			r := gin.New()
+			r.Use(gin.Recovery())
+			r.Use(NewMiddleware(mockLimiter, tt.options...))
+			r.GET("/test", tt.handler)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			r.ServeHTTP(w, req)
+
+			if got := len(mockLimiter.refunded) == 1; got != tt.wantRefund {
+				t.Errorf("退还次数 = %d, wantRefund = %v", len(mockLimiter.refunded), tt.wantRefund)
+			}
+		})
+	}
+}
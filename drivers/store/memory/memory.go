@@ -0,0 +1,311 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrWrongType 键已存在且类型与操作不匹配（对应Redis的WRONGTYPE）
+	ErrWrongType = errors.New("操作的键类型不匹配")
+	// ErrScriptNotSupported 脚本未注册，内存存储无法执行Lua脚本
+	ErrScriptNotSupported = errors.New("内存存储不支持该脚本")
+)
+
+// DefaultCleanupInterval 默认的过期键清理间隔
+const DefaultCleanupInterval = time.Minute
+
+// kind 值类型
+type kind int
+
+const (
+	kindInt kind = iota
+	kindHash
+	kindZSet
+)
+
+// entry 存储项
+type entry struct {
+	kind     kind
+	num      int64
+	hash     map[string]string
+	zset     map[string]float64
+	expireAt time.Time // 零值表示永不过期
+}
+
+// expired 判断是否已过期
+func (e *entry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+// Store 内存存储实现（并发安全，支持键过期、有序集合和脚本模拟）
+type Store struct {
+	mu        sync.Mutex
+	items     map[string]*entry
+	scripts   map[string]ScriptFunc
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewStore 创建内存存储
+// cleanupInterval 为后台清理过期键的间隔，<=0 时使用 DefaultCleanupInterval
+func NewStore(cleanupInterval time.Duration) *Store {
+	if cleanupInterval <= 0 {
+		cleanupInterval = DefaultCleanupInterval
+	}
+
+	s := &Store{
+		items:   make(map[string]*entry),
+		scripts: make(map[string]ScriptFunc),
+		stop:    make(chan struct{}),
+	}
+
+	// 注册内置算法脚本
+	for script, fn := range builtinScripts {
+		s.scripts[script] = fn
+	}
+
+	go s.sweep(cleanupInterval)
+
+	return s
+}
+
+// Close 停止后台清理
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	return nil
+}
+
+// sweep 定期清理过期键
+func (s *Store) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// deleteExpired 删除所有已过期的键
+func (s *Store) deleteExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, e := range s.items {
+		if e.expired(now) {
+			delete(s.items, key)
+		}
+	}
+}
+
+// Len 返回当前未过期的键数量
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	n := 0
+	for _, e := range s.items {
+		if !e.expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// tx 在持有锁的情况下构造事务视图
+func (s *Store) tx() *Tx {
+	return &Tx{store: s, now: time.Now()}
+}
+
+// Get 获取键的值
+func (s *Store) Get(key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tx().Get(key)
+}
+
+// Set 设置键的值（会清除原有的过期时间）
+func (s *Store) Set(key string, value int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tx().Set(key, value)
+	return nil
+}
+
+// Del 删除键
+func (s *Store) Del(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tx().Del(key)
+	return nil
+}
+
+// Incr 递增
+func (s *Store) Incr(key string) (int64, error) {
+	return s.IncrBy(key, 1)
+}
+
+// IncrBy 增加指定数量
+func (s *Store) IncrBy(key string, value int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tx().IncrBy(key, value)
+}
+
+// Expire 设置过期时间
+func (s *Store) Expire(key string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tx().Expire(key, expiration)
+	return nil
+}
+
+// TTL 获取剩余时间（与Redis一致：键不存在返回-2s，未设置过期返回-1s）
+func (s *Store) TTL(key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tx().TTL(key), nil
+}
+
+// ZAdd 添加到有序集合
+func (s *Store) ZAdd(key string, score float64, member string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tx().ZAdd(key, score, member)
+}
+
+// ZRemRangeByScore 按分数范围删除（闭区间）
+func (s *Store) ZRemRangeByScore(key string, min, max float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.tx().ZRemRangeByScore(key, min, max)
+	return err
+}
+
+// ZCount 统计分数范围内的成员数量（闭区间）
+func (s *Store) ZCount(key string, min, max float64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tx().ZCount(key, min, max)
+}
+
+// Eval 执行已注册的脚本
+// 内存存储无法解释Lua，脚本按原文匹配到注册的Go实现后在锁内原子执行
+func (s *Store) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn, ok := s.scripts[script]
+	if !ok {
+		return nil, ErrScriptNotSupported
+	}
+	return fn(s.tx(), keys, args)
+}
+
+// RegisterScript 注册脚本的Go实现（按脚本原文匹配，已存在则覆盖）
+func (s *Store) RegisterScript(script string, fn ScriptFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[script] = fn
+}
+
+// 以下为ContextStore实现：内存操作不可中断，仅在执行前检查context是否已结束
+
+// GetContext 获取键的值（支持context）
+func (s *Store) GetContext(ctx context.Context, key string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.Get(key)
+}
+
+// SetContext 设置键的值（支持context）
+func (s *Store) SetContext(ctx context.Context, key string, value int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Set(key, value)
+}
+
+// DelContext 删除键（支持context）
+func (s *Store) DelContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Del(key)
+}
+
+// IncrContext 递增（支持context）
+func (s *Store) IncrContext(ctx context.Context, key string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.Incr(key)
+}
+
+// IncrByContext 增加指定数量（支持context）
+func (s *Store) IncrByContext(ctx context.Context, key string, value int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.IncrBy(key, value)
+}
+
+// ExpireContext 设置过期时间（支持context）
+func (s *Store) ExpireContext(ctx context.Context, key string, expiration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Expire(key, expiration)
+}
+
+// TTLContext 获取剩余时间（支持context）
+func (s *Store) TTLContext(ctx context.Context, key string) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.TTL(key)
+}
+
+// ZAddContext 添加到有序集合（支持context）
+func (s *Store) ZAddContext(ctx context.Context, key string, score float64, member string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.ZAdd(key, score, member)
+}
+
+// ZRemRangeByScoreContext 按分数范围删除（支持context）
+func (s *Store) ZRemRangeByScoreContext(ctx context.Context, key string, min, max float64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.ZRemRangeByScore(key, min, max)
+}
+
+// ZCountContext 统计分数范围内的成员数量（支持context）
+func (s *Store) ZCountContext(ctx context.Context, key string, min, max float64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.ZCount(key, min, max)
+}
+
+// EvalContext 执行已注册的脚本（支持context）
+func (s *Store) EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Eval(script, keys, args...)
+}
@@ -0,0 +1,830 @@
+package memory
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter"
+	"github.com/Fischlvor/go-ratelimiter/drivers/algorithm"
+)
+
+// 确保实现了 ratelimiter.Store 和 ratelimiter.ContextStore 接口
+var (
+	_ ratelimiter.Store        = (*Store)(nil)
+	_ ratelimiter.ContextStore = (*Store)(nil)
+)
+
+func TestMemoryStore_IncrAndGet(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	count, err := store.Incr("counter")
+	if err != nil {
+		t.Fatalf("Incr() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Incr() = %v, want 1", count)
+	}
+
+	count, err = store.IncrBy("counter", 5)
+	if err != nil {
+		t.Fatalf("IncrBy() error = %v", err)
+	}
+	if count != 6 {
+		t.Errorf("IncrBy() = %v, want 6", count)
+	}
+
+	val, err := store.Get("counter")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if val != 6 {
+		t.Errorf("Get() = %v, want 6", val)
+	}
+
+	// 不存在的键返回0
+	val, err = store.Get("nonexistent")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if val != 0 {
+		t.Errorf("Get() nonexistent = %v, want 0", val)
+	}
+}
+
+func TestMemoryStore_SetAndDel(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	if err := store.Set("key", 42); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if val, _ := store.Get("key"); val != 42 {
+		t.Errorf("Get() = %v, want 42", val)
+	}
+
+	if err := store.Del("key"); err != nil {
+		t.Fatalf("Del() error = %v", err)
+	}
+	if val, _ := store.Get("key"); val != 0 {
+		t.Errorf("删除后 Get() = %v, want 0", val)
+	}
+}
+
+func TestMemoryStore_ExpireAndTTL(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	// 键不存在
+	if ttl, _ := store.TTL("missing"); ttl != -2*time.Second {
+		t.Errorf("TTL() missing = %v, want -2s", ttl)
+	}
+
+	store.Set("key", 1)
+	if ttl, _ := store.TTL("key"); ttl != -1*time.Second {
+		t.Errorf("TTL() 未设置过期 = %v, want -1s", ttl)
+	}
+
+	store.Expire("key", 50*time.Millisecond)
+	ttl, _ := store.TTL("key")
+	if ttl <= 0 || ttl > 50*time.Millisecond {
+		t.Errorf("TTL() = %v, want (0, 50ms]", ttl)
+	}
+
+	// Incr 保留过期时间
+	store.Incr("key")
+	if ttl, _ := store.TTL("key"); ttl <= 0 {
+		t.Errorf("Incr后 TTL() = %v, 应保留过期时间", ttl)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if val, _ := store.Get("key"); val != 0 {
+		t.Errorf("过期后 Get() = %v, want 0", val)
+	}
+
+	// Set 清除过期时间
+	store.Set("key2", 1)
+	store.Expire("key2", time.Second)
+	store.Set("key2", 2)
+	if ttl, _ := store.TTL("key2"); ttl != -1*time.Second {
+		t.Errorf("Set后 TTL() = %v, want -1s", ttl)
+	}
+}
+
+func TestMemoryStore_ZSetOperations(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	key := "zset"
+	for i := 1; i <= 5; i++ {
+		if err := store.ZAdd(key, float64(i), string(rune('a'+i))); err != nil {
+			t.Fatalf("ZAdd() error = %v", err)
+		}
+	}
+
+	count, err := store.ZCount(key, 2, 4)
+	if err != nil {
+		t.Fatalf("ZCount() error = %v", err)
+	}
+	if count != 3 {
+		t.Errorf("ZCount() = %v, want 3", count)
+	}
+
+	if err := store.ZRemRangeByScore(key, 0, 2); err != nil {
+		t.Fatalf("ZRemRangeByScore() error = %v", err)
+	}
+
+	count, _ = store.ZCount(key, 0, 10)
+	if count != 3 {
+		t.Errorf("删除后 ZCount() = %v, want 3", count)
+	}
+
+	// 删除全部成员后键不再存在
+	store.ZRemRangeByScore(key, 0, 10)
+	if ttl, _ := store.TTL(key); ttl != -2*time.Second {
+		t.Errorf("空集合应被删除, TTL() = %v", ttl)
+	}
+}
+
+func TestMemoryStore_ZRange(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	scores := []float64{5, 3, 9, 1, 7, 3, 2, 8, 6, 4, 10, 0}
+	for i, score := range scores {
+		store.ZAdd("zset", score, string(rune('a'+i)))
+	}
+
+	type call struct{ start, stop int64 }
+	store.RegisterScript("zrange", func(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+		c := args[0].(call)
+		return tx.ZRangeWithScores(keys[0], c.start, c.stop)
+	})
+	store.RegisterScript("zrangebyscore", func(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+		return tx.ZRangeByScore(keys[0], args[0].(float64), args[1].(float64))
+	})
+
+	// 只取前几个成员和排序整个集合的结果一致，分数相同时按成员名排序
+	all, _ := store.Eval("zrange", []string{"zset"}, call{0, -1})
+	full := all.([]Z)
+	if len(full) != len(scores) || full[3].Member != "b" || full[4].Member != "f" {
+		t.Fatalf("ZRangeWithScores(0, -1) = %v", full)
+	}
+	for _, c := range []call{{0, 0}, {0, 4}, {2, 5}, {-3, -1}, {5, 100}} {
+		got, err := store.Eval("zrange", []string{"zset"}, c)
+		if err != nil {
+			t.Fatalf("ZRangeWithScores(%d, %d) error = %v", c.start, c.stop, err)
+		}
+		start, stop := c.start, c.stop
+		if start < 0 {
+			start += int64(len(full))
+		}
+		if stop < 0 {
+			stop += int64(len(full))
+		}
+		stop = min(stop, int64(len(full))-1)
+		want := full[start : stop+1]
+		members := got.([]Z)
+		if len(members) != len(want) {
+			t.Fatalf("ZRangeWithScores(%d, %d) = %v, want %v", c.start, c.stop, members, want)
+		}
+		for i := range want {
+			if members[i] != want[i] {
+				t.Errorf("ZRangeWithScores(%d, %d) = %v, want %v", c.start, c.stop, members, want)
+				break
+			}
+		}
+	}
+
+	got, _ := store.Eval("zrangebyscore", []string{"zset"}, 3.0, 5.0)
+	if members := got.([]Z); len(members) != 4 || members[0].Score != 3 || members[3].Score != 5 {
+		t.Errorf("ZRangeByScore(3, 5) = %v", members)
+	}
+}
+
+func TestMemoryStore_WrongType(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	store.ZAdd("zset", 1, "a")
+
+	if _, err := store.Incr("zset"); err != ErrWrongType {
+		t.Errorf("Incr() on zset error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.Get("zset"); err != ErrWrongType {
+		t.Errorf("Get() on zset error = %v, want ErrWrongType", err)
+	}
+
+	store.Set("num", 1)
+	if err := store.ZAdd("num", 1, "a"); err != ErrWrongType {
+		t.Errorf("ZAdd() on int error = %v, want ErrWrongType", err)
+	}
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	store := NewStore(10 * time.Millisecond)
+	defer store.Close()
+
+	store.Set("a", 1)
+	store.Expire("a", 5*time.Millisecond)
+	store.Set("b", 1)
+
+	time.Sleep(50 * time.Millisecond)
+
+	store.mu.Lock()
+	_, exists := store.items["a"]
+	store.mu.Unlock()
+	if exists {
+		t.Error("过期键应被后台清理")
+	}
+	if store.Len() != 1 {
+		t.Errorf("Len() = %v, want 1", store.Len())
+	}
+}
+
+func TestMemoryStore_Eval(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	// 未注册的脚本
+	if _, err := store.Eval("return 1", nil); err != ErrScriptNotSupported {
+		t.Errorf("Eval() error = %v, want ErrScriptNotSupported", err)
+	}
+
+	// 注册自定义脚本
+	store.RegisterScript("return 1", func(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+		return int64(1), nil
+	})
+	result, err := store.Eval("return 1", nil)
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	if result.(int64) != 1 {
+		t.Errorf("Eval() = %v, want 1", result)
+	}
+}
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	limiter := algorithm.NewTokenBucketLimiter(store)
+
+	// 容量5，速率很低，前5次允许，第6次拒绝
+	for i := 0; i < 5; i++ {
+		result, err := limiter.Allow("bucket", 5, 0.001)
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("第%d次请求应该允许", i+1)
+		}
+		if result.Remaining != int64(4-i) {
+			t.Errorf("第%d次 Remaining = %v, want %v", i+1, result.Remaining, 4-i)
+		}
+	}
+
+	result, err := limiter.Allow("bucket", 5, 0.001)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed {
+		t.Error("超出容量应该拒绝")
+	}
+	if result.RetryAfter <= 0 {
+		t.Errorf("RetryAfter = %v, want > 0", result.RetryAfter)
+	}
+}
+
+func TestMemoryStore_LeakyBucket(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	limiter := algorithm.NewLeakyBucketLimiter(store)
+
+	// 容量3，速率很低，前3次允许，第4次拒绝
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow("leaky", 3, 0.001)
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("第%d次请求应该允许", i+1)
+		}
+		if result.Remaining != int64(2-i) {
+			t.Errorf("第%d次 Remaining = %v, want %v", i+1, result.Remaining, 2-i)
+		}
+	}
+	result, err := limiter.Allow("leaky", 3, 0.001)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed || result.RetryAfter <= 0 {
+		t.Errorf("桶满应该拒绝，result = %+v", result)
+	}
+
+	// 速率每秒100个，10毫秒漏出1个
+	for i := 0; i < 2; i++ {
+		limiter.Allow("fast", 2, 100)
+	}
+	if result, _ := limiter.Allow("fast", 2, 100); result.Allowed {
+		t.Error("桶满应该拒绝")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if result, _ := limiter.Allow("fast", 2, 100); !result.Allowed {
+		t.Error("漏出后应该允许")
+	}
+}
+
+func TestMemoryStore_LeakyBucketQueue(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	limiter := algorithm.NewLeakyBucketLimiter(store)
+
+	// 队列容量3，速率每秒10个：依次等待0、100、200毫秒，第4个拒绝
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Queue("queue", 3, 10)
+		if err != nil {
+			t.Fatalf("Queue() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("第%d次请求应该允许", i+1)
+		}
+		want := time.Duration(i) * 100 * time.Millisecond
+		if result.Delay < want-20*time.Millisecond || result.Delay > want {
+			t.Errorf("第%d次 Delay = %v, want ~%v", i+1, result.Delay, want)
+		}
+		if result.Remaining != int64(2-i) {
+			t.Errorf("第%d次 Remaining = %v, want %v", i+1, result.Remaining, 2-i)
+		}
+	}
+
+	result, err := limiter.Queue("queue", 3, 10)
+	if err != nil {
+		t.Fatalf("Queue() error = %v", err)
+	}
+	if result.Allowed || result.Delay != 0 || result.RetryAfter <= 0 {
+		t.Errorf("队列已满应该拒绝，result = %+v", result)
+	}
+}
+
+func TestMemoryStore_GCRA(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	limiter := algorithm.NewGCRALimiter(store)
+
+	// 突发容量3，速率每秒20个（间隔50毫秒）
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow("gcra", 3, 20)
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("第%d次请求应该允许", i+1)
+		}
+		if result.Remaining != int64(2-i) {
+			t.Errorf("第%d次 Remaining = %v, want %v", i+1, result.Remaining, 2-i)
+		}
+	}
+
+	result, err := limiter.Allow("gcra", 3, 20)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed || result.RetryAfterMillis <= 0 || result.RetryAfterMillis > 50 {
+		t.Fatalf("超出突发容量应该拒绝，result = %+v", result)
+	}
+
+	// 只存储一个带过期时间的整数
+	if ttl, _ := store.TTL("gcra"); ttl <= 0 || ttl > 150*time.Millisecond {
+		t.Errorf("TTL = %v, want (0, 150ms]", ttl)
+	}
+
+	time.Sleep(time.Duration(result.RetryAfterMillis) * time.Millisecond)
+	if result, _ := limiter.Allow("gcra", 3, 20); !result.Allowed {
+		t.Error("等待RetryAfterMillis后应该允许")
+	}
+}
+
+func TestMemoryStore_WeightedCost(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	// 滑动窗口每个请求只记录一个成员，配额总量单独计数
+	sliding := algorithm.NewSlidingWindowLimiter(store)
+	if result, _ := sliding.AllowN("sliding", 10, time.Minute, 7); !result.Allowed || result.Remaining != 3 {
+		t.Fatalf("AllowN(7) = %+v", result)
+	}
+	if count, _ := store.ZCount("{sliding}", math.Inf(-1), math.Inf(1)); count != 1 {
+		t.Errorf("成员数 = %d, want 1", count)
+	}
+	if total, _ := store.Get("{sliding}:total"); total != 7 {
+		t.Errorf("配额总量 = %d, want 7", total)
+	}
+	if result, _ := sliding.AllowN("sliding", 10, time.Minute, 4); result.Allowed {
+		t.Error("超出剩余配额应该拒绝")
+	}
+
+	// 滑出窗口的记录从总量中扣除
+	if result, _ := sliding.AllowN("short", 5, 50*time.Millisecond, 4); !result.Allowed {
+		t.Fatalf("AllowN(4) = %+v", result)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if result, _ := sliding.AllowN("short", 5, 50*time.Millisecond, 5); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("AllowN(5) = %+v, want allowed remaining=0", result)
+	}
+	if total, _ := store.Get("{short}:total"); total != 5 {
+		t.Errorf("配额总量 = %d, want 5", total)
+	}
+
+	// 漏桶队列中消耗n个配额的请求占用n个间隔
+	queue := algorithm.NewLeakyBucketLimiter(store)
+	if result, _ := queue.QueueN("queue", 5, 10, 3); !result.Allowed || result.Delay != 0 || result.Remaining != 2 {
+		t.Fatalf("QueueN(3) = %+v", result)
+	}
+	result, _ := queue.QueueN("queue", 5, 10, 2)
+	if !result.Allowed || result.Delay < 250*time.Millisecond || result.Delay > 300*time.Millisecond {
+		t.Fatalf("QueueN(2) = %+v, want delay ~300ms", result)
+	}
+	if result, _ := queue.QueueN("queue", 5, 10, 1); result.Allowed {
+		t.Error("队列已满应该拒绝")
+	}
+
+	// 固定窗口被拒绝的请求不计入窗口
+	fixed := algorithm.NewFixedWindowLimiter(store)
+	if result, _ := fixed.AllowN("fixed", 10, time.Minute, 8); !result.Allowed || result.Remaining != 2 {
+		t.Fatalf("AllowN(8) = %+v", result)
+	}
+	if result, _ := fixed.AllowN("fixed", 10, time.Minute, 3); result.Allowed {
+		t.Error("超出剩余配额应该拒绝")
+	}
+	if count, _ := store.Get("fixed"); count != 8 {
+		t.Errorf("计数 = %d, want 8", count)
+	}
+}
+
+func TestMemoryStore_Refund(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	// 固定窗口：退还后计数减少，计数归零时保留键和过期时间，窗口边界不变
+	fixed := algorithm.NewFixedWindowLimiter(store)
+	first, _ := fixed.AllowN("fixed", 5, time.Minute, 2)
+	second, _ := fixed.AllowN("fixed", 5, time.Minute, 3)
+	if refunded, err := fixed.Refund("fixed", time.Minute, 3, second.RefundToken); err != nil || !refunded {
+		t.Fatalf("Refund() = %v, %v", refunded, err)
+	}
+	if count, _ := store.Get("fixed"); count != 2 {
+		t.Errorf("计数 = %d, want 2", count)
+	}
+	fixed.Refund("fixed", time.Minute, 2, first.RefundToken)
+	if count, _ := store.Get("fixed"); count != 0 {
+		t.Errorf("计数 = %d, want 0", count)
+	}
+	if ttl, _ := store.TTL("fixed"); ttl <= 0 {
+		t.Errorf("计数归零后应保留过期时间, TTL = %v", ttl)
+	}
+	// 凭证为窗口结束时间，由当前时间加剩余时间得出，可能有1毫秒左右的偏差
+	third, _ := fixed.Allow("fixed", 5, time.Minute)
+	thirdEnd, _ := strconv.ParseInt(third.RefundToken, 10, 64)
+	firstEnd, _ := strconv.ParseInt(first.RefundToken, 10, 64)
+	if diff := thirdEnd - firstEnd; diff < -5 || diff > 5 {
+		t.Errorf("退还后的请求应仍在原窗口, token = %s, want %s", third.RefundToken, first.RefundToken)
+	}
+
+	// 固定窗口：已进入新窗口时不退还
+	old, _ := fixed.Allow("window", 5, 100*time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+	store.Del("window")
+	fixed.Allow("window", 5, 100*time.Millisecond)
+	if refunded, _ := fixed.Refund("window", 100*time.Millisecond, 1, old.RefundToken); refunded {
+		t.Error("新窗口不应退还上一窗口的配额")
+	}
+	if count, _ := store.Get("window"); count != 1 {
+		t.Errorf("计数 = %d, want 1", count)
+	}
+
+	// 固定窗口：窗口自然结束后，上一窗口的凭证不能退还新窗口的配额
+	prev, _ := fixed.Allow("rollover", 5, 50*time.Millisecond)
+	time.Sleep(70 * time.Millisecond)
+	fixed.AllowN("rollover", 5, 50*time.Millisecond, 2)
+	if refunded, _ := fixed.Refund("rollover", 50*time.Millisecond, 1, prev.RefundToken); refunded {
+		t.Error("窗口结束后不应退还上一窗口的配额")
+	}
+	if count, _ := store.Get("rollover"); count != 2 {
+		t.Errorf("计数 = %d, want 2", count)
+	}
+
+	// 滑动窗口：只删除本次请求记录的成员
+	sliding := algorithm.NewSlidingWindowLimiter(store)
+	sliding.Allow("sliding", 5, time.Minute)
+	result, _ := sliding.AllowN("sliding", 5, time.Minute, 3)
+	if refunded, err := sliding.Refund("sliding", 3, result.RefundToken); err != nil || !refunded {
+		t.Fatalf("Refund() = %v, %v", refunded, err)
+	}
+	if count, _ := store.ZCount("{sliding}", math.Inf(-1), math.Inf(1)); count != 1 {
+		t.Errorf("成员数 = %d, want 1", count)
+	}
+	if total, _ := store.Get("{sliding}:total"); total != 1 {
+		t.Errorf("配额总量 = %d, want 1", total)
+	}
+	if refunded, _ := sliding.Refund("sliding", 3, result.RefundToken); refunded {
+		t.Error("重复退还不应删除成员")
+	}
+
+	// 令牌桶：退还后不超过桶容量
+	bucket := algorithm.NewTokenBucketLimiter(store)
+	bucket.AllowN("bucket", 5, 0.001, 2)
+	if refunded, err := bucket.Refund("bucket", 5, 3); err != nil || !refunded {
+		t.Fatalf("Refund() = %v, %v", refunded, err)
+	}
+	if result, _ := bucket.Peek("bucket", 5, 0.001); result.Remaining != 5 {
+		t.Errorf("Remaining = %d, want 5", result.Remaining)
+	}
+	if refunded, _ := bucket.Refund("missing", 5, 1); refunded {
+		t.Error("桶不存在时不应退还")
+	}
+}
+
+func TestMemoryStore_Peek(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	// 滑动窗口预检不记录请求，拒绝时按需要滑出窗口的记录计算重置时间
+	sliding := algorithm.NewSlidingWindowLimiter(store)
+	if result, _ := sliding.AllowN("sliding", 5, time.Minute, 4); !result.Allowed {
+		t.Fatal("AllowN(4) 应该允许")
+	}
+	if result, _ := sliding.PeekN("sliding", 5, time.Minute, 1); !result.Allowed || result.Remaining != 1 {
+		t.Errorf("PeekN(1) = %+v, want allowed remaining=1", result)
+	}
+	if result, _ := sliding.PeekN("sliding", 5, time.Minute, 3); result.Allowed || result.RetryAfter < 1 {
+		t.Errorf("PeekN(3) = %+v, want denied", result)
+	}
+	if total, _ := store.Get("{sliding}:total"); total != 4 {
+		t.Errorf("配额总量 = %d, want 4", total)
+	}
+
+	// 漏桶队列预检不入队，返回入队后需要等待的时间
+	queue := algorithm.NewLeakyBucketLimiter(store)
+	if result, _ := queue.Queue("queue", 3, 10); !result.Allowed {
+		t.Fatal("Queue() 应该允许")
+	}
+	for i := 0; i < 2; i++ {
+		result, _ := queue.PeekQueue("queue", 3, 10)
+		if !result.Allowed || result.Remaining != 2 || result.Delay <= 0 || result.Delay > 100*time.Millisecond {
+			t.Errorf("PeekQueue() = %+v", result)
+		}
+	}
+
+	// 并发限制预检不占用名额
+	concurrency := algorithm.NewConcurrencyLimiter(store)
+	for i := 0; i < 3; i++ {
+		if result, _ := concurrency.Peek("jobs", 1, time.Minute); !result.Allowed {
+			t.Fatal("Peek() 应该允许")
+		}
+	}
+	if result, _ := concurrency.Acquire("jobs", 1, time.Minute); !result.Allowed {
+		t.Fatal("Acquire() 应该允许")
+	}
+	if result, _ := concurrency.Peek("jobs", 1, time.Minute); result.Allowed || result.Remaining != 0 {
+		t.Errorf("Peek() = %+v, want denied", result)
+	}
+
+	// 预检不会创建键
+	gcra := algorithm.NewGCRALimiter(store)
+	if result, _ := gcra.Peek("gcra", 3, 10); !result.Allowed || result.Remaining != 3 {
+		t.Errorf("Peek() = %+v, want allowed remaining=3", result)
+	}
+	if store.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", store.Len())
+	}
+}
+
+func TestMemoryStore_Concurrency(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	limiter := algorithm.NewConcurrencyLimiter(store)
+
+	// 最多2个并发
+	first, err := limiter.Acquire("jobs", 2, time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	second, err := limiter.Acquire("jobs", 2, time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if !first.Allowed || !second.Allowed || second.Remaining != 0 {
+		t.Fatalf("前2个请求应该允许, first = %+v, second = %+v", first, second)
+	}
+
+	result, err := limiter.Acquire("jobs", 2, time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if result.Allowed || result.LeaseID != "" {
+		t.Fatalf("超出并发数应该拒绝, result = %+v", result)
+	}
+
+	// 释放后空出名额，重复释放返回false
+	if released, err := limiter.Release("jobs", first.LeaseID); err != nil || !released {
+		t.Errorf("Release() = %v, %v, want true", released, err)
+	}
+	if released, _ := limiter.Release("jobs", first.LeaseID); released {
+		t.Error("重复释放应该返回false")
+	}
+	if result, _ := limiter.Acquire("jobs", 2, time.Minute); !result.Allowed {
+		t.Error("释放后应该允许")
+	}
+}
+
+func TestMemoryStore_ConcurrencyLeaseExpiry(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	limiter := algorithm.NewConcurrencyLimiter(store)
+
+	// 持有者未释放（如实例崩溃），租约到期后名额自动回收
+	if result, _ := limiter.Acquire("jobs", 1, 50*time.Millisecond); !result.Allowed {
+		t.Fatal("第1个请求应该允许")
+	}
+	if result, _ := limiter.Acquire("jobs", 1, 50*time.Millisecond); result.Allowed {
+		t.Fatal("租约到期前应该拒绝")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if result, _ := limiter.Acquire("jobs", 1, 50*time.Millisecond); !result.Allowed {
+		t.Error("租约到期后应该允许")
+	}
+}
+
+func TestMemoryStore_SlidingWindowCounter(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	limiter := algorithm.NewSlidingWindowCounterLimiter(store)
+
+	for i := 0; i < 5; i++ {
+		result, err := limiter.Allow("counter", 5, time.Hour)
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("第%d次请求应该允许", i+1)
+		}
+		if result.Remaining != int64(4-i) {
+			t.Errorf("第%d次 Remaining = %v, want %v", i+1, result.Remaining, 4-i)
+		}
+	}
+
+	result, err := limiter.Allow("counter", 5, time.Hour)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed || result.RetryAfter <= 0 {
+		t.Errorf("超出限制应该拒绝，result = %+v", result)
+	}
+
+	// 无论请求多少，每个key只保存当前窗口的一个计数
+	if n := store.Len(); n != 1 {
+		t.Errorf("Len() = %d, want 1", n)
+	}
+}
+
+func TestMemoryStore_SlidingWindowCounterWeighted(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	limiter := algorithm.NewSlidingWindowCounterLimiter(store)
+	window := 200 * time.Millisecond
+
+	// 等到窗口刚开始时填满配额
+	windowMillis := window.Milliseconds()
+	time.Sleep(time.Duration(windowMillis-time.Now().UnixMilli()%windowMillis) * time.Millisecond)
+	for i := 0; i < 4; i++ {
+		if result, _ := limiter.Allow("weighted", 4, window); !result.Allowed {
+			t.Fatalf("第%d次请求应该允许", i+1)
+		}
+	}
+
+	// 进入下一窗口开头，上一窗口计数的权重接近1，仍然拒绝
+	time.Sleep(window + 10*time.Millisecond)
+	if result, _ := limiter.Allow("weighted", 4, window); result.Allowed {
+		t.Error("下一窗口开头应该按上一窗口计数加权后拒绝")
+	}
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				store.Incr("counter")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if val, _ := store.Get("counter"); val != 5000 {
+		t.Errorf("Get() = %v, want 5000", val)
+	}
+}
+
+func BenchmarkMemoryStore_Incr(b *testing.B) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Incr("bench")
+	}
+}
+
+func TestMemoryStore_WindowAlgorithmsConcurrent(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	fixed := algorithm.NewFixedWindowLimiter(store)
+	sliding := algorithm.NewSlidingWindowLimiter(store)
+
+	tests := []struct {
+		name  string
+		allow func() (*algorithm.Context, error)
+	}{
+		{"fixed_window", func() (*algorithm.Context, error) { return fixed.Allow("fixed", 10, time.Minute) }},
+		{"sliding_window", func() (*algorithm.Context, error) { return sliding.Allow("sliding", 10, time.Minute) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			allowed := 0
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					result, err := tt.allow()
+					if err != nil {
+						t.Errorf("Allow() error = %v", err)
+						return
+					}
+					if result.Allowed {
+						mu.Lock()
+						allowed++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			// 原子脚本保证并发下恰好放行limit个请求
+			if allowed != 10 {
+				t.Errorf("并发放行数 = %d, want 10", allowed)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_SlidingWindowRetryAfter(t *testing.T) {
+	store := NewStore(time.Minute)
+	defer store.Close()
+
+	limiter := algorithm.NewSlidingWindowLimiter(store)
+
+	for i := 0; i < 2; i++ {
+		if result, _ := limiter.Allow("key", 2, 3*time.Second); !result.Allowed {
+			t.Fatalf("第%d次请求应该允许", i+1)
+		}
+	}
+
+	result, err := limiter.Allow("key", 2, 3*time.Second)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed {
+		t.Fatal("超出限制应该拒绝")
+	}
+	// 最早的请求在3秒后滑出窗口
+	if result.RetryAfter < 1 || result.RetryAfter > 3 {
+		t.Errorf("RetryAfter = %d, want 1~3", result.RetryAfter)
+	}
+
+	// 窗口过期后键被清理
+	if ttl, _ := store.TTL("{key}"); ttl <= 0 || ttl > 3*time.Second {
+		t.Errorf("TTL() = %v, want (0, 3s]", ttl)
+	}
+}
@@ -0,0 +1,215 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/algorithm"
+	"github.com/Fischlvor/go-ratelimiter/drivers/ban"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// parityCall 一次脚本调用
+type parityCall struct {
+	script string
+	keys   []string
+	args   []interface{}
+	// loose 结果中由服务端时钟计算的元素下标，两端允许存在少量偏差
+	loose []int
+}
+
+// parityCases 每个场景在全新的存储上依次执行，覆盖 builtinScripts 中的所有脚本
+func parityCases(now time.Time) map[string][]parityCall {
+	ms := now.UnixMilli()
+	ns := now.UnixNano()
+	window := int64(60000)
+	swKeys := []string{"{sw}", "{sw}:total"}
+	banKeys := []string{ban.BlacklistKey("ip", "1.1.1.1"), ban.RecordKey("ip", "1.1.1.1")}
+	index := []string{ban.IndexKey("ip")}
+
+	return map[string][]parityCall{
+		"fixed_window": {
+			{script: algorithm.FixedWindowScript, keys: []string{"fw"}, args: []interface{}{10, window, 3}, loose: []int{2, 3}},
+			{script: algorithm.FixedWindowScript, keys: []string{"fw"}, args: []interface{}{10, window, 8}, loose: []int{2, 3}},
+			{script: algorithm.FixedWindowPeekScript, keys: []string{"fw"}, loose: []int{1}},
+			{script: algorithm.FixedWindowRefundScript, keys: []string{"fw"}, args: []interface{}{2, ms + window, window}},
+			{script: algorithm.FixedWindowRefundScript, keys: []string{"fw"}, args: []interface{}{2, ms + 3*window, window}},
+			{script: algorithm.FixedWindowPeekScript, keys: []string{"fw"}, loose: []int{1}},
+		},
+		"sliding_window": {
+			{script: algorithm.SlidingWindowScript, keys: swKeys, args: []interface{}{5, ns - 2000, ns - window*1e6, "1-a:2", window, 2}},
+			{script: algorithm.SlidingWindowScript, keys: swKeys, args: []interface{}{5, ns - 1000, ns - window*1e6, "2-b:1", window, 1}},
+			{script: algorithm.SlidingWindowScript, keys: swKeys, args: []interface{}{5, ns, ns - window*1e6, "3-c:4", window, 4}},
+			{script: algorithm.SlidingWindowPeekScript, keys: swKeys, args: []interface{}{ns - window*1e6, 5, 4}},
+			{script: algorithm.SlidingWindowPeekScript, keys: swKeys, args: []interface{}{ns - window*1e6, 5, 9}},
+			{script: algorithm.SlidingWindowRefundScript, keys: swKeys, args: []interface{}{"1-a:2"}},
+			{script: algorithm.SlidingWindowRefundScript, keys: swKeys, args: []interface{}{"1-a:2"}},
+			{script: algorithm.SlidingWindowPeekScript, keys: swKeys, args: []interface{}{ns - window*1e6, 5, 4}},
+		},
+		"sliding_window_counter": {
+			{script: algorithm.SlidingWindowCounterScript, keys: []string{"{swc}:99", "{swc}:98"}, args: []interface{}{10, window, 59000, 6}},
+			{script: algorithm.SlidingWindowCounterScript, keys: []string{"{swc}:100", "{swc}:99"}, args: []interface{}{10, window, 30000, 2}},
+			{script: algorithm.SlidingWindowCounterScript, keys: []string{"{swc}:100", "{swc}:99"}, args: []interface{}{10, window, 30000, 5}},
+			{script: algorithm.SlidingWindowCounterPeekScript, keys: []string{"{swc}:100", "{swc}:99"}, args: []interface{}{10, window, 45000, 4}},
+		},
+		"token_bucket": {
+			{script: algorithm.TokenBucketScript, keys: []string{"tb"}, args: []interface{}{10, 1.5, now.Unix(), 4}},
+			{script: algorithm.TokenBucketScript, keys: []string{"tb"}, args: []interface{}{10, 1.5, now.Unix(), 7}},
+			{script: algorithm.TokenBucketPeekScript, keys: []string{"tb"}, args: []interface{}{10, 1.5, now.Unix() + 2, 1}},
+			{script: algorithm.TokenBucketRefundScript, keys: []string{"tb"}, args: []interface{}{10, 3}},
+			{script: algorithm.TokenBucketRefundScript, keys: []string{"tb"}, args: []interface{}{10, 5}},
+			{script: algorithm.TokenBucketRefundScript, keys: []string{"missing"}, args: []interface{}{10, 5}},
+		},
+		"leaky_bucket": {
+			{script: algorithm.LeakyBucketScript, keys: []string{"lb"}, args: []interface{}{5, 0.5, ms, 3}},
+			{script: algorithm.LeakyBucketScript, keys: []string{"lb"}, args: []interface{}{5, 0.5, ms + 1000, 3}},
+			{script: algorithm.LeakyBucketPeekScript, keys: []string{"lb"}, args: []interface{}{5, 0.5, ms + 3000, 1}},
+		},
+		"leaky_bucket_queue": {
+			{script: algorithm.LeakyBucketQueueScript, keys: []string{"lq"}, args: []interface{}{3, 100.0 / 3, ms, 1}},
+			{script: algorithm.LeakyBucketQueueScript, keys: []string{"lq"}, args: []interface{}{3, 100.0 / 3, ms + 10, 2}},
+			{script: algorithm.LeakyBucketQueueScript, keys: []string{"lq"}, args: []interface{}{3, 100.0 / 3, ms + 20, 1}},
+			{script: algorithm.LeakyBucketQueuePeekScript, keys: []string{"lq"}, args: []interface{}{3, 100.0 / 3, ms + 50, 1}},
+		},
+		"gcra": {
+			{script: algorithm.GCRAScript, keys: []string{"gcra"}, args: []interface{}{100000, 3, now.UnixMicro(), 2}},
+			{script: algorithm.GCRAScript, keys: []string{"gcra"}, args: []interface{}{100000, 3, now.UnixMicro() + 10, 2}},
+			{script: algorithm.GCRAPeekScript, keys: []string{"gcra"}, args: []interface{}{100000, 3, now.UnixMicro() + 150000, 1}},
+		},
+		"concurrency": {
+			{script: algorithm.ConcurrencyAcquireScript, keys: []string{"cc"}, args: []interface{}{2, ms - 10, ms + 100, "l0", 110}},
+			{script: algorithm.ConcurrencyAcquireScript, keys: []string{"cc"}, args: []interface{}{2, ms, ms + window, "l1", window}},
+			{script: algorithm.ConcurrencyAcquireScript, keys: []string{"cc"}, args: []interface{}{2, ms + 1, ms + window + 1, "l2", window}},
+			{script: algorithm.ConcurrencyPeekScript, keys: []string{"cc"}, args: []interface{}{2, ms + 200}},
+			{script: algorithm.ConcurrencyAcquireScript, keys: []string{"cc"}, args: []interface{}{2, ms + 200, ms + window + 200, "l3", window}},
+			{script: algorithm.ConcurrencyReleaseScript, keys: []string{"cc"}, args: []interface{}{"l1"}},
+			{script: algorithm.ConcurrencyReleaseScript, keys: []string{"cc"}, args: []interface{}{"l1"}},
+			{script: algorithm.ConcurrencyRenewScript, keys: []string{"cc"}, args: []interface{}{"l2", ms + 200, ms + 2*window, 2 * window}},
+			{script: algorithm.ConcurrencyRenewScript, keys: []string{"cc"}, args: []interface{}{"l1", ms + 200, ms + 2*window, 2 * window}},
+			{script: algorithm.ConcurrencyRenewScript, keys: []string{"cc"}, args: []interface{}{"l2", ms + 3*window, ms + 4*window, window}},
+			{script: algorithm.ConcurrencyAcquireScript, keys: []string{"cc"}, args: []interface{}{2, ms + window + 500, ms + 2*window, "l4", window}},
+			{script: algorithm.ConcurrencyPeekScript, keys: []string{"cc"}, args: []interface{}{2, ms + 200}},
+		},
+		"ban": {
+			{script: ban.BanScript, keys: banKeys, args: []interface{}{ms, ms + window, window, "刷接口", ban.SourceManual}},
+			{script: ban.IndexAddScript, keys: index, args: []interface{}{"1.1.1.1", ms, strconv.FormatInt(ms+window, 10)}},
+			{script: ban.IndexAddScript, keys: index, args: []interface{}{"2.2.2.2", ms, "+inf"}},
+			{script: ban.IndexAddScript, keys: index, args: []interface{}{"3.3.3.3", ms - 10, strconv.FormatInt(ms-5, 10)}},
+			{script: ban.ListScript, keys: index, args: []interface{}{ms, 0, 10}},
+			{script: ban.ListScript, keys: index, args: []interface{}{ms, 1, 1}},
+			{script: ban.RecordScript, keys: banKeys[1:]},
+			{script: ban.RecordScript, keys: []string{ban.RecordKey("ip", "9.9.9.9")}},
+			{script: ban.UnbanScript, keys: banKeys},
+			{script: ban.UnbanScript, keys: banKeys},
+			{script: ban.IndexRemoveScript, keys: index, args: []interface{}{"1.1.1.1", ms}},
+			{script: ban.ListScript, keys: index, args: []interface{}{ms, 0, 10}},
+		},
+	}
+}
+
+// TestScriptParity 在miniredis中执行Lua原文，与Go实现的结果逐次比较，避免两者不一致
+func TestScriptParity(t *testing.T) {
+	cases := parityCases(time.Now())
+
+	covered := map[string]bool{}
+	for _, calls := range cases {
+		for _, call := range calls {
+			covered[call.script] = true
+		}
+	}
+	for script := range builtinScripts {
+		if !covered[script] {
+			t.Errorf("脚本没有一致性测试用例:\n%s", script)
+		}
+	}
+
+	for name, calls := range cases {
+		t.Run(name, func(t *testing.T) {
+			store := NewStore(0)
+			defer store.Close()
+			client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			defer client.Close()
+
+			for i, call := range calls {
+				want, err := client.Eval(context.Background(), call.script, call.keys, call.args...).Result()
+				if err != nil && err != redis.Nil {
+					t.Fatalf("第%d次调用 Lua error = %v", i+1, err)
+				}
+				got, err := store.Eval(call.script, call.keys, call.args...)
+				if err != nil {
+					t.Fatalf("第%d次调用 Go error = %v", i+1, err)
+				}
+				if msg := compareResult(got, want, call.loose); msg != "" {
+					t.Errorf("第%d次调用结果不一致: %s\nGo  = %#v\nLua = %#v", i+1, msg, got, want)
+				}
+			}
+		})
+	}
+}
+
+// compareResult 比较脚本返回值：类型必须一致，数值字符串按数值比较（两端的浮点数格式化方式不同）
+// loose 中的下标允许相差100毫秒以内
+func compareResult(got, want interface{}, loose []int) string {
+	gotValues, gotArray := got.([]interface{})
+	wantValues, wantArray := want.([]interface{})
+	if gotArray != wantArray {
+		return "类型不同"
+	}
+	if !gotArray {
+		return compareValue(got, want, len(loose) > 0)
+	}
+	if len(gotValues) != len(wantValues) {
+		return fmt.Sprintf("长度 %d != %d", len(gotValues), len(wantValues))
+	}
+	for i := range gotValues {
+		isLoose := false
+		for _, j := range loose {
+			isLoose = isLoose || i == j
+		}
+		if msg := compareValue(gotValues[i], wantValues[i], isLoose); msg != "" {
+			return fmt.Sprintf("[%d] %s", i, msg)
+		}
+	}
+	return ""
+}
+
+// compareValue 比较单个返回值
+func compareValue(got, want interface{}, loose bool) string {
+	if fmt.Sprintf("%T", got) != fmt.Sprintf("%T", want) {
+		return fmt.Sprintf("类型 %T != %T", got, want)
+	}
+	switch w := want.(type) {
+	case nil:
+		return ""
+	case int64:
+		if g := got.(int64); g != w && !(loose && math.Abs(float64(g-w)) <= 100) {
+			return fmt.Sprintf("%d != %d", g, w)
+		}
+		return ""
+	case string:
+		g := got.(string)
+		if g == w {
+			return ""
+		}
+		gf, gErr := strconv.ParseFloat(g, 64)
+		wf, wErr := strconv.ParseFloat(w, 64)
+		if gErr != nil || wErr != nil {
+			return fmt.Sprintf("%q != %q", g, w)
+		}
+		tolerance := math.Abs(wf) * 1e-12
+		if loose {
+			tolerance = 100
+		}
+		if math.Abs(gf-wf) > tolerance {
+			return fmt.Sprintf("%s != %s", g, w)
+		}
+		return ""
+	default:
+		return fmt.Sprintf("不支持的类型 %T", want)
+	}
+}
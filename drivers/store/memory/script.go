@@ -0,0 +1,1140 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/algorithm"
+	"github.com/Fischlvor/go-ratelimiter/drivers/ban"
+)
+
+// ScriptFunc 脚本的Go实现
+// 返回值需遵循Redis对Lua返回值的转换规则：整数为int64，数组为[]interface{}
+type ScriptFunc func(tx *Tx, keys []string, args []interface{}) (interface{}, error)
+
+// builtinScripts 内置的算法脚本实现（按脚本原文索引）
+var builtinScripts = map[string]ScriptFunc{
+	algorithm.FixedWindowScript:              fixedWindow,
+	algorithm.FixedWindowPeekScript:          fixedWindowPeek,
+	algorithm.SlidingWindowScript:            slidingWindow,
+	algorithm.SlidingWindowPeekScript:        slidingWindowPeek,
+	algorithm.TokenBucketScript:              tokenBucket,
+	algorithm.TokenBucketPeekScript:          tokenBucketPeek,
+	algorithm.LeakyBucketScript:              leakyBucket,
+	algorithm.LeakyBucketPeekScript:          leakyBucketPeek,
+	algorithm.LeakyBucketQueueScript:         leakyBucketQueue,
+	algorithm.LeakyBucketQueuePeekScript:     leakyBucketQueuePeek,
+	algorithm.GCRAScript:                     gcra,
+	algorithm.GCRAPeekScript:                 gcraPeek,
+	algorithm.SlidingWindowCounterScript:     slidingWindowCounter,
+	algorithm.SlidingWindowCounterPeekScript: slidingWindowCounterPeek,
+	algorithm.ConcurrencyAcquireScript:       concurrencyAcquire,
+	algorithm.ConcurrencyPeekScript:          concurrencyPeek,
+	algorithm.ConcurrencyReleaseScript:       concurrencyRelease,
+	algorithm.ConcurrencyRenewScript:         concurrencyRenew,
+	algorithm.FixedWindowRefundScript:        fixedWindowRefund,
+	algorithm.SlidingWindowRefundScript:      slidingWindowRefund,
+	algorithm.TokenBucketRefundScript:        tokenBucketRefund,
+	ban.BanScript:                            banScript,
+	ban.UnbanScript:                          unbanScript,
+	ban.IndexAddScript:                       banIndexAdd,
+	ban.IndexRemoveScript:                    banIndexRemove,
+	ban.ListScript:                           listBansScript,
+	ban.RecordScript:                         banRecordScript,
+}
+
+// fixedWindow 对应 algorithm.FixedWindowScript
+func fixedWindow(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 2 {
+		return nil, fmt.Errorf("固定窗口脚本参数不足")
+	}
+	key := keys[0]
+
+	limit, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	windowMillis, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	window := time.Duration(windowMillis) * time.Millisecond
+	cost, err := argCost(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := tx.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var allowed int64
+	if float64(count+cost) <= limit {
+		if count, err = tx.IncrBy(key, cost); err != nil {
+			return nil, err
+		}
+		if count == cost {
+			tx.Expire(key, window)
+		}
+		allowed = 1
+	}
+
+	ttl := tx.TTL(key)
+	if ttl < 0 {
+		if tx.Exists(key) {
+			tx.Expire(key, window)
+		}
+		ttl = window
+	}
+
+	windowEnd := tx.Now().Add(ttl).UnixMilli()
+	return []interface{}{allowed, count, ttl.Milliseconds(), windowEnd}, nil
+}
+
+// slidingWindow 对应 algorithm.SlidingWindowScript
+func slidingWindow(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 2 || len(args) < 5 {
+		return nil, fmt.Errorf("滑动窗口脚本参数不足")
+	}
+	key := keys[0]
+
+	limit, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	now, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	windowStart, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	member := ArgString(args[3])
+	windowMillis, err := ArgFloat(args[4])
+	if err != nil {
+		return nil, err
+	}
+	cost, err := argCost(args, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	// 删除窗口之外的记录，并从总量中扣除它们的配额
+	count, err := slidingWindowTotal(tx, keys, windowStart)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ZRemRangeByScore(key, 0, windowStart); err != nil {
+		return nil, err
+	}
+
+	var allowed int64
+	if float64(count+cost) <= limit {
+		if err := tx.ZAdd(key, now, member); err != nil {
+			return nil, err
+		}
+		count += cost
+		allowed = 1
+	}
+
+	if count > 0 {
+		window := time.Duration(windowMillis) * time.Millisecond
+		tx.Set(keys[1], count)
+		tx.Expire(keys[1], window)
+		tx.Expire(key, window)
+	} else {
+		tx.Del(keys[1])
+	}
+
+	// Redis以字符串返回分数，窗口为空时Lua的false转换为nil
+	var oldest interface{}
+	first, err := tx.ZRangeWithScores(key, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(first) == 1 {
+		oldest = FormatFloat(first[0].Score)
+	}
+
+	return []interface{}{allowed, count, oldest}, nil
+}
+
+// slidingWindowCounter 对应 algorithm.SlidingWindowCounterScript
+func slidingWindowCounter(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 2 || len(args) < 3 {
+		return nil, fmt.Errorf("滑动窗口计数器脚本参数不足")
+	}
+
+	limit, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	window, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	elapsed, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	cost, err := argCost(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := tx.Get(keys[0])
+	if err != nil {
+		return nil, err
+	}
+	previous, err := tx.Get(keys[1])
+	if err != nil {
+		return nil, err
+	}
+	estimated := float64(previous)*(window-elapsed)/window + float64(current)
+
+	var allowed int64
+	if estimated+float64(cost) <= limit {
+		if current, err = tx.IncrBy(keys[0], cost); err != nil {
+			return nil, err
+		}
+		if current == cost {
+			tx.Expire(keys[0], time.Duration(window*2)*time.Millisecond)
+		}
+		allowed = 1
+	}
+
+	return []interface{}{allowed, current, previous}, nil
+}
+
+// tokenBucket 对应 algorithm.TokenBucketScript
+func tokenBucket(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 4 {
+		return nil, fmt.Errorf("令牌桶脚本参数不足")
+	}
+	key := keys[0]
+
+	capacity, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	rate, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	now, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	requested, err := ArgFloat(args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取上次更新时间和当前令牌数
+	lastTime, err := hashFloat(tx, key, "last_time", now)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := hashFloat(tx, key, "tokens", capacity)
+	if err != nil {
+		return nil, err
+	}
+
+	// 计算新增的令牌数
+	delta := math.Max(0, now-lastTime)
+	newTokens := math.Min(capacity, tokens+delta*rate)
+
+	allowed := newTokens >= requested
+	remaining := newTokens
+	if allowed {
+		remaining = newTokens - requested
+	}
+
+	if err := tx.HSet(key, "tokens", FormatFloat(remaining)); err != nil {
+		return nil, err
+	}
+	if err := tx.HSet(key, "last_time", FormatFloat(now)); err != nil {
+		return nil, err
+	}
+	tx.Expire(key, time.Duration(math.Ceil(capacity/rate)+60)*time.Second)
+
+	return []interface{}{boolInt(allowed), int64(remaining), int64(capacity)}, nil
+}
+
+// leakyBucket 对应 algorithm.LeakyBucketScript
+func leakyBucket(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 4 {
+		return nil, fmt.Errorf("漏桶脚本参数不足")
+	}
+	key := keys[0]
+
+	capacity, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	rate, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	now, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	requested, err := ArgFloat(args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	// 按流出速率扣减自上次请求以来漏出的水量
+	level, err := hashFloat(tx, key, "level", 0)
+	if err != nil {
+		return nil, err
+	}
+	lastTime, err := hashFloat(tx, key, "last_time", now)
+	if err != nil {
+		return nil, err
+	}
+	leaked := math.Max(0, now-lastTime) * rate / 1000
+	level = math.Max(0, level-leaked)
+
+	var allowed int64
+	if level+requested <= capacity {
+		level += requested
+		allowed = 1
+	}
+
+	if err := tx.HSet(key, "level", FormatFloat(level)); err != nil {
+		return nil, err
+	}
+	if err := tx.HSet(key, "last_time", ArgString(args[2])); err != nil {
+		return nil, err
+	}
+	tx.Expire(key, time.Duration(math.Ceil(level/rate*1000)+1000)*time.Millisecond)
+
+	return []interface{}{allowed, FormatFloat(level)}, nil
+}
+
+// leakyBucketQueue 对应 algorithm.LeakyBucketQueueScript
+func leakyBucketQueue(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 3 {
+		return nil, fmt.Errorf("漏桶队列脚本参数不足")
+	}
+	key := keys[0]
+
+	capacity, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	interval, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	now, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	cost, err := argCost(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	tat, err := hashFloat(tx, key, "tat", now)
+	if err != nil {
+		return nil, err
+	}
+	start := math.Max(tat, now)
+	delay := start - now
+
+	var allowed int64
+	if delay+float64(cost-1)*interval < capacity*interval {
+		tat = start + interval*float64(cost)
+		if err := tx.HSet(key, "tat", FormatFloat(tat)); err != nil {
+			return nil, err
+		}
+		tx.Expire(key, time.Duration(math.Ceil(tat-now)+1000)*time.Millisecond)
+		allowed = 1
+	}
+
+	return []interface{}{allowed, FormatFloat(delay)}, nil
+}
+
+// gcra 对应 algorithm.GCRAScript
+func gcra(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 3 {
+		return nil, fmt.Errorf("GCRA脚本参数不足")
+	}
+	key := keys[0]
+
+	interval, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	burst, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	now, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	cost, err := argCost(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	tat := int64(now)
+	if tx.Exists(key) {
+		if tat, err = tx.Get(key); err != nil {
+			return nil, err
+		}
+	}
+	if tat < int64(now) {
+		tat = int64(now)
+	}
+
+	newTat := tat + int64(interval)*cost
+	if float64(newTat)-burst*interval > now {
+		return []interface{}{int64(0), tat}, nil
+	}
+
+	tx.Set(key, newTat)
+	tx.Expire(key, time.Duration(math.Ceil((float64(newTat)-now)/1000))*time.Millisecond)
+	return []interface{}{int64(1), newTat}, nil
+}
+
+// concurrencyAcquire 对应 algorithm.ConcurrencyAcquireScript
+func concurrencyAcquire(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 5 {
+		return nil, fmt.Errorf("并发限制脚本参数不足")
+	}
+	key := keys[0]
+
+	limit, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	now, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	lease, err := ArgFloat(args[4])
+	if err != nil {
+		return nil, err
+	}
+
+	// 清理已过期的租约
+	if _, err := tx.ZRemRangeByScore(key, math.Inf(-1), now); err != nil {
+		return nil, err
+	}
+
+	count, err := tx.ZCard(key)
+	if err != nil {
+		return nil, err
+	}
+	var allowed int64
+	if float64(count) < limit {
+		if err := tx.ZAdd(key, expiresAt, ArgString(args[3])); err != nil {
+			return nil, err
+		}
+		tx.Expire(key, time.Duration(lease)*time.Millisecond)
+		count++
+		allowed = 1
+	}
+
+	var earliest interface{}
+	first, err := tx.ZRangeWithScores(key, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(first) == 1 {
+		earliest = FormatFloat(first[0].Score)
+	}
+
+	return []interface{}{allowed, count, earliest}, nil
+}
+
+// concurrencyRelease 对应 algorithm.ConcurrencyReleaseScript
+func concurrencyRelease(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 1 {
+		return nil, fmt.Errorf("并发释放脚本参数不足")
+	}
+	return tx.ZRem(keys[0], ArgString(args[0]))
+}
+
+// concurrencyRenew 对应 algorithm.ConcurrencyRenewScript
+func concurrencyRenew(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 4 {
+		return nil, fmt.Errorf("并发续期脚本参数不足")
+	}
+	key, member := keys[0], ArgString(args[0])
+
+	now, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	lease, err := ArgFloat(args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	score, ok, err := tx.ZScore(key, member)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || score <= now {
+		return int64(0), nil
+	}
+
+	if err := tx.ZAdd(key, expiresAt, member); err != nil {
+		return nil, err
+	}
+	leaseTTL := time.Duration(lease) * time.Millisecond
+	if tx.TTL(key) < leaseTTL {
+		tx.Expire(key, leaseTTL)
+	}
+	return int64(1), nil
+}
+
+// fixedWindowRefund 对应 algorithm.FixedWindowRefundScript
+func fixedWindowRefund(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 3 {
+		return nil, fmt.Errorf("固定窗口退还脚本参数不足")
+	}
+	key := keys[0]
+
+	cost, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	windowEnd, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	windowMillis, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	// 键不存在或已不是消耗时所在的窗口时不退还
+	ttl := tx.TTL(key)
+	if ttl < 0 {
+		return int64(0), nil
+	}
+	currentEnd := float64(tx.Now().Add(ttl).UnixMilli())
+	if math.Abs(currentEnd-windowEnd)*2 >= windowMillis {
+		return int64(0), nil
+	}
+
+	count, err := tx.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	refund := min(int64(cost), count)
+	if refund <= 0 {
+		return int64(0), nil
+	}
+	if _, err := tx.IncrBy(key, -refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// slidingWindowRefund 对应 algorithm.SlidingWindowRefundScript
+func slidingWindowRefund(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 2 || len(args) < 1 {
+		return nil, fmt.Errorf("滑动窗口退还脚本参数不足")
+	}
+	member := ArgString(args[0])
+
+	removed, err := tx.ZRem(keys[0], member)
+	if err != nil || removed == 0 {
+		return int64(0), err
+	}
+	cost := memberCost(member)
+	total, err := tx.IncrBy(keys[1], -cost)
+	if err != nil {
+		return nil, err
+	}
+	if total <= 0 {
+		tx.Del(keys[1])
+	}
+	return cost, nil
+}
+
+// tokenBucketRefund 对应 algorithm.TokenBucketRefundScript
+func tokenBucketRefund(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 2 {
+		return nil, fmt.Errorf("令牌桶退还脚本参数不足")
+	}
+	key := keys[0]
+
+	capacity, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	requested, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok, err := tx.HGet(key, "tokens")
+	if err != nil || !ok {
+		return int64(0), err
+	}
+	tokens, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := math.Max(0, math.Min(requested, capacity-tokens))
+	if refund > 0 {
+		if err := tx.HSet(key, "tokens", FormatFloat(tokens+refund)); err != nil {
+			return nil, err
+		}
+	}
+	return int64(math.Ceil(refund)), nil
+}
+
+// fixedWindowPeek 对应 algorithm.FixedWindowPeekScript
+func fixedWindowPeek(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 {
+		return nil, fmt.Errorf("固定窗口只读脚本参数不足")
+	}
+	count, err := tx.Get(keys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// 与PTTL一致，键不存在返回-2，未设置过期返回-1
+	ttl := tx.TTL(keys[0])
+	ttlMillis := ttl.Milliseconds()
+	if ttl < 0 {
+		ttlMillis = int64(ttl / time.Second)
+	}
+	return []interface{}{count, ttlMillis}, nil
+}
+
+// slidingWindowPeek 对应 algorithm.SlidingWindowPeekScript
+func slidingWindowPeek(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 2 || len(args) < 3 {
+		return nil, fmt.Errorf("滑动窗口只读脚本参数不足")
+	}
+	windowStart, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	limit, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	cost, err := argCost(args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := slidingWindowTotal(tx, keys, windowStart)
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed int64
+	var need int64
+	if float64(count+cost) <= limit {
+		allowed = 1
+	} else {
+		need = count + cost - int64(limit)
+	}
+
+	// 按时间顺序累计滑出窗口释放的配额，找到足以容纳本次请求的那条记录
+	members, err := zrangeAbove(tx, keys[0], windowStart)
+	if err != nil {
+		return nil, err
+	}
+	var score interface{}
+	var freed int64
+	for _, m := range members {
+		freed += memberCost(m.Member)
+		if freed >= need {
+			score = FormatFloat(m.Score)
+			break
+		}
+	}
+	return []interface{}{allowed, count, score}, nil
+}
+
+// slidingWindowTotal 返回滑动窗口内的配额总量（扣除尚未清理的窗口外记录）
+func slidingWindowTotal(tx *Tx, keys []string, windowStart float64) (int64, error) {
+	size, err := tx.ZCard(keys[0])
+	if err != nil || size == 0 {
+		return 0, err
+	}
+	total, err := tx.Get(keys[1])
+	if err != nil {
+		return 0, err
+	}
+	expired, err := tx.ZRangeByScore(keys[0], 0, windowStart)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range expired {
+		total -= memberCost(m.Member)
+	}
+	if total < 0 || int64(len(expired)) == size {
+		total = 0
+	}
+	return total, nil
+}
+
+// memberCost 解析滑动窗口成员名末尾记录的配额数（与脚本中的 string.match(m, ':(%d+)$') 一致）
+func memberCost(member string) int64 {
+	i := strings.LastIndexByte(member, ':')
+	if i < 0 {
+		return 1
+	}
+	cost, err := strconv.ParseInt(member[i+1:], 10, 64)
+	if err != nil || cost < 0 {
+		return 1
+	}
+	return cost
+}
+
+// slidingWindowCounterPeek 对应 algorithm.SlidingWindowCounterPeekScript
+func slidingWindowCounterPeek(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 2 || len(args) < 3 {
+		return nil, fmt.Errorf("滑动窗口计数器只读脚本参数不足")
+	}
+	limit, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	window, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	elapsed, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	cost, err := argCost(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := tx.Get(keys[0])
+	if err != nil {
+		return nil, err
+	}
+	previous, err := tx.Get(keys[1])
+	if err != nil {
+		return nil, err
+	}
+	estimated := float64(previous)*(window-elapsed)/window + float64(current)
+
+	return []interface{}{boolInt(estimated+float64(cost) <= limit), current, previous}, nil
+}
+
+// tokenBucketPeek 对应 algorithm.TokenBucketPeekScript
+func tokenBucketPeek(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 4 {
+		return nil, fmt.Errorf("令牌桶只读脚本参数不足")
+	}
+	key := keys[0]
+
+	capacity, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	rate, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	now, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	requested, err := ArgFloat(args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	lastTime, err := hashFloat(tx, key, "last_time", now)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := hashFloat(tx, key, "tokens", capacity)
+	if err != nil {
+		return nil, err
+	}
+	newTokens := math.Min(capacity, tokens+math.Max(0, now-lastTime)*rate)
+
+	return []interface{}{boolInt(newTokens >= requested), int64(newTokens), int64(capacity)}, nil
+}
+
+// leakyBucketPeek 对应 algorithm.LeakyBucketPeekScript
+func leakyBucketPeek(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 4 {
+		return nil, fmt.Errorf("漏桶只读脚本参数不足")
+	}
+	key := keys[0]
+
+	capacity, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	rate, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	now, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	requested, err := ArgFloat(args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	level, err := hashFloat(tx, key, "level", 0)
+	if err != nil {
+		return nil, err
+	}
+	lastTime, err := hashFloat(tx, key, "last_time", now)
+	if err != nil {
+		return nil, err
+	}
+	level = math.Max(0, level-math.Max(0, now-lastTime)*rate/1000)
+
+	return []interface{}{boolInt(level+requested <= capacity), FormatFloat(level)}, nil
+}
+
+// leakyBucketQueuePeek 对应 algorithm.LeakyBucketQueuePeekScript
+func leakyBucketQueuePeek(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 3 {
+		return nil, fmt.Errorf("漏桶队列只读脚本参数不足")
+	}
+	capacity, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	interval, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	now, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	cost, err := argCost(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	tat, err := hashFloat(tx, keys[0], "tat", now)
+	if err != nil {
+		return nil, err
+	}
+	delay := math.Max(tat, now) - now
+
+	return []interface{}{boolInt(delay+float64(cost-1)*interval < capacity*interval), FormatFloat(delay)}, nil
+}
+
+// gcraPeek 对应 algorithm.GCRAPeekScript
+func gcraPeek(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 3 {
+		return nil, fmt.Errorf("GCRA只读脚本参数不足")
+	}
+	interval, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	burst, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	now, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	cost, err := argCost(args, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	tat := int64(now)
+	if tx.Exists(keys[0]) {
+		if tat, err = tx.Get(keys[0]); err != nil {
+			return nil, err
+		}
+	}
+	if tat < int64(now) {
+		tat = int64(now)
+	}
+
+	allowed := float64(tat)+interval*float64(cost)-burst*interval <= now
+	return []interface{}{boolInt(allowed), tat}, nil
+}
+
+// concurrencyPeek 对应 algorithm.ConcurrencyPeekScript
+func concurrencyPeek(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 2 {
+		return nil, fmt.Errorf("并发限制只读脚本参数不足")
+	}
+	limit, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	now, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	leases, err := zrangeAbove(tx, keys[0], now)
+	if err != nil {
+		return nil, err
+	}
+	count := int64(len(leases))
+
+	var earliest interface{}
+	if count > 0 {
+		earliest = FormatFloat(leases[0].Score)
+	}
+	return []interface{}{boolInt(float64(count) < limit), count, earliest}, nil
+}
+
+// banScript 对应 ban.BanScript
+func banScript(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 2 || len(args) < 5 {
+		return nil, fmt.Errorf("封禁脚本参数不足")
+	}
+	ttl, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	tx.Set(keys[0], 1)
+	tx.Del(keys[1])
+	fields := []string{"reason", "source", "created_at", "expires_at"}
+	values := []interface{}{args[3], args[4], args[0], args[1]}
+	for i, field := range fields {
+		if err := tx.HSet(keys[1], field, ArgString(values[i])); err != nil {
+			return nil, err
+		}
+	}
+
+	if ttl > 0 {
+		expiration := time.Duration(ttl) * time.Millisecond
+		tx.Expire(keys[0], expiration)
+		tx.Expire(keys[1], expiration)
+	}
+
+	return int64(1), nil
+}
+
+// unbanScript 对应 ban.UnbanScript
+func unbanScript(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 2 {
+		return nil, fmt.Errorf("解封脚本参数不足")
+	}
+	existed := tx.Exists(keys[0])
+	tx.Del(keys[0])
+	tx.Del(keys[1])
+	return boolInt(existed), nil
+}
+
+// banIndexAdd 对应 ban.IndexAddScript
+func banIndexAdd(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 3 {
+		return nil, fmt.Errorf("封禁索引脚本参数不足")
+	}
+	now, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	score, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	// 清理索引中已到期的成员
+	if _, err := tx.ZRemRangeByScore(keys[0], math.Inf(-1), now); err != nil {
+		return nil, err
+	}
+	if err := tx.ZAdd(keys[0], score, ArgString(args[0])); err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+}
+
+// banIndexRemove 对应 ban.IndexRemoveScript
+func banIndexRemove(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 2 {
+		return nil, fmt.Errorf("封禁索引脚本参数不足")
+	}
+	now, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ZRemRangeByScore(keys[0], math.Inf(-1), now); err != nil {
+		return nil, err
+	}
+	return tx.ZRem(keys[0], ArgString(args[0]))
+}
+
+// listBansScript 对应 ban.ListScript
+func listBansScript(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 || len(args) < 3 {
+		return nil, fmt.Errorf("封禁列表脚本参数不足")
+	}
+	now, err := ArgFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	offset, err := ArgFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	limit, err := ArgFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ZRemRangeByScore(keys[0], math.Inf(-1), now); err != nil {
+		return nil, err
+	}
+	members, err := tx.ZRangeWithScores(keys[0], int64(offset), int64(offset+limit)-1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]interface{}, len(members))
+	for i, member := range members {
+		result[i] = member.Member
+	}
+	return result, nil
+}
+
+// banRecordScript 对应 ban.RecordScript
+func banRecordScript(tx *Tx, keys []string, args []interface{}) (interface{}, error) {
+	if len(keys) < 1 {
+		return nil, fmt.Errorf("封禁详情脚本参数不足")
+	}
+	fields := []string{"reason", "source", "created_at", "expires_at"}
+	result := make([]interface{}, len(fields))
+	for i, field := range fields {
+		value, ok, err := tx.HGet(keys[0], field)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result[i] = value
+		}
+	}
+	return result, nil
+}
+
+// zrangeAbove 按分数升序返回分数大于min的成员（对应 ZRANGEBYSCORE key (min +inf）
+// 先按分数过滤再排序，只对窗口内的成员排序
+func zrangeAbove(tx *Tx, key string, min float64) ([]Z, error) {
+	return tx.ZRangeByScore(key, math.Nextafter(min, math.Inf(1)), math.Inf(1))
+}
+
+// hashFloat 读取哈希字段并转换为数值，字段不存在时返回默认值
+func hashFloat(tx *Tx, key, field string, def float64) (float64, error) {
+	value, ok, err := tx.HGet(key, field)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return def, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// argCost 读取可选的cost参数（与脚本中的 tonumber(ARGV[i] or 1) 一致）
+func argCost(args []interface{}, i int) (int64, error) {
+	if len(args) <= i {
+		return 1, nil
+	}
+	cost, err := ArgFloat(args[i])
+	if err != nil {
+		return 0, err
+	}
+	return int64(cost), nil
+}
+
+// ArgFloat 将脚本参数转换为数值（与Lua的tonumber一致）
+func ArgFloat(arg interface{}) (float64, error) {
+	switch v := arg.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	case bool:
+		// go-redis将bool编码为"1"/"0"
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("无效的脚本参数: %v", arg)
+	}
+}
+
+// ArgString 将脚本参数转换为字符串（与go-redis的参数编码一致）
+func ArgString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case float64:
+		return FormatFloat(v)
+	case float32:
+		return FormatFloat(float64(v))
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// FormatFloat 按Lua数值转字符串的方式格式化
+func FormatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// boolInt 将布尔值转换为Lua脚本常用的0/1
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
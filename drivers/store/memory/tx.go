@@ -0,0 +1,314 @@
+package memory
+
+import (
+	"sort"
+	"time"
+)
+
+// Z 有序集合成员
+type Z struct {
+	Member string
+	Score  float64
+}
+
+// Tx 脚本执行期间对存储的独占视图
+// 只能在 ScriptFunc 内使用，调用期间存储锁已被持有
+type Tx struct {
+	store *Store
+	now   time.Time
+}
+
+// Now 返回本次执行的当前时间（同一次脚本执行内保持不变）
+func (tx *Tx) Now() time.Time {
+	return tx.now
+}
+
+// lookup 查找未过期的键（过期键惰性删除）
+func (tx *Tx) lookup(key string) *entry {
+	e, ok := tx.store.items[key]
+	if !ok {
+		return nil
+	}
+	if e.expired(tx.now) {
+		delete(tx.store.items, key)
+		return nil
+	}
+	return e
+}
+
+// lookupKind 查找指定类型的键，类型不匹配返回 ErrWrongType
+func (tx *Tx) lookupKind(key string, k kind) (*entry, error) {
+	e := tx.lookup(key)
+	if e == nil {
+		return nil, nil
+	}
+	if e.kind != k {
+		return nil, ErrWrongType
+	}
+	return e, nil
+}
+
+// Exists 检查键是否存在
+func (tx *Tx) Exists(key string) bool {
+	return tx.lookup(key) != nil
+}
+
+// Get 获取整数值，键不存在返回0
+func (tx *Tx) Get(key string) (int64, error) {
+	e, err := tx.lookupKind(key, kindInt)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	return e.num, nil
+}
+
+// Set 设置整数值（清除原有的过期时间）
+func (tx *Tx) Set(key string, value int64) {
+	tx.store.items[key] = &entry{kind: kindInt, num: value}
+}
+
+// Del 删除键
+func (tx *Tx) Del(key string) {
+	delete(tx.store.items, key)
+}
+
+// IncrBy 增加整数值（保留原有的过期时间）
+func (tx *Tx) IncrBy(key string, value int64) (int64, error) {
+	e, err := tx.lookupKind(key, kindInt)
+	if err != nil {
+		return 0, err
+	}
+	if e == nil {
+		e = &entry{kind: kindInt}
+		tx.store.items[key] = e
+	}
+	e.num += value
+	return e.num, nil
+}
+
+// Expire 设置过期时间（键不存在时忽略，<=0 立即删除）
+func (tx *Tx) Expire(key string, expiration time.Duration) {
+	e := tx.lookup(key)
+	if e == nil {
+		return
+	}
+	if expiration <= 0 {
+		delete(tx.store.items, key)
+		return
+	}
+	e.expireAt = tx.now.Add(expiration)
+}
+
+// TTL 获取剩余时间（键不存在返回-2s，未设置过期返回-1s）
+func (tx *Tx) TTL(key string) time.Duration {
+	e := tx.lookup(key)
+	if e == nil {
+		return -2 * time.Second
+	}
+	if e.expireAt.IsZero() {
+		return -1 * time.Second
+	}
+	return e.expireAt.Sub(tx.now)
+}
+
+// HGet 获取哈希字段
+func (tx *Tx) HGet(key, field string) (string, bool, error) {
+	e, err := tx.lookupKind(key, kindHash)
+	if err != nil || e == nil {
+		return "", false, err
+	}
+	value, ok := e.hash[field]
+	return value, ok, nil
+}
+
+// HSet 设置哈希字段
+func (tx *Tx) HSet(key, field, value string) error {
+	e, err := tx.lookupKind(key, kindHash)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		e = &entry{kind: kindHash, hash: make(map[string]string)}
+		tx.store.items[key] = e
+	}
+	e.hash[field] = value
+	return nil
+}
+
+// ZAdd 添加到有序集合（成员已存在时更新分数）
+func (tx *Tx) ZAdd(key string, score float64, member string) error {
+	e, err := tx.lookupKind(key, kindZSet)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		e = &entry{kind: kindZSet, zset: make(map[string]float64)}
+		tx.store.items[key] = e
+	}
+	e.zset[member] = score
+	return nil
+}
+
+// ZRem 删除有序集合成员，返回实际删除的数量
+func (tx *Tx) ZRem(key string, members ...string) (int64, error) {
+	e, err := tx.lookupKind(key, kindZSet)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	var removed int64
+	for _, member := range members {
+		if _, ok := e.zset[member]; ok {
+			delete(e.zset, member)
+			removed++
+		}
+	}
+	tx.dropEmpty(key, e)
+	return removed, nil
+}
+
+// ZRemRangeByScore 按分数范围删除（闭区间），返回删除的数量
+func (tx *Tx) ZRemRangeByScore(key string, min, max float64) (int64, error) {
+	e, err := tx.lookupKind(key, kindZSet)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	var removed int64
+	for member, score := range e.zset {
+		if score >= min && score <= max {
+			delete(e.zset, member)
+			removed++
+		}
+	}
+	tx.dropEmpty(key, e)
+	return removed, nil
+}
+
+// ZCount 统计分数范围内的成员数量（闭区间）
+func (tx *Tx) ZCount(key string, min, max float64) (int64, error) {
+	e, err := tx.lookupKind(key, kindZSet)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	var count int64
+	for _, score := range e.zset {
+		if score >= min && score <= max {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ZCard 返回有序集合的成员数量
+func (tx *Tx) ZCard(key string) (int64, error) {
+	e, err := tx.lookupKind(key, kindZSet)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	return int64(len(e.zset)), nil
+}
+
+// ZScore 返回有序集合成员的分数，成员不存在时返回false
+func (tx *Tx) ZScore(key, member string) (float64, bool, error) {
+	e, err := tx.lookupKind(key, kindZSet)
+	if err != nil || e == nil {
+		return 0, false, err
+	}
+	score, ok := e.zset[member]
+	return score, ok, nil
+}
+
+// ZRangeByScore 按分数升序返回分数范围内的成员（闭区间）
+func (tx *Tx) ZRangeByScore(key string, min, max float64) ([]Z, error) {
+	e, err := tx.lookupKind(key, kindZSet)
+	if err != nil || e == nil {
+		return nil, err
+	}
+
+	var members []Z
+	for member, score := range e.zset {
+		if score >= min && score <= max {
+			members = append(members, Z{Member: member, Score: score})
+		}
+	}
+	sortZ(members)
+	return members, nil
+}
+
+// partialRangeLimit 只需要前几个成员时逐个选出的数量上限，超过时对整个集合排序
+const partialRangeLimit = 8
+
+// ZRangeWithScores 按分数升序返回指定下标范围的成员（与Redis一致，支持负数下标）
+// 只读取前几个成员时（如最早的记录）不对整个集合排序
+func (tx *Tx) ZRangeWithScores(key string, start, stop int64) ([]Z, error) {
+	e, err := tx.lookupKind(key, kindZSet)
+	if err != nil || e == nil {
+		return nil, err
+	}
+
+	n := int64(len(e.zset))
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return nil, nil
+	}
+
+	if stop < partialRangeLimit && stop+1 < n {
+		return smallestZ(e.zset, int(stop+1))[start:], nil
+	}
+
+	members := make([]Z, 0, len(e.zset))
+	for member, score := range e.zset {
+		members = append(members, Z{Member: member, Score: score})
+	}
+	sortZ(members)
+	return members[start : stop+1], nil
+}
+
+// smallestZ 按排序规则选出最小的k个成员（升序），只维护长度为k的有序切片
+func smallestZ(zset map[string]float64, k int) []Z {
+	members := make([]Z, 0, k+1)
+	for member, score := range zset {
+		z := Z{Member: member, Score: score}
+		if len(members) == k && !lessZ(z, members[k-1]) {
+			continue
+		}
+		i := sort.Search(len(members), func(i int) bool { return lessZ(z, members[i]) })
+		members = append(members, Z{})
+		copy(members[i+1:], members[i:])
+		members[i] = z
+		if len(members) > k {
+			members = members[:k]
+		}
+	}
+	return members
+}
+
+// sortZ 与Redis一致，按分数升序排列，分数相同时按成员名排序
+func sortZ(members []Z) {
+	sort.Slice(members, func(i, j int) bool { return lessZ(members[i], members[j]) })
+}
+
+// lessZ 成员的排序规则：分数升序，分数相同时按成员名排序
+func lessZ(a, b Z) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.Member < b.Member
+}
+
+// dropEmpty 与Redis一致，集合为空时删除键
+func (tx *Tx) dropEmpty(key string, e *entry) {
+	if len(e.zset) == 0 && len(e.hash) == 0 && e.kind != kindInt {
+		delete(tx.store.items, key)
+	}
+}
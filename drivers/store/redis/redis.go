@@ -1,21 +1,23 @@
 package redis
 
 import (
+	"context"
 	"strconv"
 	"time"
 
 	"github.com/Fischlvor/go-ratelimiter"
-	libredis "github.com/go-redis/redis"
+	libredis "github.com/redis/go-redis/v9"
 )
 
 // RedisStore Redis存储实现
 type Store struct {
-	client *libredis.Client
+	client libredis.UniversalClient
 	prefix string
 }
 
 // NewRedisStore 创建Redis存储
-func NewStore(client *libredis.Client, prefix string) ratelimiter.Store {
+// client可以是*redis.Client、*redis.ClusterClient或*redis.Ring
+func NewStore(client libredis.UniversalClient, prefix string) ratelimiter.Store {
 	return &Store{
 		client: client,
 		prefix: prefix,
@@ -30,9 +32,50 @@ func (s *Store) key(k string) string {
 	return s.prefix + ":" + k
 }
 
+// run 在ctx约束下执行一次Redis调用
+// go-redis只有在开启ContextTimeoutEnabled时才会用ctx的截止时间设置读写超时，
+// 这里先检查ctx，再在ctx结束时立即返回ctx.Err()，保证截止时间和取消都能生效；
+// 被放弃的命令会在客户端的ReadTimeout内结束并归还连接
+func run[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	type result struct {
+		val T
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		val, err := fn()
+		ch <- result{val: val, err: err}
+	}()
+
+	select {
+	case r := <-ch:
+		if r.err != nil && ctx.Err() != nil {
+			return zero, ctx.Err()
+		}
+		return r.val, r.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
 // Get 获取键的值
 func (s *Store) Get(key string) (int64, error) {
-	val, err := s.client.Get(s.key(key)).Result()
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext 获取键的值（支持context）
+func (s *Store) GetContext(ctx context.Context, key string) (int64, error) {
+	val, err := run(ctx, func() (string, error) {
+		return s.client.Get(ctx, s.key(key)).Result()
+	})
 	if err == libredis.Nil {
 		return 0, nil
 	}
@@ -44,62 +87,137 @@ func (s *Store) Get(key string) (int64, error) {
 
 // Set 设置键的值
 func (s *Store) Set(key string, value int64) error {
-	return s.client.Set(s.key(key), value, 0).Err()
+	return s.SetContext(context.Background(), key, value)
+}
+
+// SetContext 设置键的值（支持context）
+func (s *Store) SetContext(ctx context.Context, key string, value int64) error {
+	_, err := run(ctx, func() (string, error) {
+		return s.client.Set(ctx, s.key(key), value, 0).Result()
+	})
+	return err
 }
 
 // Del 删除键
 func (s *Store) Del(key string) error {
-	return s.client.Del(s.key(key)).Err()
+	return s.DelContext(context.Background(), key)
+}
+
+// DelContext 删除键（支持context）
+func (s *Store) DelContext(ctx context.Context, key string) error {
+	_, err := run(ctx, func() (int64, error) {
+		return s.client.Del(ctx, s.key(key)).Result()
+	})
+	return err
 }
 
 // Incr 递增
 func (s *Store) Incr(key string) (int64, error) {
-	return s.client.Incr(s.key(key)).Result()
+	return s.IncrContext(context.Background(), key)
+}
+
+// IncrContext 递增（支持context）
+func (s *Store) IncrContext(ctx context.Context, key string) (int64, error) {
+	return run(ctx, func() (int64, error) {
+		return s.client.Incr(ctx, s.key(key)).Result()
+	})
 }
 
 // IncrBy 增加指定数量
 func (s *Store) IncrBy(key string, value int64) (int64, error) {
-	return s.client.IncrBy(s.key(key), value).Result()
+	return s.IncrByContext(context.Background(), key, value)
+}
+
+// IncrByContext 增加指定数量（支持context）
+func (s *Store) IncrByContext(ctx context.Context, key string, value int64) (int64, error) {
+	return run(ctx, func() (int64, error) {
+		return s.client.IncrBy(ctx, s.key(key), value).Result()
+	})
 }
 
 // Expire 设置过期时间
 func (s *Store) Expire(key string, expiration time.Duration) error {
-	return s.client.Expire(s.key(key), expiration).Err()
+	return s.ExpireContext(context.Background(), key, expiration)
+}
+
+// ExpireContext 设置过期时间（支持context）
+func (s *Store) ExpireContext(ctx context.Context, key string, expiration time.Duration) error {
+	_, err := run(ctx, func() (bool, error) {
+		return s.client.Expire(ctx, s.key(key), expiration).Result()
+	})
+	return err
 }
 
 // TTL 获取剩余时间
 func (s *Store) TTL(key string) (time.Duration, error) {
-	return s.client.TTL(s.key(key)).Result()
+	return s.TTLContext(context.Background(), key)
+}
+
+// TTLContext 获取剩余时间（支持context）
+func (s *Store) TTLContext(ctx context.Context, key string) (time.Duration, error) {
+	return run(ctx, func() (time.Duration, error) {
+		return s.client.TTL(ctx, s.key(key)).Result()
+	})
 }
 
 // ZAdd 添加到有序集合
 func (s *Store) ZAdd(key string, score float64, member string) error {
-	return s.client.ZAdd(s.key(key), libredis.Z{
-		Score:  score,
-		Member: member,
-	}).Err()
+	return s.ZAddContext(context.Background(), key, score, member)
+}
+
+// ZAddContext 添加到有序集合（支持context）
+func (s *Store) ZAddContext(ctx context.Context, key string, score float64, member string) error {
+	_, err := run(ctx, func() (int64, error) {
+		return s.client.ZAdd(ctx, s.key(key), libredis.Z{
+			Score:  score,
+			Member: member,
+		}).Result()
+	})
+	return err
 }
 
 // ZRemRangeByScore 按分数范围删除
 func (s *Store) ZRemRangeByScore(key string, min, max float64) error {
+	return s.ZRemRangeByScoreContext(context.Background(), key, min, max)
+}
+
+// ZRemRangeByScoreContext 按分数范围删除（支持context）
+func (s *Store) ZRemRangeByScoreContext(ctx context.Context, key string, min, max float64) error {
 	minStr := strconv.FormatFloat(min, 'f', -1, 64)
 	maxStr := strconv.FormatFloat(max, 'f', -1, 64)
-	return s.client.ZRemRangeByScore(s.key(key), minStr, maxStr).Err()
+	_, err := run(ctx, func() (int64, error) {
+		return s.client.ZRemRangeByScore(ctx, s.key(key), minStr, maxStr).Result()
+	})
+	return err
 }
 
 // ZCount 统计分数范围内的成员数量
 func (s *Store) ZCount(key string, min, max float64) (int64, error) {
+	return s.ZCountContext(context.Background(), key, min, max)
+}
+
+// ZCountContext 统计分数范围内的成员数量（支持context）
+func (s *Store) ZCountContext(ctx context.Context, key string, min, max float64) (int64, error) {
 	minStr := strconv.FormatFloat(min, 'f', -1, 64)
 	maxStr := strconv.FormatFloat(max, 'f', -1, 64)
-	return s.client.ZCount(s.key(key), minStr, maxStr).Result()
+	return run(ctx, func() (int64, error) {
+		return s.client.ZCount(ctx, s.key(key), minStr, maxStr).Result()
+	})
 }
 
 // Eval 执行Lua脚本
 func (s *Store) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return s.EvalContext(context.Background(), script, keys, args...)
+}
+
+// EvalContext 执行Lua脚本（支持context）
+func (s *Store) EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
 	// 为所有key添加前缀
 	prefixedKeys := make([]string, len(keys))
 	for i, k := range keys {
 		prefixedKeys[i] = s.key(k)
 	}
-	return s.client.Eval(script, prefixedKeys, args...).Result()
+	return run(ctx, func() (interface{}, error) {
+		return s.client.Eval(ctx, script, prefixedKeys, args...).Result()
+	})
 }
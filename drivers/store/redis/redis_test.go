@@ -1,37 +1,76 @@
 package redis
 
 import (
+	"context"
+	"errors"
+	"net"
 	"testing"
 	"time"
 
-	"github.com/go-redis/redis"
+	"github.com/Fischlvor/go-ratelimiter/drivers/algorithm"
+	"github.com/Fischlvor/go-ratelimiter/drivers/ban"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
 )
 
-// 注意：这些测试需要运行的Redis实例
-// 可以使用 docker run -d -p 6379:6379 redis 启动
+// 这些测试优先使用本地Redis（可以使用 docker run -d -p 6379:6379 redis 启动），
+// Redis未运行时使用miniredis，保证CI中也会执行真实的Lua脚本
 
 func setupTestRedis(t *testing.T) *redis.Client {
 	client := redis.NewClient(&redis.Options{
 		Addr:     "localhost:6379",
 		Password: "",
 		DB:       15, // 使用DB 15进行测试，避免影响生产数据
+		// Redis未运行时尽快切换到miniredis，不做拨号重试
+		DialerRetries: 1,
 	})
 
 	// 测试连接
-	if err := client.Ping().Err(); err != nil {
-		t.Skipf("跳过Redis测试: Redis未运行 (%v)", err)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return setupMiniRedis(t)
 	}
 
 	// 清空测试数据库
-	client.FlushDB()
+	client.FlushDB(context.Background())
 
 	return client
 }
 
+// setupMiniRedis 启动miniredis并返回连接它的客户端
+// miniredis的键不会随时间过期，后台按实际流逝的时间推进其时钟
+func setupMiniRedis(t *testing.T) *redis.Client {
+	m := miniredis.RunT(t)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				m.FastForward(now.Sub(last))
+				last = now
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+
+	return redis.NewClient(&redis.Options{Addr: m.Addr()})
+}
+
 // cleanupTestRedis 清理测试数据
 func cleanupTestRedis(t *testing.T, client *redis.Client) {
 	// 清空测试数据库
-	if err := client.FlushDB().Err(); err != nil {
+	if err := client.FlushDB(context.Background()).Err(); err != nil {
 		t.Logf("清理Redis数据失败: %v", err)
 	}
 	client.Close()
@@ -194,7 +233,7 @@ func TestRedisStore_Prefix(t *testing.T) {
 	}
 
 	// 直接从Redis检查key是否有前缀
-	val, err := client.Get("myapp:test").Result()
+	val, err := client.Get(context.Background(), "myapp:test").Result()
 	if err != nil {
 		t.Fatalf("Redis Get() error = %v", err)
 	}
@@ -203,7 +242,7 @@ func TestRedisStore_Prefix(t *testing.T) {
 	}
 
 	// 检查不带前缀的key不存在
-	_, err = client.Get("test").Result()
+	_, err = client.Get(context.Background(), "test").Result()
 	if err != redis.Nil {
 		t.Error("不带前缀的key不应该存在")
 	}
@@ -228,7 +267,7 @@ func TestRedisStore_Eval(t *testing.T) {
 	t.Logf("Eval result: %v", result)
 
 	// 验证值是否设置成功
-	val, err := client.Get("test:lua_test").Result()
+	val, err := client.Get(context.Background(), "test:lua_test").Result()
 	if err != nil {
 		t.Fatalf("Get() error = %v", err)
 	}
@@ -237,6 +276,81 @@ func TestRedisStore_Eval(t *testing.T) {
 	}
 }
 
+// newSlowRedis 启动一个只接受连接、从不响应的服务端，模拟卡住的Redis
+func newSlowRedis(t *testing.T) *redis.Client {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	var conns []net.Conn
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{
+		Addr:         ln.Addr().String(),
+		DialTimeout:  5 * time.Second,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+		MaxRetries:   -1,
+	})
+	t.Cleanup(func() {
+		client.Close()
+		ln.Close()
+		<-done
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	return client
+}
+
+func TestRedisStore_ContextDeadline(t *testing.T) {
+	store := NewStore(newSlowRedis(t), "test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := store.(*Store).EvalContext(ctx, "return 1", []string{"k"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望 DeadlineExceeded，得到 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("超过截止时间后仍阻塞了 %v", elapsed)
+	}
+}
+
+func TestRedisStore_ContextCanceled(t *testing.T) {
+	store := NewStore(newSlowRedis(t), "test").(*Store)
+
+	// 已取消的ctx不应发出任何命令
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.IncrContext(ctx, "k"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望 Canceled，得到 %v", err)
+	}
+
+	// 执行中取消也应立即返回
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := store.GetContext(ctx, "k"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望 Canceled，得到 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("取消后仍阻塞了 %v", elapsed)
+	}
+}
+
 func BenchmarkRedisStore_Incr(b *testing.B) {
 	client := redis.NewClient(&redis.Options{
 		Addr: "localhost:6379",
@@ -244,7 +358,7 @@ func BenchmarkRedisStore_Incr(b *testing.B) {
 	})
 	defer client.Close()
 
-	if err := client.Ping().Err(); err != nil {
+	if err := client.Ping(context.Background()).Err(); err != nil {
 		b.Skipf("跳过基准测试: Redis未运行")
 	}
 
@@ -298,3 +412,317 @@ func TestRedisStore_SetAndDel(t *testing.T) {
 		t.Errorf("期望值0（已删除），实际%d", val)
 	}
 }
+
+func TestRedisStore_WindowScripts(t *testing.T) {
+	client := setupTestRedis(t)
+	defer cleanupTestRedis(t, client)
+
+	store := NewStore(client, "test")
+	fixed := algorithm.NewFixedWindowLimiter(store)
+	sliding := algorithm.NewSlidingWindowLimiter(store)
+	counter := algorithm.NewSlidingWindowCounterLimiter(store)
+
+	for i := 0; i < 3; i++ {
+		result, err := fixed.Allow("fixed", 3, time.Minute)
+		if err != nil {
+			t.Fatalf("FixedWindow Allow() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Errorf("固定窗口第%d次请求应该允许", i+1)
+		}
+
+		result, err = sliding.Allow("sliding", 3, time.Minute)
+		if err != nil {
+			t.Fatalf("SlidingWindow Allow() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Errorf("滑动窗口第%d次请求应该允许", i+1)
+		}
+
+		result, err = counter.Allow("counter", 3, time.Minute)
+		if err != nil {
+			t.Fatalf("SlidingWindowCounter Allow() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Errorf("滑动窗口计数器第%d次请求应该允许", i+1)
+		}
+	}
+
+	if result, _ := fixed.Allow("fixed", 3, time.Minute); result.Allowed {
+		t.Error("固定窗口第4次请求应该拒绝")
+	}
+	if result, _ := sliding.Allow("sliding", 3, time.Minute); result.Allowed {
+		t.Error("滑动窗口第4次请求应该拒绝")
+	}
+	if result, _ := counter.Allow("counter", 3, time.Minute); result.Allowed {
+		t.Error("滑动窗口计数器第4次请求应该拒绝")
+	}
+
+	// 固定窗口的键一定带有过期时间
+	if ttl := client.TTL(context.Background(), "test:fixed").Val(); ttl <= 0 {
+		t.Errorf("固定窗口键TTL = %v, 应该大于0", ttl)
+	}
+}
+
+func TestRedisStore_LeakyBucketScripts(t *testing.T) {
+	client := setupTestRedis(t)
+	defer cleanupTestRedis(t, client)
+
+	store := NewStore(client, "test")
+	limiter := algorithm.NewLeakyBucketLimiter(store)
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow("leaky", 3, 0.001)
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Errorf("漏桶第%d次请求应该允许", i+1)
+		}
+
+		result, err = limiter.Queue("queue", 3, 10)
+		if err != nil {
+			t.Fatalf("Queue() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Errorf("漏桶队列第%d次请求应该允许", i+1)
+		}
+		if want := time.Duration(i) * 100 * time.Millisecond; result.Delay < want-50*time.Millisecond || result.Delay > want {
+			t.Errorf("漏桶队列第%d次 Delay = %v, want ~%v", i+1, result.Delay, want)
+		}
+	}
+
+	if result, _ := limiter.Allow("leaky", 3, 0.001); result.Allowed {
+		t.Error("漏桶第4次请求应该拒绝")
+	}
+	// 队列持续流出，剩余空间不足两个请求（不依赖两次调用之间是否跨过毫秒）
+	if result, _ := limiter.QueueN("queue", 3, 10, 2); result.Allowed {
+		t.Error("漏桶队列已满时消耗2个配额的请求应该拒绝")
+	}
+
+	// 漏桶的键一定带有过期时间
+	if ttl := client.PTTL(context.Background(), "test:queue").Val(); ttl <= 0 {
+		t.Errorf("漏桶队列键TTL = %v, 应该大于0", ttl)
+	}
+}
+
+func TestRedisStore_GCRAScript(t *testing.T) {
+	client := setupTestRedis(t)
+	defer cleanupTestRedis(t, client)
+
+	store := NewStore(client, "test")
+	limiter := algorithm.NewGCRALimiter(store)
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow("gcra", 3, 20)
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		if !result.Allowed || result.Remaining != int64(2-i) {
+			t.Errorf("GCRA第%d次请求 = %+v", i+1, result)
+		}
+	}
+	result, err := limiter.Allow("gcra", 3, 20)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed || result.RetryAfterMillis <= 0 || result.RetryAfterMillis > 50 {
+		t.Errorf("GCRA第4次请求 = %+v", result)
+	}
+
+	// TAT以微秒整数保存，不能因Lua数值格式化丢失精度
+	tat, err := client.Get(context.Background(), "test:gcra").Int64()
+	if err != nil || tat < time.Now().UnixMicro() {
+		t.Errorf("TAT = %d, %v", tat, err)
+	}
+}
+
+func TestRedisStore_WeightedScripts(t *testing.T) {
+	client := setupTestRedis(t)
+	defer cleanupTestRedis(t, client)
+
+	store := NewStore(client, "test")
+
+	sliding := algorithm.NewSlidingWindowLimiter(store)
+	if result, err := sliding.AllowN("sliding", 10, time.Minute, 7); err != nil || !result.Allowed || result.Remaining != 3 {
+		t.Fatalf("滑动窗口AllowN(7) = %+v, %v", result, err)
+	}
+	if result, _ := sliding.AllowN("sliding", 10, time.Minute, 4); result.Allowed {
+		t.Error("滑动窗口超出剩余配额应该拒绝")
+	}
+
+	counter := algorithm.NewSlidingWindowCounterLimiter(store)
+	if result, err := counter.AllowN("counter", 10, time.Minute, 7); err != nil || !result.Allowed {
+		t.Fatalf("近似滑动窗口AllowN(7) = %+v, %v", result, err)
+	}
+
+	gcra := algorithm.NewGCRALimiter(store)
+	if result, err := gcra.AllowN("gcra", 5, 1, 5); err != nil || !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("GCRA AllowN(5) = %+v, %v", result, err)
+	}
+
+	queue := algorithm.NewLeakyBucketLimiter(store)
+	if result, err := queue.QueueN("queue", 5, 10, 3); err != nil || !result.Allowed {
+		t.Fatalf("漏桶队列QueueN(3) = %+v, %v", result, err)
+	}
+	// 剩余容量约为2（不依赖两次调用之间是否跨过毫秒）
+	if result, _ := queue.QueueN("queue", 5, 10, 4); result.Allowed {
+		t.Error("漏桶队列超出容量应该拒绝")
+	}
+}
+
+func TestRedisStore_RefundScripts(t *testing.T) {
+	client := setupTestRedis(t)
+	defer cleanupTestRedis(t, client)
+
+	store := NewStore(client, "test")
+
+	fixed := algorithm.NewFixedWindowLimiter(store)
+	result, _ := fixed.AllowN("fixed", 5, time.Minute, 3)
+	if refunded, err := fixed.Refund("fixed", time.Minute, 3, result.RefundToken); err != nil || !refunded {
+		t.Errorf("固定窗口Refund() = %v, %v", refunded, err)
+	}
+	if count, _ := client.Get(context.Background(), "test:fixed").Int64(); count != 0 {
+		t.Errorf("计数 = %d, want 0", count)
+	}
+	if ttl := client.PTTL(context.Background(), "test:fixed").Val(); ttl <= 0 {
+		t.Errorf("计数归零后应保留过期时间, TTL = %v", ttl)
+	}
+
+	// 窗口结束后，上一窗口的凭证不能退还新窗口的配额
+	prev, _ := fixed.Allow("rollover", 5, 50*time.Millisecond)
+	time.Sleep(70 * time.Millisecond)
+	fixed.AllowN("rollover", 5, 50*time.Millisecond, 2)
+	if refunded, _ := fixed.Refund("rollover", 50*time.Millisecond, 1, prev.RefundToken); refunded {
+		t.Error("窗口结束后不应退还上一窗口的配额")
+	}
+
+	sliding := algorithm.NewSlidingWindowLimiter(store)
+	result, _ = sliding.AllowN("sliding", 5, time.Minute, 2)
+	if refunded, err := sliding.Refund("sliding", 2, result.RefundToken); err != nil || !refunded {
+		t.Errorf("滑动窗口Refund() = %v, %v", refunded, err)
+	}
+	if count, _ := client.ZCard(context.Background(), "test:{sliding}").Result(); count != 0 {
+		t.Errorf("成员数 = %d, want 0", count)
+	}
+
+	bucket := algorithm.NewTokenBucketLimiter(store)
+	bucket.AllowN("bucket", 5, 0.001, 2)
+	if refunded, err := bucket.Refund("bucket", 5, 2); err != nil || !refunded {
+		t.Errorf("令牌桶Refund() = %v, %v", refunded, err)
+	}
+	if result, _ := bucket.Peek("bucket", 5, 0.001); result.Remaining != 5 {
+		t.Errorf("Remaining = %d, want 5", result.Remaining)
+	}
+}
+
+func TestRedisStore_PeekScripts(t *testing.T) {
+	client := setupTestRedis(t)
+	defer cleanupTestRedis(t, client)
+
+	store := NewStore(client, "test")
+
+	fixed := algorithm.NewFixedWindowLimiter(store)
+	if result, err := fixed.Peek("fixed", 2, time.Minute); err != nil || !result.Allowed || result.Remaining != 2 {
+		t.Fatalf("固定窗口Peek() = %+v, %v", result, err)
+	}
+	fixed.Allow("fixed", 2, time.Minute)
+	fixed.Allow("fixed", 2, time.Minute)
+	if result, err := fixed.Peek("fixed", 2, time.Minute); err != nil || result.Allowed || result.RetryAfter < 1 {
+		t.Errorf("固定窗口Peek() = %+v, %v", result, err)
+	}
+
+	sliding := algorithm.NewSlidingWindowLimiter(store)
+	sliding.AllowN("sliding", 5, time.Minute, 4)
+	if result, err := sliding.PeekN("sliding", 5, time.Minute, 3); err != nil || result.Allowed || result.Remaining != 1 {
+		t.Errorf("滑动窗口PeekN() = %+v, %v", result, err)
+	}
+
+	bucket := algorithm.NewTokenBucketLimiter(store)
+	if result, err := bucket.Peek("bucket", 5, 1); err != nil || !result.Allowed || result.Remaining != 5 {
+		t.Errorf("令牌桶Peek() = %+v, %v", result, err)
+	}
+
+	gcra := algorithm.NewGCRALimiter(store)
+	if result, err := gcra.Peek("gcra", 3, 10); err != nil || !result.Allowed || result.Remaining != 3 {
+		t.Errorf("GCRA Peek() = %+v, %v", result, err)
+	}
+
+	concurrency := algorithm.NewConcurrencyLimiter(store)
+	if result, err := concurrency.Peek("jobs", 1, time.Minute); err != nil || !result.Allowed {
+		t.Errorf("并发限制Peek() = %+v, %v", result, err)
+	}
+
+	// 预检不会创建键
+	for _, key := range []string{"test:bucket", "test:gcra", "test:jobs"} {
+		if n, _ := client.Exists(context.Background(), key).Result(); n != 0 {
+			t.Errorf("预检不应创建键 %s", key)
+		}
+	}
+}
+
+func TestRedisStore_ConcurrencyScripts(t *testing.T) {
+	client := setupTestRedis(t)
+	defer cleanupTestRedis(t, client)
+
+	store := NewStore(client, "test")
+	limiter := algorithm.NewConcurrencyLimiter(store)
+
+	first, err := limiter.Acquire("jobs", 1, time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if !first.Allowed || first.LeaseID == "" {
+		t.Fatalf("第1个请求 = %+v", first)
+	}
+	result, err := limiter.Acquire("jobs", 1, time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if result.Allowed || result.Reset < time.Now().Unix() {
+		t.Errorf("第2个请求 = %+v", result)
+	}
+
+	if released, err := limiter.Release("jobs", first.LeaseID); err != nil || !released {
+		t.Errorf("Release() = %v, %v, want true", released, err)
+	}
+	if result, _ := limiter.Acquire("jobs", 1, time.Minute); !result.Allowed {
+		t.Error("释放后应该允许")
+	}
+}
+
+func TestRedisStore_BanScripts(t *testing.T) {
+	client := setupTestRedis(t)
+	defer cleanupTestRedis(t, client)
+
+	store := NewStore(client, "test")
+	m := ban.NewManager(store)
+	ctx := context.Background()
+
+	if err := m.Ban(ctx, "ip", "1.1.1.1", time.Hour, "test", ban.SourceManual); err != nil {
+		t.Fatalf("Ban() error = %v", err)
+	}
+	if err := m.Ban(ctx, "ip", "2.2.2.2", 0, "permanent", ban.SourceAuto); err != nil {
+		t.Fatalf("Ban() error = %v", err)
+	}
+
+	if ttl := client.TTL(context.Background(), "test:blacklist:{ip:1.1.1.1}").Val(); ttl <= 0 {
+		t.Errorf("封禁标记TTL = %v, 应该大于0", ttl)
+	}
+
+	records, err := m.List(ctx, "ip", 0, 0)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(records) != 2 || records[0].Reason != "test" || records[1].ExpiresAt != 0 {
+		t.Errorf("records = %+v", records)
+	}
+
+	existed, err := m.Unban(ctx, "ip", "1.1.1.1")
+	if err != nil || !existed {
+		t.Errorf("Unban() = %v, %v, want true", existed, err)
+	}
+	if records, _ := m.List(ctx, "ip", 0, 0); len(records) != 1 {
+		t.Errorf("解封后 records = %+v", records)
+	}
+}
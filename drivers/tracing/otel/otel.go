@@ -0,0 +1,91 @@
+// Package otel 将OpenTelemetry的TracerProvider适配为限流器的链路追踪接口
+//
+// 该包是独立的Go模块，核心包和其他驱动不依赖OpenTelemetry
+//
+// 使用方式：
+//
+//	provider := otel.NewTracerProvider(otelapi.GetTracerProvider())
+//	store := tracing.NewStore(redis.NewStore(client, "app"), provider)
+//	limiter, _ := ratelimiter.NewFromFile("rate_limit.yaml", store, ratelimiter.WithTracerProvider(provider))
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Fischlvor/go-ratelimiter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerProvider OpenTelemetry链路追踪适配器
+type TracerProvider struct {
+	provider trace.TracerProvider
+}
+
+// NewTracerProvider 包装OpenTelemetry的TracerProvider（如 otel.GetTracerProvider() 或SDK创建的provider）
+func NewTracerProvider(provider trace.TracerProvider) *TracerProvider {
+	return &TracerProvider{provider: provider}
+}
+
+// Tracer 按instrumentation名称获取Tracer
+func (p *TracerProvider) Tracer(name string) ratelimiter.Tracer {
+	return &tracer{tracer: p.provider.Tracer(name)}
+}
+
+// tracer 包装OpenTelemetry的Tracer
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// Start 开始一个span，返回的context携带OpenTelemetry span，可与业务代码的span互为父子
+func (t *tracer) Start(ctx context.Context, name string) (context.Context, ratelimiter.Span) {
+	ctx, s := t.tracer.Start(ctx, name)
+	return ctx, &span{span: s}
+}
+
+// span 包装OpenTelemetry的Span
+type span struct {
+	span trace.Span
+}
+
+// SetAttributes 设置属性，不支持的值类型按字符串记录
+func (s *span) SetAttributes(attrs ...ratelimiter.Attribute) {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		kvs = append(kvs, keyValue(a))
+	}
+	s.span.SetAttributes(kvs...)
+}
+
+// RecordError 记录错误并将span状态设置为Error
+func (s *span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End 结束span
+func (s *span) End() {
+	s.span.End()
+}
+
+// keyValue 将限流器的属性转换为OpenTelemetry属性
+func keyValue(a ratelimiter.Attribute) attribute.KeyValue {
+	switch v := a.Value.(type) {
+	case string:
+		return attribute.String(a.Key, v)
+	case int64:
+		return attribute.Int64(a.Key, v)
+	case int:
+		return attribute.Int(a.Key, v)
+	case float64:
+		return attribute.Float64(a.Key, v)
+	case bool:
+		return attribute.Bool(a.Key, v)
+	case fmt.Stringer:
+		return attribute.String(a.Key, v.String())
+	default:
+		return attribute.String(a.Key, fmt.Sprint(v))
+	}
+}
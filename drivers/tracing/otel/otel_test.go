@@ -0,0 +1,155 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Fischlvor/go-ratelimiter"
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+	"github.com/Fischlvor/go-ratelimiter/drivers/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newLimiter 创建使用SDK内存span记录器的限流器，存储调用经过tracing装饰器
+func newLimiter(t *testing.T) (*ratelimiter.Limiter, *sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	sdk := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = sdk.Shutdown(context.Background()) })
+
+	store := memory.NewStore(0)
+	t.Cleanup(func() { store.Close() })
+
+	provider := NewTracerProvider(sdk)
+	config := &ratelimiter.Config{
+		Default: ratelimiter.DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []ratelimiter.RuleConfig{
+			{Name: "api", Path: "/api", By: "ip", Params: []string{"1", "1m"}},
+		},
+	}
+	limiter, err := ratelimiter.NewFromConfig(config, tracing.NewStore(store, provider), ratelimiter.WithTracerProvider(provider))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	return limiter, sdk, recorder
+}
+
+// find 按名称查找已结束的span
+func find(recorder *tracetest.SpanRecorder, name string) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// attrs 将span属性转换为map
+func attrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestTracerProvider_Check(t *testing.T) {
+	limiter, sdk, recorder := newLimiter(t)
+
+	// 限流器的span挂在业务span下
+	ctx, parent := sdk.Tracer("app").Start(context.Background(), "handler")
+	if _, err := limiter.CheckContext(ctx, "/api", "GET", "1.1.1.1", ""); err != nil {
+		t.Fatalf("CheckContext() error = %v", err)
+	}
+	if result, _ := limiter.CheckContext(ctx, "/api", "GET", "1.1.1.1", ""); result.Allowed {
+		t.Fatal("超出限制应该拒绝")
+	}
+	parent.End()
+
+	checks := find(recorder, "ratelimiter.Check")
+	if len(checks) != 2 {
+		t.Fatalf("Check span数量 = %d, want 2", len(checks))
+	}
+	for _, check := range checks {
+		if check.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Check span的父span = %v, want handler", check.Parent().SpanID())
+		}
+		if check.InstrumentationScope().Name != ratelimiter.TracerName {
+			t.Errorf("instrumentation名称 = %s, want %s", check.InstrumentationScope().Name, ratelimiter.TracerName)
+		}
+	}
+
+	allowed := attrs(checks[0])
+	if allowed[ratelimiter.AttrKeyRule].AsString() != "api" ||
+		allowed[ratelimiter.AttrKeyDecision].AsString() != "allowed" ||
+		allowed[ratelimiter.AttrKeyLimit].AsInt64() != 1 ||
+		allowed[ratelimiter.AttrKeyRemaining].AsInt64() != 0 {
+		t.Errorf("通过的Check span属性 = %v", allowed)
+	}
+	denied := attrs(checks[1])
+	if denied[ratelimiter.AttrKeyDecision].AsString() != "denied" ||
+		denied[ratelimiter.AttrKeyReason].AsString() != string(ratelimiter.ReasonRuleLimit) {
+		t.Errorf("拒绝的Check span属性 = %v", denied)
+	}
+
+	// 存储调用的span挂在Check span下
+	evals := find(recorder, "ratelimiter.store.eval")
+	if len(evals) == 0 {
+		t.Fatal("没有存储调用span")
+	}
+	if evals[0].Parent().SpanID() != checks[0].SpanContext().SpanID() {
+		t.Errorf("存储span的父span = %v, want Check span", evals[0].Parent().SpanID())
+	}
+	if attrs(evals[0])[tracing.AttrKeyOperation].AsString() != "eval" {
+		t.Errorf("存储span属性 = %v", attrs(evals[0]))
+	}
+}
+
+func TestTracerProvider_RecordError(t *testing.T) {
+	limiter, _, recorder := newLimiter(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.CheckContext(ctx, "/api", "GET", "1.1.1.1", ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("CheckContext() error = %v, want context.Canceled", err)
+	}
+
+	checks := find(recorder, "ratelimiter.Check")
+	if len(checks) != 1 {
+		t.Fatalf("Check span数量 = %d, want 1", len(checks))
+	}
+	if checks[0].Status().Code != codes.Error {
+		t.Errorf("span状态 = %v, want Error", checks[0].Status())
+	}
+	events := checks[0].Events()
+	if len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("span事件 = %v, want exception", events)
+	}
+}
+
+func TestKeyValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  attribute.Value
+	}{
+		{"ip", attribute.StringValue("ip")},
+		{int64(3), attribute.Int64Value(3)},
+		{3, attribute.IntValue(3)},
+		{1.5, attribute.Float64Value(1.5)},
+		{true, attribute.BoolValue(true)},
+		{ratelimiter.ReasonRuleLimit, attribute.StringValue(string(ratelimiter.ReasonRuleLimit))},
+		{[]int{1, 2}, attribute.StringValue("[1 2]")},
+	}
+
+	for _, tt := range tests {
+		kv := keyValue(ratelimiter.Attribute{Key: "k", Value: tt.value})
+		if kv.Key != "k" || kv.Value != tt.want {
+			t.Errorf("keyValue(%#v) = %v, want %v", tt.value, kv.Value.Emit(), tt.want.Emit())
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package tracing 为存储调用创建span的存储装饰器，可包装任意存储驱动
+//
+// 使用方式：
+//
+//	provider := otel.NewTracerProvider(otelapi.GetTracerProvider()) // drivers/tracing/otel
+//	store := tracing.NewStore(redis.NewStore(client, "app"), provider)
+//	limiter, _ := ratelimiter.NewFromFile("rate_limit.yaml", store, ratelimiter.WithTracerProvider(provider))
+//
+// 限流器通过 WithTracerProvider 创建的Check span会作为存储调用span的父span
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter"
+)
+
+// span属性名
+const (
+	// AttrKeyOperation 存储操作名
+	AttrKeyOperation = "db.operation"
+	// AttrKeyKey 操作的键（Eval为第一个键）
+	AttrKeyKey = "ratelimiter.store.key"
+)
+
+// Store 为每次存储调用创建span的存储装饰器
+type Store struct {
+	ctxStore ratelimiter.ContextStore
+	tracer   ratelimiter.Tracer
+}
+
+// NewStore 包装存储，每次调用创建一个span
+func NewStore(store ratelimiter.Store, provider ratelimiter.TracerProvider) *Store {
+	return &Store{
+		ctxStore: ratelimiter.ToContextStore(store),
+		tracer:   provider.Tracer(ratelimiter.TracerName),
+	}
+}
+
+// start 开始一次存储调用的span
+func (s *Store) start(ctx context.Context, operation, key string) (context.Context, ratelimiter.Span) {
+	ctx, span := s.tracer.Start(ctx, "ratelimiter.store."+operation)
+	span.SetAttributes(
+		ratelimiter.Attribute{Key: AttrKeyOperation, Value: operation},
+		ratelimiter.Attribute{Key: AttrKeyKey, Value: key},
+	)
+	return ctx, span
+}
+
+// end 记录错误并结束span
+func end(span ratelimiter.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// Get 获取键的值
+func (s *Store) Get(key string) (int64, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// Set 设置键的值
+func (s *Store) Set(key string, value int64) error {
+	return s.SetContext(context.Background(), key, value)
+}
+
+// Del 删除键
+func (s *Store) Del(key string) error {
+	return s.DelContext(context.Background(), key)
+}
+
+// Incr 增加键的值
+func (s *Store) Incr(key string) (int64, error) {
+	return s.IncrContext(context.Background(), key)
+}
+
+// IncrBy 增加键的值指定数量
+func (s *Store) IncrBy(key string, value int64) (int64, error) {
+	return s.IncrByContext(context.Background(), key, value)
+}
+
+// Expire 设置键的过期时间
+func (s *Store) Expire(key string, expiration time.Duration) error {
+	return s.ExpireContext(context.Background(), key, expiration)
+}
+
+// TTL 获取键的剩余过期时间
+func (s *Store) TTL(key string) (time.Duration, error) {
+	return s.TTLContext(context.Background(), key)
+}
+
+// ZAdd 添加有序集合成员
+func (s *Store) ZAdd(key string, score float64, member string) error {
+	return s.ZAddContext(context.Background(), key, score, member)
+}
+
+// ZRemRangeByScore 删除有序集合中指定分数范围的成员
+func (s *Store) ZRemRangeByScore(key string, min, max float64) error {
+	return s.ZRemRangeByScoreContext(context.Background(), key, min, max)
+}
+
+// ZCount 统计有序集合中指定分数范围的成员数量
+func (s *Store) ZCount(key string, min, max float64) (int64, error) {
+	return s.ZCountContext(context.Background(), key, min, max)
+}
+
+// Eval 执行Lua脚本
+func (s *Store) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return s.EvalContext(context.Background(), script, keys, args...)
+}
+
+// GetContext 获取键的值
+func (s *Store) GetContext(ctx context.Context, key string) (int64, error) {
+	ctx, span := s.start(ctx, "get", key)
+	value, err := s.ctxStore.GetContext(ctx, key)
+	end(span, err)
+	return value, err
+}
+
+// SetContext 设置键的值
+func (s *Store) SetContext(ctx context.Context, key string, value int64) error {
+	ctx, span := s.start(ctx, "set", key)
+	err := s.ctxStore.SetContext(ctx, key, value)
+	end(span, err)
+	return err
+}
+
+// DelContext 删除键
+func (s *Store) DelContext(ctx context.Context, key string) error {
+	ctx, span := s.start(ctx, "del", key)
+	err := s.ctxStore.DelContext(ctx, key)
+	end(span, err)
+	return err
+}
+
+// IncrContext 增加键的值
+func (s *Store) IncrContext(ctx context.Context, key string) (int64, error) {
+	ctx, span := s.start(ctx, "incr", key)
+	value, err := s.ctxStore.IncrContext(ctx, key)
+	end(span, err)
+	return value, err
+}
+
+// IncrByContext 增加键的值指定数量
+func (s *Store) IncrByContext(ctx context.Context, key string, value int64) (int64, error) {
+	ctx, span := s.start(ctx, "incrby", key)
+	result, err := s.ctxStore.IncrByContext(ctx, key, value)
+	end(span, err)
+	return result, err
+}
+
+// ExpireContext 设置键的过期时间
+func (s *Store) ExpireContext(ctx context.Context, key string, expiration time.Duration) error {
+	ctx, span := s.start(ctx, "expire", key)
+	err := s.ctxStore.ExpireContext(ctx, key, expiration)
+	end(span, err)
+	return err
+}
+
+// TTLContext 获取键的剩余过期时间
+func (s *Store) TTLContext(ctx context.Context, key string) (time.Duration, error) {
+	ctx, span := s.start(ctx, "ttl", key)
+	ttl, err := s.ctxStore.TTLContext(ctx, key)
+	end(span, err)
+	return ttl, err
+}
+
+// ZAddContext 添加有序集合成员
+func (s *Store) ZAddContext(ctx context.Context, key string, score float64, member string) error {
+	ctx, span := s.start(ctx, "zadd", key)
+	err := s.ctxStore.ZAddContext(ctx, key, score, member)
+	end(span, err)
+	return err
+}
+
+// ZRemRangeByScoreContext 删除有序集合中指定分数范围的成员
+func (s *Store) ZRemRangeByScoreContext(ctx context.Context, key string, min, max float64) error {
+	ctx, span := s.start(ctx, "zremrangebyscore", key)
+	err := s.ctxStore.ZRemRangeByScoreContext(ctx, key, min, max)
+	end(span, err)
+	return err
+}
+
+// ZCountContext 统计有序集合中指定分数范围的成员数量
+func (s *Store) ZCountContext(ctx context.Context, key string, min, max float64) (int64, error) {
+	ctx, span := s.start(ctx, "zcount", key)
+	count, err := s.ctxStore.ZCountContext(ctx, key, min, max)
+	end(span, err)
+	return count, err
+}
+
+// EvalContext 执行Lua脚本
+func (s *Store) EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	var key string
+	if len(keys) > 0 {
+		key = keys[0]
+	}
+	ctx, span := s.start(ctx, "eval", key)
+	result, err := s.ctxStore.EvalContext(ctx, script, keys, args...)
+	end(span, err)
+	return result, err
+}
@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter"
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+// recordedSpan 记录的span
+type recordedSpan struct {
+	name   string
+	parent *recordedSpan
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...ratelimiter.Attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) { s.err = err }
+func (s *recordedSpan) End()                  { s.ended = true }
+
+type spanKey struct{}
+
+// recorder 记录全部span的TracerProvider，父span通过context传递
+type recorder struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (r *recorder) Tracer(name string) ratelimiter.Tracer { return r }
+
+func (r *recorder) Start(ctx context.Context, name string) (context.Context, ratelimiter.Span) {
+	parent, _ := ctx.Value(spanKey{}).(*recordedSpan)
+	span := &recordedSpan{name: name, parent: parent, attrs: make(map[string]interface{})}
+	r.mu.Lock()
+	r.spans = append(r.spans, span)
+	r.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// find 按名称查找span
+func (r *recorder) find(name string) []*recordedSpan {
+	var spans []*recordedSpan
+	for _, span := range r.spans {
+		if span.name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func TestStore_ChildSpans(t *testing.T) {
+	provider := &recorder{}
+	mem := memory.NewStore(0)
+	defer mem.Close()
+
+	config := &ratelimiter.Config{
+		Default: ratelimiter.DefaultConfig{Algorithm: "sliding_window", Enabled: true},
+		Rules: []ratelimiter.RuleConfig{
+			{Name: "api", Path: "/api", By: "ip", Params: []string{"1", "1m"}},
+		},
+	}
+	limiter, err := ratelimiter.NewFromConfig(config, NewStore(mem, provider), ratelimiter.WithTracerProvider(provider))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	limiter.Check("/api", "GET", "1.1.1.1", "")
+	limiter.Check("/api", "GET", "1.1.1.1", "")
+
+	checks := provider.find("ratelimiter.Check")
+	if len(checks) != 2 {
+		t.Fatalf("Check span数量 = %d, want 2", len(checks))
+	}
+	denied := checks[1]
+	if denied.attrs[ratelimiter.AttrKeyRule] != "api" ||
+		denied.attrs[ratelimiter.AttrKeyAlgorithm] != "sliding_window" ||
+		denied.attrs[ratelimiter.AttrKeyDecision] != ratelimiter.DecisionDenied ||
+		denied.attrs[ratelimiter.AttrKeyRemaining] != int64(0) {
+		t.Errorf("Check span属性 = %v", denied.attrs)
+	}
+
+	evals := provider.find("ratelimiter.store.eval")
+	if len(evals) != 2 {
+		t.Fatalf("eval span数量 = %d, want 2", len(evals))
+	}
+	for i, span := range evals {
+		if span.parent != checks[i] {
+			t.Errorf("eval span[%d] 的父span应为Check span", i)
+		}
+		if span.attrs[AttrKeyKey] == "" || !span.ended {
+			t.Errorf("eval span[%d] = %+v", i, span)
+		}
+	}
+}
+
+// failingStore 所有调用都失败的存储
+type failingStore struct{}
+
+var errTest = errors.New("存储不可用")
+
+func (failingStore) Get(string) (int64, error)                       { return 0, errTest }
+func (failingStore) Set(string, int64) error                         { return errTest }
+func (failingStore) Del(string) error                                { return errTest }
+func (failingStore) Incr(string) (int64, error)                      { return 0, errTest }
+func (failingStore) IncrBy(string, int64) (int64, error)             { return 0, errTest }
+func (failingStore) Expire(string, time.Duration) error              { return errTest }
+func (failingStore) TTL(string) (time.Duration, error)               { return 0, errTest }
+func (failingStore) ZAdd(string, float64, string) error              { return errTest }
+func (failingStore) ZRemRangeByScore(string, float64, float64) error { return errTest }
+func (failingStore) ZCount(string, float64, float64) (int64, error)  { return 0, errTest }
+func (failingStore) Eval(string, []string, ...interface{}) (interface{}, error) {
+	return nil, errTest
+}
+
+func TestStore_RecordError(t *testing.T) {
+	provider := &recorder{}
+	store := NewStore(failingStore{}, provider)
+
+	if _, err := store.IncrBy("violation:ip:1.1.1.1", 1); !errors.Is(err, errTest) {
+		t.Fatalf("IncrBy() error = %v, want errTest", err)
+	}
+
+	spans := provider.find("ratelimiter.store.incrby")
+	if len(spans) != 1 {
+		t.Fatalf("span数量 = %d, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.err != errTest || !span.ended || span.attrs[AttrKeyKey] != "violation:ip:1.1.1.1" {
+		t.Errorf("span = %+v", span)
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// KeyExtractor 自定义维度的key提取器
+// arg 为规则中 key 字段冒号后的参数（如 header:X-Tenant-ID 中的 X-Tenant-ID）
+// 返回提取到的标识和是否提取成功
+type KeyExtractor func(req *Request, arg string) (string, bool)
+
+// 内置提取器使用的属性名
+const (
+	// AttrAPIKey API Key属性
+	AttrAPIKey = "api_key"
+	// AttrTenantID 租户ID属性
+	AttrTenantID = "tenant_id"
+	// attrHeaderPrefix 请求头属性前缀
+	attrHeaderPrefix = "header:"
+)
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = map[string]KeyExtractor{
+		"api_key": attrExtractor(AttrAPIKey),
+		"tenant":  attrExtractor(AttrTenantID),
+		"header":  headerExtractor,
+		"attr":    attributeExtractor,
+	}
+)
+
+// RegisterKeyExtractor 注册自定义key提取器（同名覆盖）
+// 规则通过 by: custom 和 key: <name> 或 key: <name>:<arg> 引用
+func RegisterKeyExtractor(name string, extractor KeyExtractor) error {
+	if name == "" || extractor == nil {
+		return fmt.Errorf("提取器名称和函数不能为空")
+	}
+	if strings.Contains(name, ":") {
+		return fmt.Errorf("提取器名称不能包含冒号: %s", name)
+	}
+
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors[name] = extractor
+	return nil
+}
+
+// lookupKeyExtractor 根据规则的key字段查找提取器，返回提取器和参数
+func lookupKeyExtractor(key string) (KeyExtractor, string, error) {
+	name, arg, _ := strings.Cut(key, ":")
+
+	extractorsMu.RLock()
+	extractor, ok := extractors[name]
+	extractorsMu.RUnlock()
+
+	if !ok {
+		return nil, "", fmt.Errorf("未知的key提取器: %s", name)
+	}
+	return extractor, arg, nil
+}
+
+// HeaderAttribute 返回请求头对应的属性名
+// 未设置 Request.Header 时，调用方可据此把请求头放入属性中供 header 提取器使用
+func HeaderAttribute(name string) string {
+	return attrHeaderPrefix + textproto.CanonicalMIMEHeaderKey(name)
+}
+
+// attrExtractor 读取固定属性的提取器
+func attrExtractor(attr string) KeyExtractor {
+	return func(req *Request, _ string) (string, bool) {
+		value := req.Attribute(attr)
+		return value, value != ""
+	}
+}
+
+// headerExtractor 读取请求头，如 key: header:X-Tenant-ID
+// 优先读取 Request.Header，其次读取 HeaderAttribute 对应的属性
+func headerExtractor(req *Request, arg string) (string, bool) {
+	if arg == "" {
+		return "", false
+	}
+	value := req.Header.Get(arg)
+	if value == "" {
+		value = req.Attribute(HeaderAttribute(arg))
+	}
+	return value, value != ""
+}
+
+// attributeExtractor 读取任意属性，如 key: attr:region
+func attributeExtractor(req *Request, arg string) (string, bool) {
+	if arg == "" {
+		return "", false
+	}
+	value := req.Attribute(arg)
+	return value, value != ""
+}
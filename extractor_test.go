@@ -0,0 +1,93 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLookupKeyExtractor(t *testing.T) {
+	attrs := map[string]string{
+		AttrAPIKey:                  "key-1",
+		AttrTenantID:                "tenant-1",
+		HeaderAttribute("X-Client"): "client-1",
+		"region":                    "cn-east",
+	}
+
+	tests := []struct {
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{key: "api_key", want: "key-1", wantOK: true},
+		{key: "tenant", want: "tenant-1", wantOK: true},
+		{key: "header:x-client", want: "client-1", wantOK: true},
+		{key: "header:X-Missing", want: "", wantOK: false},
+		{key: "header", want: "", wantOK: false},
+		{key: "attr:region", want: "cn-east", wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			extractor, arg, err := lookupKeyExtractor(tt.key)
+			if err != nil {
+				t.Fatalf("lookupKeyExtractor() error = %v", err)
+			}
+			got, ok := extractor(&Request{Attributes: attrs}, arg)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("extractor() = (%q, %v), want (%q, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	if _, _, err := lookupKeyExtractor("unknown"); err == nil {
+		t.Error("未知提取器应该返回错误")
+	}
+}
+
+func TestRegisterKeyExtractor(t *testing.T) {
+	err := RegisterKeyExtractor("device", func(req *Request, _ string) (string, bool) {
+		id := req.Attribute("device_id")
+		return id, id != ""
+	})
+	if err != nil {
+		t.Fatalf("RegisterKeyExtractor() error = %v", err)
+	}
+
+	extractor, _, err := lookupKeyExtractor("device")
+	if err != nil {
+		t.Fatalf("lookupKeyExtractor() error = %v", err)
+	}
+	if got, ok := extractor(&Request{Attributes: map[string]string{"device_id": "d1"}}, ""); !ok || got != "d1" {
+		t.Errorf("extractor() = (%q, %v), want (d1, true)", got, ok)
+	}
+
+	// 无效注册
+	if err := RegisterKeyExtractor("", nil); err == nil {
+		t.Error("空名称应该返回错误")
+	}
+	if err := RegisterKeyExtractor("a:b", extractor); err == nil {
+		t.Error("名称包含冒号应该返回错误")
+	}
+}
+
+func TestHeaderExtractor(t *testing.T) {
+	extractor, arg, err := lookupKeyExtractor("header:x-api-key")
+	if err != nil {
+		t.Fatalf("lookupKeyExtractor() error = %v", err)
+	}
+
+	// 优先读取Header
+	req := &Request{
+		Header:     http.Header{"X-Api-Key": []string{"from-header"}},
+		Attributes: map[string]string{HeaderAttribute("X-API-Key"): "from-attr"},
+	}
+	if got, _ := extractor(req, arg); got != "from-header" {
+		t.Errorf("extractor() = %q, want from-header", got)
+	}
+
+	// 没有Header时读取属性
+	req.Header = nil
+	if got, _ := extractor(req, arg); got != "from-attr" {
+		t.Errorf("extractor() = %q, want from-attr", got)
+	}
+}
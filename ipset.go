@@ -0,0 +1,141 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ipSet IP和CIDR集合（基于二叉前缀树查找，时间复杂度与集合大小无关）
+// IPv4地址按IPv4映射的IPv6地址（::ffff:a.b.c.d）存储，因此 ::ffff:1.2.3.4 与 1.2.3.4 等价
+type ipSet struct {
+	root ipNode
+	size int
+}
+
+// ipNode 前缀树节点
+type ipNode struct {
+	children [2]*ipNode
+	// terminal 从根到该节点的路径是否为集合中的一个前缀
+	terminal bool
+}
+
+// newIPSet 从IP或CIDR列表创建集合
+func newIPSet(entries []string) (*ipSet, error) {
+	s := &ipSet{}
+	for _, entry := range entries {
+		if err := s.add(entry); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// add 添加IP或CIDR（如：1.2.3.4、10.0.0.0/8、2001:db8::/32）
+func (s *ipSet) add(entry string) error {
+	prefix, err := parseIPPrefix(entry)
+	if err != nil {
+		return err
+	}
+
+	addr := prefix.Addr().As16()
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		bits += 96
+	}
+
+	node := &s.root
+	for i := 0; i < bits; i++ {
+		if node.terminal {
+			// 已包含更短的前缀，无需继续
+			return nil
+		}
+		b := addrBit(addr, i)
+		if node.children[b] == nil {
+			node.children[b] = &ipNode{}
+		}
+		node = node.children[b]
+	}
+	node.terminal = true
+	// 更长的前缀已被覆盖
+	node.children = [2]*ipNode{}
+	s.size++
+	return nil
+}
+
+// contains 检查IP是否在集合中（无法解析的IP返回false）
+func (s *ipSet) contains(ip string) bool {
+	if s == nil || s.size == 0 || ip == "" {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	return s.containsAddr(addr)
+}
+
+// containsAddr 检查地址是否在集合中
+func (s *ipSet) containsAddr(addr netip.Addr) bool {
+	a := addr.WithZone("").As16()
+
+	node := &s.root
+	for i := 0; i < 128; i++ {
+		if node.terminal {
+			return true
+		}
+		node = node.children[addrBit(a, i)]
+		if node == nil {
+			return false
+		}
+	}
+	return node.terminal
+}
+
+// parseIPPrefix 解析IP或CIDR，单个IP视为/32或/128
+func parseIPPrefix(entry string) (netip.Prefix, error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("无效的CIDR: %s", entry)
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("无效的IP: %s", entry)
+	}
+	addr = addr.WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// addrBit 返回地址第i位（从最高位开始）
+func addrBit(addr [16]byte, i int) int {
+	return int(addr[i/8]>>(7-uint(i%8))) & 1
+}
+
+// aggregateIP 按前缀长度聚合IP，返回网段标识（如：2001:db8:1:2::/64）
+// 前缀长度为0或不小于地址长度时返回规范化后的地址；无法解析的IP原样返回
+func aggregateIP(ip string, ipv4Prefix, ipv6Prefix int) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap().WithZone("")
+
+	bits := ipv6Prefix
+	if addr.Is4() {
+		bits = ipv4Prefix
+	}
+	if bits <= 0 || bits >= addr.BitLen() {
+		return addr.String()
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
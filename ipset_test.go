@@ -0,0 +1,153 @@
+package ratelimiter
+
+import "testing"
+
+func TestIPSet(t *testing.T) {
+	set, err := newIPSet([]string{
+		"10.0.0.0/8",
+		"192.168.1.1",
+		"172.16.5.0/24",
+		"2001:db8::/32",
+		"::1",
+		"::ffff:100.64.0.0/106",
+	})
+	if err != nil {
+		t.Fatalf("newIPSet() error = %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.0.0.1", true},
+		{"10.255.255.255", true},
+		{"11.0.0.1", false},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"172.16.5.200", true},
+		{"172.16.6.1", false},
+		{"2001:db8::1", true},
+		{"2001:db8:ffff::1", true},
+		{"2001:db9::1", false},
+		{"::1", true},
+		{"::2", false},
+		{"fe80::1%eth0", false},
+		// IPv4映射的IPv6地址与IPv4地址等价
+		{"::ffff:10.1.2.3", true},
+		{"::ffff:192.168.1.1", true},
+		{"::ffff:192.168.1.2", false},
+		{"100.64.1.1", true},
+		{"100.128.0.1", false},
+		// 无法解析的IP
+		{"", false},
+		{"localhost", false},
+		{"10.0.0.0/8", false},
+	}
+	for _, tt := range tests {
+		if got := set.contains(tt.ip); got != tt.want {
+			t.Errorf("contains(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestIPSet_OverlappingPrefixes(t *testing.T) {
+	// 先添加更长的前缀，再添加覆盖它的更短前缀
+	set, err := newIPSet([]string{"10.1.2.3", "10.1.0.0/16", "10.0.0.0/8", "10.2.3.4"})
+	if err != nil {
+		t.Fatalf("newIPSet() error = %v", err)
+	}
+	for _, ip := range []string{"10.1.2.3", "10.1.9.9", "10.9.9.9", "10.2.3.4"} {
+		if !set.contains(ip) {
+			t.Errorf("contains(%q) = false, want true", ip)
+		}
+	}
+	if set.contains("11.0.0.0") {
+		t.Error("contains(11.0.0.0) = true, want false")
+	}
+
+	// 0.0.0.0/0 匹配所有IPv4，但不匹配IPv6
+	all, _ := newIPSet([]string{"0.0.0.0/0"})
+	if !all.contains("8.8.8.8") || all.contains("2001:db8::1") {
+		t.Error("0.0.0.0/0 应该只匹配IPv4")
+	}
+}
+
+func TestIPSet_Invalid(t *testing.T) {
+	for _, entry := range []string{"abc", "10.0.0.0/33", "1.2.3", "2001:db8::/129", ""} {
+		if _, err := newIPSet([]string{entry}); err == nil {
+			t.Errorf("newIPSet(%q) 应该返回错误", entry)
+		}
+	}
+
+	var empty *ipSet
+	if empty.contains("1.2.3.4") {
+		t.Error("空集合不应包含任何IP")
+	}
+}
+
+func TestCheck_CIDRLists(t *testing.T) {
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Global:  &GlobalConfig{Params: []string{"1", "1m"}},
+		Whitelist: WhitelistConfig{
+			IPs: []string{"10.0.0.0/8"},
+		},
+		Blacklist: BlacklistConfig{
+			IPs: []string{"203.0.113.0/24", "2001:db8:bad::/48"},
+		},
+	}
+	limiter, err := NewFromConfig(config, NewMockStore())
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	for _, ip := range []string{"203.0.113.7", "::ffff:203.0.113.8", "2001:db8:bad::1"} {
+		if result, _ := limiter.Check("/api", "GET", ip, ""); result.Allowed {
+			t.Errorf("黑名单网段 %s 应该被拒绝", ip)
+		}
+	}
+
+	// 白名单网段不受限流影响
+	for i := 0; i < 3; i++ {
+		if result, _ := limiter.Check("/api", "GET", "10.20.30.40", ""); !result.Allowed {
+			t.Fatal("白名单网段应该允许")
+		}
+	}
+
+	if _, err := NewFromConfig(&Config{Whitelist: WhitelistConfig{IPs: []string{"10.0.0.0/40"}}}, NewMockStore()); err == nil {
+		t.Error("无效的CIDR应该返回错误")
+	}
+}
+
+func TestAggregateIP(t *testing.T) {
+	tests := []struct {
+		ip         string
+		ipv4, ipv6 int
+		want       string
+	}{
+		{"2001:db8:1:2:3:4:5:6", 0, 64, "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:ffff::1", 0, 64, "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:3:4:5:6", 0, 48, "2001:db8:1::/48"},
+		{"2001:db8::1", 0, 128, "2001:db8::1"},
+		{"fe80::1%eth0", 0, 0, "fe80::1"},
+		{"1.2.3.4", 0, 64, "1.2.3.4"},
+		{"1.2.3.4", 24, 64, "1.2.3.0/24"},
+		// IPv4映射的IPv6地址按IPv4聚合
+		{"::ffff:1.2.3.4", 24, 64, "1.2.3.0/24"},
+		{"::ffff:1.2.3.4", 0, 64, "1.2.3.4"},
+		{"unknown", 24, 64, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := aggregateIP(tt.ip, tt.ipv4, tt.ipv6); got != tt.want {
+			t.Errorf("aggregateIP(%q, %d, %d) = %q, want %q", tt.ip, tt.ipv4, tt.ipv6, got, tt.want)
+		}
+	}
+}
+
+func TestNewFromConfig_InvalidIPAggregation(t *testing.T) {
+	for _, aggregation := range []IPAggregationConfig{{IPv4Prefix: 33}, {IPv6Prefix: 129}, {IPv6Prefix: -1}} {
+		if _, err := NewFromConfig(&Config{IPAggregation: aggregation}, NewMockStore()); err == nil {
+			t.Errorf("IPAggregation %+v 应该返回错误", aggregation)
+		}
+	}
+}
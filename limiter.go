@@ -1,37 +1,68 @@
 package ratelimiter
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Fischlvor/go-ratelimiter/drivers/algorithm"
+	"github.com/Fischlvor/go-ratelimiter/drivers/ban"
 )
 
 // Limiter 限流器
 type Limiter struct {
+	store         ContextStore
+	fixedWindow   *algorithm.FixedWindowLimiter
+	slidingWindow *algorithm.SlidingWindowLimiter
+	windowCounter *algorithm.SlidingWindowCounterLimiter
+	tokenBucket   *algorithm.TokenBucketLimiter
+	leakyBucket   *algorithm.LeakyBucketLimiter
+	gcra          *algorithm.GCRALimiter
+	concurrency   *algorithm.ConcurrencyLimiter
+	bans          *ban.Manager
+	metrics       Metrics
+	tracer        Tracer
+	observers     []Observer
+	// current 当前生效的规则集，重新加载配置时整体原子替换
+	current atomic.Pointer[ruleSet]
+	// configFile 配置文件路径（通过NewFromFile创建时设置，用于Reload）
+	configFile string
+	// updateMu 串行化规则集的替换
+	updateMu sync.Mutex
+	// closers 关闭限流器时依次调用（如关闭决策日志）
+	closers   []func() error
+	closeOnce sync.Once
+}
+
+// ruleSet 由配置编译得到的规则集（创建后只读）
+type ruleSet struct {
 	config             *Config
-	store              Store
-	fixedWindow        *algorithm.FixedWindowLimiter
-	slidingWindow      *algorithm.SlidingWindowLimiter
-	tokenBucket        *algorithm.TokenBucketLimiter
 	defaultAlgorithm   Algorithm
 	globalRule         *Rule
 	rules              []*Rule
-	whitelistIPs       map[string]bool
+	whitelistIPs       *ipSet
 	whitelistUsers     map[string]bool
-	blacklistIPs       map[string]bool
+	blacklistIPs       *ipSet
 	blacklistUsers     map[string]bool
 	autoBanEnabled     bool
 	autoBanDimensions  map[string]bool
+	banDimensions      map[string]bool
 	violationThreshold int64
 	violationWindow    time.Duration
 	banDuration        time.Duration
+	banEscalation      []time.Duration
+	escalationWindow   time.Duration
+	ipv4Prefix         int
+	ipv6Prefix         int
 }
 
 // NewFromFile 从配置文件创建限流器
-func NewFromFile(configFile string, store Store) (*Limiter, error) {
+func NewFromFile(configFile string, store Store, options ...Option) (*Limiter, error) {
 	// 获取配置文件路径
 	configPath, err := GetConfigPath(configFile)
 	if err != nil {
@@ -44,63 +75,146 @@ func NewFromFile(configFile string, store Store) (*Limiter, error) {
 		return nil, err
 	}
 
-	return NewFromConfig(config, store)
+	limiter, err := NewFromConfig(config, store, options...)
+	if err != nil {
+		return nil, err
+	}
+	limiter.configFile = configPath
+	return limiter, nil
 }
 
 // NewFromConfig 从配置对象创建限流器
-func NewFromConfig(config *Config, store Store) (*Limiter, error) {
+func NewFromConfig(config *Config, store Store, options ...Option) (*Limiter, error) {
+	rs, err := newRuleSet(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// 标记存储返回的错误，观察者只对存储错误触发 OnStoreError
+	marking := newErrorMarkingStore(store)
 	limiter := &Limiter{
+		store:         marking,
+		fixedWindow:   algorithm.NewFixedWindowLimiter(marking),
+		slidingWindow: algorithm.NewSlidingWindowLimiter(marking),
+		windowCounter: algorithm.NewSlidingWindowCounterLimiter(marking),
+		tokenBucket:   algorithm.NewTokenBucketLimiter(marking),
+		leakyBucket:   algorithm.NewLeakyBucketLimiter(marking),
+		gcra:          algorithm.NewGCRALimiter(marking),
+		concurrency:   algorithm.NewConcurrencyLimiter(marking),
+		bans:          ban.NewManager(marking),
+		metrics:       nopMetrics{},
+		tracer:        nopTracer{},
+	}
+	for _, opt := range options {
+		opt(limiter)
+	}
+	limiter.current.Store(rs)
+
+	// 启用决策日志
+	if config.DecisionLog.Enabled {
+		logger, closeFn, err := limiter.openDecisionLog(config.DecisionLog)
+		if err != nil {
+			return nil, err
+		}
+		limiter.observers = append(limiter.observers, logger)
+		limiter.closers = append(limiter.closers, closeFn)
+	}
+
+	return limiter, nil
+}
+
+// newRuleSet 编译配置为规则集
+func newRuleSet(config *Config) (*ruleSet, error) {
+	rs := &ruleSet{
 		config:            config,
-		store:             store,
-		fixedWindow:       algorithm.NewFixedWindowLimiter(store),
-		slidingWindow:     algorithm.NewSlidingWindowLimiter(store),
-		tokenBucket:       algorithm.NewTokenBucketLimiter(store),
 		defaultAlgorithm:  Algorithm(config.Default.Algorithm),
-		whitelistIPs:      make(map[string]bool),
 		whitelistUsers:    make(map[string]bool),
-		blacklistIPs:      make(map[string]bool),
 		blacklistUsers:    make(map[string]bool),
 		autoBanDimensions: make(map[string]bool),
+		banDimensions:     make(map[string]bool),
 	}
 
 	// 加载白名单
-	for _, ip := range config.Whitelist.IPs {
-		limiter.whitelistIPs[ip] = true
+	whitelistIPs, err := newIPSet(config.Whitelist.IPs)
+	if err != nil {
+		return nil, fmt.Errorf("无效的IP白名单: %w", err)
 	}
+	rs.whitelistIPs = whitelistIPs
 	for _, user := range config.Whitelist.Users {
-		limiter.whitelistUsers[user] = true
+		rs.whitelistUsers[user] = true
 	}
 
 	// 加载黑名单
-	for _, ip := range config.Blacklist.IPs {
-		limiter.blacklistIPs[ip] = true
+	blacklistIPs, err := newIPSet(config.Blacklist.IPs)
+	if err != nil {
+		return nil, fmt.Errorf("无效的IP黑名单: %w", err)
 	}
+	rs.blacklistIPs = blacklistIPs
 	for _, user := range config.Blacklist.Users {
-		limiter.blacklistUsers[user] = true
+		rs.blacklistUsers[user] = true
+	}
+	if config.Blacklist.Dynamic {
+		rs.banDimensions[BanDimensionIP] = true
+		rs.banDimensions[BanDimensionUser] = true
+	}
+
+	// 加载IP聚合配置
+	if p := config.IPAggregation.IPv4Prefix; p < 0 || p > 32 {
+		return nil, fmt.Errorf("无效的IPv4聚合前缀长度: %d", p)
+	}
+	if p := config.IPAggregation.IPv6Prefix; p < 0 || p > 128 {
+		return nil, fmt.Errorf("无效的IPv6聚合前缀长度: %d", p)
 	}
+	rs.ipv4Prefix = config.IPAggregation.IPv4Prefix
+	rs.ipv6Prefix = config.IPAggregation.IPv6Prefix
 
 	// 加载自动拉黑配置
 	if config.AutoBan.Enabled {
-		limiter.autoBanEnabled = true
-		limiter.violationThreshold = config.AutoBan.ViolationThreshold
+		rs.autoBanEnabled = true
+		rs.violationThreshold = config.AutoBan.ViolationThreshold
 
 		// 解析违规窗口
 		violationWindow, err := parseDuration(config.AutoBan.ViolationWindow)
 		if err != nil {
 			return nil, fmt.Errorf("解析违规窗口失败: %w", err)
 		}
-		limiter.violationWindow = violationWindow
+		rs.violationWindow = violationWindow
 
-		// 解析封禁时长
-		banDuration, err := parseDuration(config.AutoBan.BanDuration)
-		if err != nil {
-			return nil, fmt.Errorf("解析封禁时长失败: %w", err)
+		// 解析封禁时长（设置了递增封禁时长时可省略）
+		if config.AutoBan.BanDuration != "" || len(config.AutoBan.BanEscalation) == 0 {
+			banDuration, err := parseDuration(config.AutoBan.BanDuration)
+			if err != nil {
+				return nil, fmt.Errorf("解析封禁时长失败: %w", err)
+			}
+			rs.banDuration = banDuration
+		}
+
+		// 解析递增封禁时长
+		for i, s := range config.AutoBan.BanEscalation {
+			d, err := parseDuration(s)
+			if err != nil {
+				return nil, fmt.Errorf("解析递增封禁时长[%d]失败: %w", i, err)
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("递增封禁时长[%d]必须大于0", i)
+			}
+			rs.banEscalation = append(rs.banEscalation, d)
+		}
+		if len(rs.banEscalation) > 0 {
+			rs.escalationWindow = DefaultEscalationWindow
+			if config.AutoBan.EscalationWindow != "" {
+				window, err := parseDuration(config.AutoBan.EscalationWindow)
+				if err != nil {
+					return nil, fmt.Errorf("解析封禁次数记忆时长失败: %w", err)
+				}
+				rs.escalationWindow = window
+			}
 		}
-		limiter.banDuration = banDuration
 
 		// 加载拉黑维度
 		for _, dim := range config.AutoBan.Dimensions {
-			limiter.autoBanDimensions[dim] = true
+			rs.autoBanDimensions[dim] = true
+			rs.banDimensions[dim] = true
 		}
 	}
 
@@ -108,10 +222,10 @@ func NewFromConfig(config *Config, store Store) (*Limiter, error) {
 	if config.Global != nil {
 		algo := Algorithm(config.Global.Algorithm)
 		if algo == "" {
-			algo = limiter.defaultAlgorithm
+			algo = rs.defaultAlgorithm
 		}
 
-		limiter.globalRule = &Rule{
+		rs.globalRule = &Rule{
 			Name: "全局限流",
 			Path: "*",
 			By:   LimitByGlobal,
@@ -119,7 +233,7 @@ func NewFromConfig(config *Config, store Store) (*Limiter, error) {
 
 		// 设置算法参数
 		if err := setAlgorithmParams(
-			limiter.globalRule,
+			rs.globalRule,
 			algo,
 			config.Global.Params,
 		); err != nil {
@@ -129,191 +243,525 @@ func NewFromConfig(config *Config, store Store) (*Limiter, error) {
 
 	// 转换规则列表
 	for _, ruleConfig := range config.Rules {
-		rule, err := ruleConfig.ToRule(limiter.defaultAlgorithm)
+		rule, err := ruleConfig.ToRule(rs.defaultAlgorithm)
 		if err != nil {
 			return nil, fmt.Errorf("转换规则失败: %w", err)
 		}
-		limiter.rules = append(limiter.rules, rule)
+		if ruleConfig.Continue == nil {
+			rule.Continue = config.Default.Continue
+		}
+		rs.rules = append(rs.rules, rule)
 	}
 
-	return limiter, nil
+	return rs, nil
+}
+
+// snapshot 获取当前生效的规则集
+func (l *Limiter) snapshot() *ruleSet {
+	return l.current.Load()
 }
 
 // Check 检查请求是否允许通过
 func (l *Limiter) Check(path, method, ip, userID string) (*Result, error) {
+	return l.CheckRequestContext(context.Background(), &Request{
+		Path:   path,
+		Method: method,
+		IP:     ip,
+		UserID: userID,
+	})
+}
+
+// CheckContext 检查请求是否允许通过（支持context）
+// ctx 会传递到存储调用，用于遵循请求的取消和超时
+func (l *Limiter) CheckContext(ctx context.Context, path, method, ip, userID string) (*Result, error) {
+	return l.CheckRequestContext(ctx, &Request{
+		Path:   path,
+		Method: method,
+		IP:     ip,
+		UserID: userID,
+	})
+}
+
+// CheckN 检查消耗cost个配额的请求是否允许通过（如批量接口按条数计费）
+// 规则配置了cost时以规则为准
+func (l *Limiter) CheckN(path, method, ip, userID string, cost int64) (*Result, error) {
+	return l.CheckNContext(context.Background(), path, method, ip, userID, cost)
+}
+
+// CheckNContext 检查消耗cost个配额的请求是否允许通过（支持context）
+func (l *Limiter) CheckNContext(ctx context.Context, path, method, ip, userID string, cost int64) (*Result, error) {
+	return l.CheckRequestContext(ctx, &Request{
+		Path:   path,
+		Method: method,
+		IP:     ip,
+		UserID: userID,
+		Cost:   cost,
+	})
+}
+
+// CheckWithAttributes 检查请求是否允许通过（携带自定义属性）
+// attrs 供自定义维度（by: custom）的key提取器使用，例如 api_key、tenant_id 或请求头
+func (l *Limiter) CheckWithAttributes(path, method, ip, userID string, attrs map[string]string) (*Result, error) {
+	return l.CheckWithAttributesContext(context.Background(), path, method, ip, userID, attrs)
+}
+
+// CheckWithAttributesContext 检查请求是否允许通过（携带自定义属性，支持context）
+func (l *Limiter) CheckWithAttributesContext(ctx context.Context, path, method, ip, userID string, attrs map[string]string) (*Result, error) {
+	return l.CheckRequestContext(ctx, &Request{
+		Path:       path,
+		Method:     method,
+		IP:         ip,
+		UserID:     userID,
+		Attributes: attrs,
+	})
+}
+
+// CheckRequest 检查请求是否允许通过（使用请求描述）
+func (l *Limiter) CheckRequest(req *Request) (*Result, error) {
+	return l.CheckRequestContext(context.Background(), req)
+}
+
+// CheckRequestContext 检查请求是否允许通过（使用请求描述，支持context）
+// 规则匹配、key构建和黑白名单检查都基于req完成
+func (l *Limiter) CheckRequestContext(ctx context.Context, req *Request) (*Result, error) {
+	return l.check(ctx, req, modeCheck)
+}
+
+// checkMode 限流检查方式
+type checkMode int
+
+const (
+	// modeCheck 消耗配额，被拒绝时记录违规，并统计和通知观察者
+	modeCheck checkMode = iota
+	// modePeek 只读取状态，不消耗配额、不记录违规、不统计、不通知观察者
+	modePeek
+	// modeReserve 预检通过后消耗配额，被拒绝时不记录违规、不统计、不通知观察者
+	modeReserve
+)
+
+// observes 该检查方式下是否统计并通知本次决策
+func (m checkMode) observes(allowed bool) bool {
+	return m == modeCheck || (m == modeReserve && allowed)
+}
+
+// check 执行限流检查，记录span并按检查方式通知观察者
+func (l *Limiter) check(ctx context.Context, req *Request, mode checkMode) (*Result, error) {
+	peek := mode == modePeek
+	name := "ratelimiter.Check"
+	if peek {
+		name = "ratelimiter.Peek"
+	}
+	ctx, span := l.tracer.Start(ctx, name)
+	defer span.End()
+
+	if req != nil {
+		span.SetAttributes(
+			Attribute{Key: AttrKeyPath, Value: req.Path},
+			Attribute{Key: AttrKeyMethod, Value: req.Method},
+		)
+	}
+
+	result, err := l.evaluate(ctx, req, mode)
+	if err != nil {
+		span.RecordError(err)
+		operation := OperationCheck
+		if peek {
+			operation = OperationPeek
+		}
+		l.notifyStoreError(operation, req, err)
+		return nil, err
+	}
+
+	if rule := result.rule; rule != nil {
+		span.SetAttributes(
+			Attribute{Key: AttrKeyRule, Value: rule.Name},
+			Attribute{Key: AttrKeyAlgorithm, Value: string(rule.Algorithm)},
+		)
+	}
+	span.SetAttributes(
+		Attribute{Key: AttrKeyDecision, Value: decision(result.Allowed)},
+		Attribute{Key: AttrKeyLimit, Value: result.Limit},
+		Attribute{Key: AttrKeyRemaining, Value: result.Remaining},
+	)
+	if result.Reason != ReasonNone {
+		span.SetAttributes(Attribute{Key: AttrKeyReason, Value: string(result.Reason)})
+	}
+	if mode.observes(result.Allowed) {
+		l.notifyDecision(req, result)
+	}
+	return result, nil
+}
+
+// evaluate 执行限流检查，只有modeCheck会在被拒绝时记录违规
+func (l *Limiter) evaluate(ctx context.Context, req *Request, mode checkMode) (*Result, error) {
+	if req == nil {
+		req = &Request{}
+	}
+
+	// 整个检查过程使用同一份规则集，不受并发重新加载的影响
+	rs := l.snapshot()
+
 	// 检查是否启用限流
-	if !l.config.Default.Enabled {
+	if !rs.config.Default.Enabled {
 		return &Result{Allowed: true}, nil
 	}
 
 	// ===== 第一优先级：用户维度 =====
-	if userID != "" {
+	if req.UserID != "" {
 		// 1. 检查用户黑名单（最高优先级）
-		if l.blacklistUsers[userID] {
-			return &Result{Allowed: false}, nil
+		if rs.blacklistUsers[req.UserID] {
+			return blacklistedResult(BanDimensionUser, req.UserID), nil
 		}
 		// 检查动态用户黑名单
-		if l.autoBanEnabled && l.autoBanDimensions["user"] {
-			banned, err := l.store.Get("blacklist:user:" + userID)
+		if rs.banDimensions[BanDimensionUser] {
+			result, err := l.checkBan(ctx, BanDimensionUser, req.UserID)
 			if err != nil {
 				return nil, fmt.Errorf("检查用户黑名单失败: %w", err)
 			}
-			if banned > 0 {
-				return &Result{Allowed: false}, nil
+			if result != nil {
+				return result, nil
 			}
 		}
 
 		// 2. 检查用户白名单（第二优先级，直接通过，不检查IP）
-		if l.whitelistUsers[userID] {
+		if rs.whitelistUsers[req.UserID] {
 			return &Result{Allowed: true}, nil
 		}
 	}
 
+	// 静态黑白名单按原始IP匹配，限流key、违规计数和封禁使用聚合后的IP
+	clientIP := req.IP
+	req = rs.aggregateRequest(req)
+
 	// ===== 第二优先级：IP维度 =====
-	if ip != "" {
+	if req.IP != "" {
 		// 3. 检查IP黑名单
-		if l.blacklistIPs[ip] {
-			return &Result{Allowed: false}, nil
+		if rs.blacklistIPs.contains(clientIP) {
+			return blacklistedResult(BanDimensionIP, clientIP), nil
 		}
 		// 检查动态IP黑名单
-		if l.autoBanEnabled && l.autoBanDimensions["ip"] {
-			banned, err := l.store.Get("blacklist:ip:" + ip)
+		if rs.banDimensions[BanDimensionIP] {
+			result, err := l.checkBan(ctx, BanDimensionIP, req.IP)
 			if err != nil {
 				return nil, fmt.Errorf("检查IP黑名单失败: %w", err)
 			}
-			if banned > 0 {
-				return &Result{Allowed: false}, nil
+			if result != nil {
+				return result, nil
 			}
 		}
 
 		// 4. 检查IP白名单
-		if l.whitelistIPs[ip] {
+		if rs.whitelistIPs.contains(clientIP) {
 			return &Result{Allowed: true}, nil
 		}
 	}
 
 	// ===== 第三优先级：限流检查 =====
 	// 5. 检查全局限流
-	if l.globalRule != nil {
-		result, err := l.checkRule(l.globalRule, path, method, ip, userID)
+	// leases 已占用的并发名额，refunds 已消耗的配额，请求被拒绝时立即释放和退还，通过时随结果返回
+	var leases []Lease
+	var refunds []Refund
+	var globalResult *Result
+	if rs.globalRule != nil {
+		result, err := l.evalRule(ctx, rs.globalRule, req, mode)
 		if err != nil {
 			return nil, err
 		}
 		if !result.Allowed {
 			// 全局限流不记录违规（因为不是用户/IP的问题）
+			result.Reason = ReasonGlobalLimit
 			return result, nil
 		}
+		leases = append(leases, result.Leases...)
+		refunds = append(refunds, result.Refunds...)
+		globalResult = result
 	}
 
 	// 6. 检查规则列表（按顺序匹配）
-	for _, rule := range l.rules {
-		// 检查路径是否匹配
-		if !l.matchPath(rule.Path, path) {
-			continue
-		}
-
-		// 检查方法是否匹配
-		if rule.Method != "" && rule.Method != method {
+	// 规则设置continue时继续检查后续匹配的规则，返回最严格的结果
+	var final *Result
+	for _, rule := range rs.rules {
+		// 检查路径和方法是否匹配
+		if !l.matchRule(rule, req) {
 			continue
 		}
 
 		// 匹配到规则，执行限流检查
-		result, err := l.checkRule(rule, path, method, ip, userID)
+		result, err := l.evalRule(ctx, rule, req, mode)
 		if err != nil {
+			l.releaseLeases(ctx, leases)
+			l.refundQuota(ctx, refunds)
 			return nil, err
 		}
 
 		// 如果被限流，根据规则配置决定是否记录违规
+		// 被拒绝后不再检查后续规则，并退还之前的规则已消耗的配额
 		if !result.Allowed {
-			if rule.RecordViolation {
+			l.releaseLeases(ctx, leases)
+			l.refundQuota(ctx, refunds)
+			result.Reason = ReasonRuleLimit
+			if rule.RecordViolation && mode == modeCheck {
 				weight := rule.ViolationWeight
 				if weight <= 0 {
 					weight = 1 // 默认权重为1
 				}
-				if err := l.recordViolationWithWeight(ip, userID, weight); err != nil {
+				if err := l.recordViolation(ctx, rs, req, rule.Name, weight); err != nil {
 					return nil, fmt.Errorf("记录违规失败: %w", err)
 				}
 			}
 			return result, nil
 		}
 
-		// 匹配到规则且通过，返回该规则的限流信息
-		return result, nil
+		leases = append(leases, result.Leases...)
+		refunds = append(refunds, result.Refunds...)
+		if final == nil || isMoreRestrictive(result, final) {
+			final = result
+		}
+		if !rule.Continue {
+			break
+		}
+	}
+
+	// 匹配到规则且全部通过，返回最严格规则的限流信息
+	if final != nil {
+		final.Leases = leases
+		final.Refunds = refunds
+		return final, nil
 	}
 
 	// 没有匹配到任何规则，返回全局限流信息
 	// 会根据是否有 userID 自动选择维度（user 或 ip）
-	if l.globalRule != nil {
-		return l.checkRule(l.globalRule, path, method, ip, userID)
+	if globalResult != nil {
+		return globalResult, nil
 	}
 
 	// 没有全局限流配置，返回默认允许
 	return &Result{Allowed: true}, nil
 }
 
-// checkRule 检查单个规则
-func (l *Limiter) checkRule(rule *Rule, path, method, ip, userID string) (*Result, error) {
+// blacklistedResult 命中静态黑名单的结果（永久拒绝，没有重试时间）
+func blacklistedResult(dimension, identifier string) *Result {
+	return &Result{Allowed: false, Reason: ReasonBlacklisted, Dimension: dimension, Key: identifier}
+}
+
+// checkBan 检查动态封禁，被封禁时返回带剩余封禁时长的结果，未被封禁时返回nil
+func (l *Limiter) checkBan(ctx context.Context, dimension, identifier string) (*Result, error) {
+	key := ban.BlacklistKey(dimension, identifier)
+	banned, err := l.store.GetContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if banned <= 0 {
+		return nil, nil
+	}
+
+	result := &Result{Allowed: false, Reason: ReasonBanned, Dimension: dimension, Key: identifier}
+	ttl, err := l.store.TTLContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	// 永久封禁（或刚好到期）时没有重试时间
+	if ttl > 0 {
+		result.BanTTL = ttl
+		result.RetryAfterMillis = ttl.Milliseconds()
+		result.RetryAfter = int64(math.Ceil(ttl.Seconds()))
+		result.ResetMillis = time.Now().Add(ttl).UnixMilli()
+		result.Reset = (result.ResetMillis + 999) / 1000
+	}
+	return result, nil
+}
+
+// aggregateRequest 返回IP按配置前缀聚合后的请求（未配置聚合时返回原请求）
+func (rs *ruleSet) aggregateRequest(req *Request) *Request {
+	ip := rs.aggregateIP(req.IP)
+	if ip == req.IP {
+		return req
+	}
+	aggregated := *req
+	aggregated.IP = ip
+	return &aggregated
+}
+
+// aggregateIP 按配置的前缀长度聚合IP（未配置聚合时原样返回）
+func (rs *ruleSet) aggregateIP(ip string) string {
+	if ip == "" || (rs.ipv4Prefix == 0 && rs.ipv6Prefix == 0) {
+		return ip
+	}
+	return aggregateIP(ip, rs.ipv4Prefix, rs.ipv6Prefix)
+}
+
+// isMoreRestrictive 判断结果a是否比b更严格
+// 拒绝优先于允许；同为拒绝时重试时间更长者更严格；同为允许时排队时间更长、剩余配额更少者更严格
+func isMoreRestrictive(a, b *Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		if a.RetryAfter != b.RetryAfter {
+			return a.RetryAfter > b.RetryAfter
+		}
+		return a.RetryAfterMillis > b.RetryAfterMillis
+	}
+	if a.Delay != b.Delay {
+		return a.Delay > b.Delay
+	}
+	if a.Remaining != b.Remaining {
+		return a.Remaining < b.Remaining
+	}
+	return a.Reset > b.Reset
+}
+
+// evalRule 检查单个规则，modePeek时只读取状态
+func (l *Limiter) evalRule(ctx context.Context, rule *Rule, req *Request, mode checkMode) (*Result, error) {
+	peek := mode == modePeek
+
 	// 构建限流key
-	key := l.buildKey(rule, path, ip, userID)
+	key, dimension := l.buildKeyWithDimension(rule, req)
+
+	// 请求消耗的配额数量
+	cost := requestCost(rule, req)
 
 	// 根据算法执行限流检查
-	var ctx *algorithm.Context
+	var algoCtx *algorithm.Context
 	var err error
+	if peek {
+		algoCtx, err = l.peekAlgorithm(ctx, rule, key, cost)
+	} else {
+		algoCtx, err = l.allowAlgorithm(ctx, rule, key, cost)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 转换algorithm.Context到ratelimiter.Result
+	result := &Result{
+		Allowed:          algoCtx.Allowed,
+		Limit:            algoCtx.Limit,
+		Remaining:        algoCtx.Remaining,
+		Reset:            algoCtx.Reset,
+		RetryAfter:       algoCtx.RetryAfter,
+		Delay:            algoCtx.Delay,
+		ResetMillis:      algoCtx.ResetMillis,
+		RetryAfterMillis: algoCtx.RetryAfterMillis,
+		Rule:             rule.Name,
+		Dimension:        dimension,
+		Key:              key,
+		rule:             rule,
+	}
+	// 算法未提供毫秒精度时由秒换算
+	if result.ResetMillis == 0 {
+		result.ResetMillis = result.Reset * 1000
+	}
+	if result.RetryAfterMillis == 0 {
+		result.RetryAfterMillis = result.RetryAfter * 1000
+	}
+	// 消耗超过规则容量的请求等待多久都无法通过，不给出重试时间
+	if !result.Allowed && cost > maxCost(rule) {
+		result.RetryAfter = 0
+		result.RetryAfterMillis = 0
+	}
+	if algoCtx.LeaseID != "" {
+		result.Leases = []Lease{{Key: key, ID: algoCtx.LeaseID, Duration: rule.Window}}
+	}
+	if mode.observes(result.Allowed) {
+		l.metrics.ObserveDecision(rule.Name, rule.Algorithm, decision(result.Allowed))
+	}
+	if result.Allowed && !peek && refundable(rule.Algorithm) {
+		result.Refunds = []Refund{{Key: key, Cost: cost, rule: rule, token: algoCtx.RefundToken}}
+	}
+	return result, nil
+}
 
+// allowAlgorithm 按规则的算法消耗配额
+func (l *Limiter) allowAlgorithm(ctx context.Context, rule *Rule, key string, cost int64) (*algorithm.Context, error) {
 	switch rule.Algorithm {
 	case AlgorithmFixedWindow:
-		ctx, err = l.fixedWindow.Allow(key, rule.Limit, rule.Window)
+		return l.fixedWindow.AllowNContext(ctx, key, rule.Limit, rule.Window, cost)
 	case AlgorithmSlidingWindow:
-		ctx, err = l.slidingWindow.Allow(key, rule.Limit, rule.Window)
+		return l.slidingWindow.AllowNContext(ctx, key, rule.Limit, rule.Window, cost)
+	case AlgorithmSlidingWindowCounter:
+		return l.windowCounter.AllowNContext(ctx, key, rule.Limit, rule.Window, cost)
 	case AlgorithmTokenBucket:
-		ctx, err = l.tokenBucket.Allow(key, rule.Capacity, rule.Rate)
+		return l.tokenBucket.AllowNContext(ctx, key, rule.Capacity, rule.Rate, cost)
+	case AlgorithmLeakyBucket:
+		return l.leakyBucket.AllowNContext(ctx, key, rule.Capacity, rule.Rate, cost)
+	case AlgorithmLeakyBucketQueue:
+		return l.leakyBucket.QueueNContext(ctx, key, rule.Capacity, rule.Rate, cost)
+	case AlgorithmGCRA:
+		return l.gcra.AllowNContext(ctx, key, rule.Capacity, rule.Rate, cost)
+	case AlgorithmConcurrency:
+		// 并发限制按请求占用名额，不使用cost
+		return l.concurrency.AcquireContext(ctx, key, rule.Limit, rule.Window)
 	default:
 		return nil, fmt.Errorf("未知的算法: %s", rule.Algorithm)
 	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	// 转换algorithm.Context到ratelimiter.Result
-	return &Result{
-		Allowed:    ctx.Allowed,
-		Limit:      ctx.Limit,
-		Remaining:  ctx.Remaining,
-		Reset:      ctx.Reset,
-		RetryAfter: ctx.RetryAfter,
-	}, nil
 }
 
-// buildKey 构建限流key
-func (l *Limiter) buildKey(rule *Rule, path, ip, userID string) string {
+// buildKeyWithDimension 构建限流key，同时返回实际使用的维度（user/custom取不到标识时降级为ip）
+func (l *Limiter) buildKeyWithDimension(rule *Rule, req *Request) (string, string) {
 	var parts []string
 
 	// 添加规则名称或路径
 	if rule.Name != "" {
 		parts = append(parts, rule.Name)
 	} else {
-		parts = append(parts, path)
+		parts = append(parts, req.Path)
 	}
 
 	// 根据限流维度添加key部分
 	switch rule.By {
 	case LimitByIP:
-		parts = append(parts, "ip", ip)
+		parts = append(parts, "ip", req.IP)
 	case LimitByUser:
-		if userID != "" {
-			parts = append(parts, "user", userID)
+		if req.UserID != "" {
+			parts = append(parts, "user", req.UserID)
 		} else {
 			// 如果没有用户ID，降级为IP限流
-			parts = append(parts, "ip", ip)
+			parts = append(parts, "ip", req.IP)
 		}
 	case LimitByPath:
-		parts = append(parts, "path", path)
+		parts = append(parts, "path", req.Path)
 	case LimitByGlobal:
 		parts = append(parts, "global")
+	case LimitByCustom:
+		if value, ok := l.extractCustomKey(rule, req); ok {
+			parts = append(parts, "custom", rule.Key, value)
+		} else {
+			// 如果提取不到自定义key，降级为IP限流
+			parts = append(parts, "ip", req.IP)
+		}
 	}
 
-	return strings.Join(parts, ":")
+	// parts[1] 为实际使用的维度
+	dimension := string(rule.By)
+	if len(parts) > 1 {
+		dimension = parts[1]
+	}
+	return strings.Join(parts, ":"), dimension
+}
+
+// extractCustomKey 使用规则配置的提取器获取自定义维度的标识
+func (l *Limiter) extractCustomKey(rule *Rule, req *Request) (string, bool) {
+	extractor, arg, err := lookupKeyExtractor(rule.Key)
+	if err != nil {
+		return "", false
+	}
+	return extractor(req, arg)
+}
+
+// matchRule 检查规则的路径和方法是否匹配请求
+func (l *Limiter) matchRule(rule *Rule, req *Request) bool {
+	// 检查路径是否匹配
+	if !l.matchPath(rule.Path, req.Path) {
+		return false
+	}
+
+	// 检查方法是否匹配
+	if rule.Method != "" && rule.Method != req.Method {
+		return false
+	}
+
+	return true
 }
 
 // matchPath 检查路径是否匹配
@@ -334,62 +782,17 @@ func (l *Limiter) matchPath(pattern, path string) bool {
 
 // IsEnabled 检查限流是否启用
 func (l *Limiter) IsEnabled() bool {
-	return l.config.Default.Enabled
+	return l.snapshot().config.Default.Enabled
 }
 
-// GetConfig 获取配置
+// GetConfig 获取当前生效的配置
 func (l *Limiter) GetConfig() *Config {
-	return l.config
+	return l.snapshot().config
 }
 
-// isBlacklisted 检查是否在黑名单中（静态 + 动态）
-func (l *Limiter) isBlacklisted(ip, userID string) (bool, error) {
-	// 检查静态IP黑名单
-	if ip != "" && l.blacklistIPs[ip] {
-		return true, nil
-	}
-
-	// 检查静态用户黑名单
-	if userID != "" && l.blacklistUsers[userID] {
-		return true, nil
-	}
-
-	// 如果启用了自动拉黑，检查动态黑名单
-	if l.autoBanEnabled {
-		// 检查IP是否被自动拉黑
-		if ip != "" && l.autoBanDimensions["ip"] {
-			banned, err := l.store.Get("blacklist:ip:" + ip)
-			if err != nil {
-				return false, err
-			}
-			if banned > 0 {
-				return true, nil
-			}
-		}
-
-		// 检查用户是否被自动拉黑
-		if userID != "" && l.autoBanDimensions["user"] {
-			banned, err := l.store.Get("blacklist:user:" + userID)
-			if err != nil {
-				return false, err
-			}
-			if banned > 0 {
-				return true, nil
-			}
-		}
-	}
-
-	return false, nil
-}
-
-// recordViolation 记录违规并检查是否需要自动拉黑（权重为1）
-func (l *Limiter) recordViolation(ip, userID string) error {
-	return l.recordViolationWithWeight(ip, userID, 1)
-}
-
-// recordViolationWithWeight 记录违规并检查是否需要自动拉黑（带权重）
-func (l *Limiter) recordViolationWithWeight(ip, userID string, weight int) error {
-	if !l.autoBanEnabled {
+// recordViolation 记录违规并检查是否需要自动拉黑（带权重，req.IP为聚合后的IP，rule为触发违规的规则名称）
+func (l *Limiter) recordViolation(ctx context.Context, rs *ruleSet, req *Request, rule string, weight int) error {
+	if !rs.autoBanEnabled {
 		return nil
 	}
 
@@ -398,15 +801,15 @@ func (l *Limiter) recordViolationWithWeight(ip, userID string, weight int) error
 	}
 
 	// 记录IP违规
-	if ip != "" && l.autoBanDimensions["ip"] {
-		if err := l.checkAndBanWithWeight("ip", ip, weight); err != nil {
+	if req.IP != "" && rs.autoBanDimensions[BanDimensionIP] {
+		if err := l.checkAndBan(ctx, rs, rule, BanDimensionIP, req.IP, weight); err != nil {
 			return err
 		}
 	}
 
 	// 记录用户违规
-	if userID != "" && l.autoBanDimensions["user"] {
-		if err := l.checkAndBanWithWeight("user", userID, weight); err != nil {
+	if req.UserID != "" && rs.autoBanDimensions[BanDimensionUser] {
+		if err := l.checkAndBan(ctx, rs, rule, BanDimensionUser, req.UserID, weight); err != nil {
 			return err
 		}
 	}
@@ -414,48 +817,90 @@ func (l *Limiter) recordViolationWithWeight(ip, userID string, weight int) error
 	return nil
 }
 
-// checkAndBan 检查违规次数并自动拉黑（权重为1）
-func (l *Limiter) checkAndBan(dimension, identifier string) error {
-	return l.checkAndBanWithWeight(dimension, identifier, 1)
-}
-
-// checkAndBanWithWeight 检查违规次数并自动拉黑（带权重）
-func (l *Limiter) checkAndBanWithWeight(dimension, identifier string, weight int) error {
-	violationKey := fmt.Sprintf("violation:%s:%s", dimension, identifier)
-	blacklistKey := fmt.Sprintf("blacklist:%s:%s", dimension, identifier)
+// checkAndBan 检查违规次数并自动拉黑（带权重，rule为触发违规的规则名称）
+func (l *Limiter) checkAndBan(ctx context.Context, rs *ruleSet, rule, dimension, identifier string, weight int) error {
+	violationKey := ban.ViolationKey(dimension, identifier)
 
 	if weight <= 0 {
 		weight = 1
 	}
 
 	// 增加违规计数（按权重）
-	count, err := l.store.IncrBy(violationKey, int64(weight))
+	count, err := l.store.IncrByContext(ctx, violationKey, int64(weight))
 	if err != nil {
 		return err
 	}
+	l.metrics.ObserveViolation(dimension, weight)
+	if len(l.observers) > 0 {
+		event := ViolationEvent{
+			Time:       time.Now(),
+			Rule:       rule,
+			Dimension:  dimension,
+			Identifier: identifier,
+			Weight:     weight,
+			Score:      count,
+			Threshold:  rs.violationThreshold,
+		}
+		l.notify(func(o Observer) { o.OnViolation(event) })
+	}
 
 	// 设置违规记录过期时间（第一次记录时）
 	if count == int64(weight) {
-		if err := l.store.Expire(violationKey, l.violationWindow); err != nil {
+		if err := l.store.ExpireContext(ctx, violationKey, rs.violationWindow); err != nil {
 			return err
 		}
 	}
 
 	// 检查是否达到拉黑阈值
-	if count >= l.violationThreshold {
+	if count >= rs.violationThreshold {
 		// 添加到黑名单
-		if err := l.store.Set(blacklistKey, 1); err != nil {
-			return err
+		reason := fmt.Sprintf("违规分数%d达到阈值%d", count, rs.violationThreshold)
+		duration := rs.banDuration
+		if len(rs.banEscalation) > 0 {
+			// 根据历史封禁次数递增封禁时长
+			offenses, err := l.store.IncrByContext(ctx, ban.OffenseKey(dimension, identifier), 1)
+			if err != nil {
+				return err
+			}
+			if err := l.store.ExpireContext(ctx, ban.OffenseKey(dimension, identifier), rs.escalationWindow); err != nil {
+				return err
+			}
+			duration = escalatedBanDuration(rs.banEscalation, offenses)
+			reason = fmt.Sprintf("%s（第%d次封禁）", reason, offenses)
 		}
-		if err := l.store.Expire(blacklistKey, l.banDuration); err != nil {
+		if err := l.bans.Ban(ctx, dimension, identifier, duration, reason, ban.SourceAuto); err != nil {
 			return err
 		}
+		l.metrics.ObserveBan(dimension, ban.SourceAuto)
+		if len(l.observers) > 0 {
+			event := BanEvent{
+				Time:       time.Now(),
+				Rule:       rule,
+				Dimension:  dimension,
+				Identifier: identifier,
+				Duration:   duration,
+				Reason:     reason,
+				Source:     ban.SourceAuto,
+			}
+			l.notify(func(o Observer) { o.OnBan(event) })
+		}
 
 		// 清除违规记录
-		if err := l.store.Del(violationKey); err != nil {
+		if err := l.store.DelContext(ctx, violationKey); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// escalatedBanDuration 返回第offenses次封禁的时长（超出配置的次数时使用最后一个时长）
+func escalatedBanDuration(escalation []time.Duration, offenses int64) time.Duration {
+	if offenses < 1 {
+		offenses = 1
+	}
+	if offenses > int64(len(escalation)) {
+		return escalation[len(escalation)-1]
+	}
+	return escalation[offenses-1]
+}
@@ -1,10 +1,17 @@
 package ratelimiter
 
 import (
+	"context"
+	"errors"
+	"net/http"
 	"os"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/algorithm"
+	"github.com/Fischlvor/go-ratelimiter/drivers/ban"
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
 )
 
 // MockStore 用于测试的模拟存储
@@ -68,7 +75,26 @@ func (m *MockStore) ZCount(key string, min, max float64) (int64, error) {
 	return 0, nil
 }
 
+// Eval 模拟固定窗口和封禁脚本（其他脚本返回nil）
 func (m *MockStore) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	switch script {
+	case algorithm.FixedWindowScript:
+		key := keys[0]
+		limit, cost := args[0].(int64), args[2].(int64)
+		if m.data[key]+cost > limit {
+			return []interface{}{int64(0), m.data[key], m.ttl[key].Milliseconds(), time.Now().Add(m.ttl[key]).UnixMilli()}, nil
+		}
+		m.data[key] += cost
+		if m.data[key] == cost {
+			m.ttl[key] = time.Duration(args[1].(int64)) * time.Millisecond
+		}
+		return []interface{}{int64(1), m.data[key], m.ttl[key].Milliseconds(), time.Now().Add(m.ttl[key]).UnixMilli()}, nil
+	case ban.BanScript:
+		// 只模拟封禁标记
+		m.data[keys[0]] = 1
+		m.ttl[keys[0]] = time.Duration(args[2].(int64)) * time.Millisecond
+		return int64(1), nil
+	}
 	return nil, nil
 }
 
@@ -96,10 +122,10 @@ func TestNewFromConfig(t *testing.T) {
 	}
 
 	// 检查白名单
-	if !limiter.whitelistIPs["127.0.0.1"] {
+	if !limiter.snapshot().whitelistIPs.contains("127.0.0.1") {
 		t.Error("127.0.0.1 should be in whitelist")
 	}
-	if !limiter.whitelistUsers["admin"] {
+	if !limiter.snapshot().whitelistUsers["admin"] {
 		t.Error("admin should be in whitelist")
 	}
 }
@@ -138,8 +164,8 @@ rules:
 	if limiter == nil {
 		t.Error("限流器不应该为空")
 	}
-	if len(limiter.rules) != 1 {
-		t.Errorf("期望1个规则，实际 %d", len(limiter.rules))
+	if len(limiter.snapshot().rules) != 1 {
+		t.Errorf("期望1个规则，实际 %d", len(limiter.snapshot().rules))
 	}
 
 	// 测试文件不存在
@@ -265,9 +291,9 @@ func TestLimiter_BuildKey(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			key := limiter.buildKey(tt.rule, tt.path, tt.ip, tt.userID)
+			key, _ := limiter.buildKeyWithDimension(tt.rule, &Request{Path: tt.path, IP: tt.ip, UserID: tt.userID})
 			if key != tt.wantKey {
-				t.Errorf("buildKey() = %v, want %v", key, tt.wantKey)
+				t.Errorf("buildKeyWithDimension() = %v, want %v", key, tt.wantKey)
 			}
 		})
 	}
@@ -297,9 +323,9 @@ func TestLimiter_CheckRule(t *testing.T) {
 
 	// 发送5个请求，都应该被允许
 	for i := 0; i < 5; i++ {
-		result, err := limiter.checkRule(rule, "/api/test", "GET", "1.2.3.4", "")
+		result, err := limiter.evalRule(context.Background(), rule, &Request{Path: "/api/test", Method: "GET", IP: "1.2.3.4"}, modeCheck)
 		if err != nil {
-			t.Fatalf("checkRule() error = %v", err)
+			t.Fatalf("evalRule() error = %v", err)
 		}
 		if !result.Allowed {
 			t.Errorf("请求 %d 应该被允许", i+1)
@@ -307,9 +333,9 @@ func TestLimiter_CheckRule(t *testing.T) {
 	}
 
 	// 第6个请求应该被拒绝
-	result, err := limiter.checkRule(rule, "/api/test", "GET", "1.2.3.4", "")
+	result, err := limiter.evalRule(context.Background(), rule, &Request{Path: "/api/test", Method: "GET", IP: "1.2.3.4"}, modeCheck)
 	if err != nil {
-		t.Fatalf("checkRule() error = %v", err)
+		t.Fatalf("evalRule() error = %v", err)
 	}
 	if result.Allowed {
 		t.Error("第6个请求应该被拒绝")
@@ -642,6 +668,16 @@ func TestPriorityOrder(t *testing.T) {
 	}
 }
 
+// isBanned 通过预检判断请求是否命中动态封禁
+func isBanned(t *testing.T, limiter *Limiter, req *Request) bool {
+	t.Helper()
+	result, err := limiter.PeekRequest(req)
+	if err != nil {
+		t.Fatalf("PeekRequest() error = %v", err)
+	}
+	return result.Reason == ReasonBanned
+}
+
 // TestAutoBanIP 测试IP自动拉黑
 func TestAutoBanIP(t *testing.T) {
 	config := &Config{
@@ -688,11 +724,7 @@ func TestAutoBanIP(t *testing.T) {
 	limiter.Check("/api/test", "GET", ip, "")
 
 	// 检查是否被拉黑
-	banned, err := limiter.isBlacklisted(ip, "")
-	if err != nil {
-		t.Fatalf("检查黑名单失败: %v", err)
-	}
-	if !banned {
+	if !isBanned(t, limiter, &Request{IP: ip}) {
 		t.Error("达到违规阈值后应该被自动拉黑")
 	}
 }
@@ -740,11 +772,7 @@ func TestAutoBanUser(t *testing.T) {
 	limiter.Check("/api/test", "GET", "1.2.3.4", userID)
 
 	// 检查是否被拉黑
-	banned, err := limiter.isBlacklisted("", userID)
-	if err != nil {
-		t.Fatalf("检查黑名单失败: %v", err)
-	}
-	if !banned {
+	if !isBanned(t, limiter, &Request{UserID: userID}) {
 		t.Error("达到违规阈值后应该被自动拉黑")
 	}
 }
@@ -791,13 +819,10 @@ func TestAutoBanMultipleDimensions(t *testing.T) {
 	limiter.Check("/api/test", "GET", ip, userID) // 第2次违规
 
 	// IP和用户都应该被拉黑
-	bannedIP, _ := limiter.isBlacklisted(ip, "")
-	bannedUser, _ := limiter.isBlacklisted("", userID)
-
-	if !bannedIP {
+	if !isBanned(t, limiter, &Request{IP: ip}) {
 		t.Error("IP应该被自动拉黑")
 	}
-	if !bannedUser {
+	if !isBanned(t, limiter, &Request{UserID: userID}) {
 		t.Error("用户应该被自动拉黑")
 	}
 }
@@ -843,13 +868,14 @@ func TestIsEnabled(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			limiter := &Limiter{
+			limiter := &Limiter{}
+			limiter.current.Store(&ruleSet{
 				config: &Config{
 					Default: DefaultConfig{
 						Enabled: tt.enabled,
 					},
 				},
-			}
+			})
 			if got := limiter.IsEnabled(); got != tt.want {
 				t.Errorf("IsEnabled() = %v, want %v", got, tt.want)
 			}
@@ -866,9 +892,8 @@ func TestGetConfig(t *testing.T) {
 		},
 	}
 
-	limiter := &Limiter{
-		config: config,
-	}
+	limiter := &Limiter{}
+	limiter.current.Store(&ruleSet{config: config})
 
 	got := limiter.GetConfig()
 	if got != config {
@@ -928,7 +953,7 @@ func TestCheckRule_UnknownAlgorithm(t *testing.T) {
 		Window:    time.Minute,
 	}
 
-	_, err = limiter.checkRule(rule, "/api/test", "GET", "1.2.3.4", "")
+	_, err = limiter.evalRule(context.Background(), rule, &Request{Path: "/api/test", Method: "GET", IP: "1.2.3.4"}, modeCheck)
 	if err == nil {
 		t.Error("期望未知算法错误")
 	}
@@ -947,6 +972,7 @@ func TestBuildKey(t *testing.T) {
 		path   string
 		ip     string
 		userID string
+		attrs  map[string]string
 		want   string
 	}{
 		{
@@ -989,14 +1015,627 @@ func TestBuildKey(t *testing.T) {
 			userID: "",
 			want:   "test:global",
 		},
+		{
+			name:   "自定义维度",
+			rule:   &Rule{Name: "test", By: LimitByCustom, Key: "api_key"},
+			path:   "/api/test",
+			ip:     "1.2.3.4",
+			userID: "",
+			attrs:  map[string]string{AttrAPIKey: "key-abc"},
+			want:   "test:custom:api_key:key-abc",
+		},
+		{
+			name:   "自定义维度但无属性",
+			rule:   &Rule{Name: "test", By: LimitByCustom, Key: "api_key"},
+			path:   "/api/test",
+			ip:     "1.2.3.4",
+			userID: "",
+			want:   "test:ip:1.2.3.4", // 降级为IP
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := limiter.buildKey(tt.rule, tt.path, tt.ip, tt.userID)
+			got, _ := limiter.buildKeyWithDimension(tt.rule, &Request{Path: tt.path, IP: tt.ip, UserID: tt.userID, Attributes: tt.attrs})
 			if got != tt.want {
-				t.Errorf("buildKey() = %v, want %v", got, tt.want)
+				t.Errorf("buildKeyWithDimension() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCheckContext_Canceled 测试context取消后不再访问存储
+func TestCheckContext_Canceled(t *testing.T) {
+	config := &Config{
+		Default: DefaultConfig{
+			Algorithm: "fixed_window",
+			Enabled:   true,
+		},
+		Rules: []RuleConfig{
+			{Name: "test", Path: "/api/test", By: "ip", Params: []string{"10", "1m"}},
+		},
+	}
+
+	store := NewMockStore()
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("创建限流器失败: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = limiter.CheckContext(ctx, "/api/test", "GET", "1.2.3.4", "")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("期望context.Canceled错误，实际: %v", err)
+	}
+	if len(store.data) != 0 {
+		t.Error("context取消后不应写入存储")
+	}
+
+	// 未取消的context正常检查
+	result, err := limiter.CheckContext(context.Background(), "/api/test", "GET", "1.2.3.4", "")
+	if err != nil {
+		t.Fatalf("检查失败: %v", err)
+	}
+	if !result.Allowed {
+		t.Error("请求应该被允许")
+	}
+}
+
+// TestCheckWithAttributes 测试自定义维度按属性独立计数
+func TestCheckWithAttributes(t *testing.T) {
+	config := &Config{
+		Default: DefaultConfig{
+			Algorithm: "fixed_window",
+			Enabled:   true,
+		},
+		Rules: []RuleConfig{
+			{Name: "tenant", Path: "/api/*", By: "custom", Key: "header:X-Tenant-ID", Params: []string{"2", "1m"}},
+		},
+	}
+
+	store := NewMockStore()
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("创建限流器失败: %v", err)
+	}
+
+	tenantA := map[string]string{HeaderAttribute("x-tenant-id"): "a"}
+	tenantB := map[string]string{HeaderAttribute("X-Tenant-ID"): "b"}
+
+	// 同一IP下不同租户分别计数
+	for i := 0; i < 2; i++ {
+		result, err := limiter.CheckWithAttributes("/api/data", "GET", "1.2.3.4", "", tenantA)
+		if err != nil {
+			t.Fatalf("检查失败: %v", err)
+		}
+		if !result.Allowed {
+			t.Errorf("租户a第%d次请求应该允许", i+1)
+		}
+	}
+	result, _ := limiter.CheckWithAttributes("/api/data", "GET", "1.2.3.4", "", tenantA)
+	if result.Allowed {
+		t.Error("租户a第3次请求应该拒绝")
+	}
+
+	result, _ = limiter.CheckWithAttributes("/api/data", "GET", "1.2.3.4", "", tenantB)
+	if !result.Allowed {
+		t.Error("租户b不应受租户a影响")
+	}
+
+	if store.data["tenant:custom:header:X-Tenant-ID:a"] != 2 {
+		t.Errorf("租户a计数 = %d, want 2", store.data["tenant:custom:header:X-Tenant-ID:a"])
+	}
+}
+
+// TestCheckRequest 测试使用请求描述进行检查
+func TestCheckRequest(t *testing.T) {
+	config := &Config{
+		Default: DefaultConfig{
+			Algorithm: "fixed_window",
+			Enabled:   true,
+		},
+		Rules: []RuleConfig{
+			{Name: "apikey", Path: "/open/*", Method: "post", By: "custom", Key: "header:X-API-Key", Params: []string{"1", "1m"}},
+		},
+		Blacklist: BlacklistConfig{
+			Users: []string{"banned"},
+		},
+	}
+
+	store := NewMockStore()
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("创建限流器失败: %v", err)
+	}
+
+	req := &Request{
+		Path:   "/open/orders",
+		Method: "POST",
+		IP:     "1.2.3.4",
+		Header: http.Header{"X-Api-Key": []string{"k1"}},
+	}
+
+	result, err := limiter.CheckRequest(req)
+	if err != nil {
+		t.Fatalf("检查失败: %v", err)
+	}
+	if !result.Allowed {
+		t.Error("第1次请求应该允许")
+	}
+	if store.data["apikey:custom:header:X-API-Key:k1"] != 1 {
+		t.Error("应该按请求头中的API Key计数")
+	}
+
+	result, _ = limiter.CheckRequest(req)
+	if result.Allowed {
+		t.Error("第2次请求应该拒绝")
+	}
+
+	// 方法不匹配时不受规则限制
+	result, _ = limiter.CheckRequest(&Request{Path: "/open/orders", Method: "GET", IP: "1.2.3.4", Header: req.Header})
+	if !result.Allowed {
+		t.Error("GET请求不匹配规则，应该允许")
+	}
+
+	// 黑名单检查同样基于请求描述
+	result, _ = limiter.CheckRequest(&Request{Path: "/other", Method: "GET", UserID: "banned"})
+	if result.Allowed {
+		t.Error("黑名单用户应该被拒绝")
+	}
+
+	// nil请求等价于空请求
+	if _, err := limiter.CheckRequest(nil); err != nil {
+		t.Errorf("CheckRequest(nil) error = %v", err)
+	}
+}
+
+// TestCheck_ContinueRules 测试评估所有匹配的规则
+func TestCheck_ContinueRules(t *testing.T) {
+	yes := true
+	config := &Config{
+		Default: DefaultConfig{
+			Algorithm: "fixed_window",
+			Enabled:   true,
+		},
+		Rules: []RuleConfig{
+			{Name: "per_ip", Path: "/api/*", By: "ip", Params: []string{"2", "1m"}, Continue: &yes},
+			{Name: "per_user", Path: "/api/*", By: "user", Params: []string{"5", "24h"}},
+			{Name: "unreachable", Path: "/api/*", By: "ip", Params: []string{"1", "1m"}},
+		},
+	}
+
+	store := NewMockStore()
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("创建限流器失败: %v", err)
+	}
+
+	// 第1次：per_ip剩余1，per_user剩余4，返回更严格的per_ip
+	result, err := limiter.Check("/api/data", "GET", "1.1.1.1", "u1")
+	if err != nil {
+		t.Fatalf("检查失败: %v", err)
+	}
+	if !result.Allowed || result.Limit != 2 || result.Remaining != 1 {
+		t.Errorf("第1次结果 = %+v, want Allowed Limit=2 Remaining=1", result)
+	}
+	if store.data["per_user:user:u1"] != 1 {
+		t.Error("设置continue后应该继续检查per_user规则")
+	}
+	if store.data["unreachable:ip:1.1.1.1"] != 0 {
+		t.Error("per_user未设置continue，不应该检查后续规则")
+	}
+
+	limiter.Check("/api/data", "GET", "1.1.1.1", "u1")
+
+	// 第3次：per_ip拒绝，不再消耗per_user的配额
+	result, _ = limiter.Check("/api/data", "GET", "1.1.1.1", "u1")
+	if result.Allowed {
+		t.Error("第3次请求应该被per_ip拒绝")
+	}
+	if store.data["per_user:user:u1"] != 2 {
+		t.Errorf("per_user计数 = %d, want 2", store.data["per_user:user:u1"])
+	}
+
+	// 换IP后per_user成为更严格的规则
+	for i := 0; i < 3; i++ {
+		limiter.Check("/api/data", "GET", "2.2.2.2", "u1")
+		limiter.Check("/api/data", "GET", "3.3.3.3", "u1")
+	}
+	result, _ = limiter.Check("/api/data", "GET", "4.4.4.4", "u1")
+	if result.Allowed || result.Limit != 5 {
+		t.Errorf("结果 = %+v, want per_user拒绝", result)
+	}
+}
+
+// TestCheck_DefaultContinue 测试全局continue配置及规则覆盖
+func TestCheck_DefaultContinue(t *testing.T) {
+	no := false
+	config := &Config{
+		Default: DefaultConfig{
+			Algorithm: "fixed_window",
+			Enabled:   true,
+			Continue:  true,
+		},
+		Rules: []RuleConfig{
+			{Name: "a", Path: "/api/*", By: "ip", Params: []string{"10", "1m"}},
+			{Name: "b", Path: "/api/*", By: "ip", Params: []string{"10", "1m"}, Continue: &no},
+			{Name: "c", Path: "/api/*", By: "ip", Params: []string{"10", "1m"}},
+		},
+	}
+
+	store := NewMockStore()
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("创建限流器失败: %v", err)
+	}
+
+	if !limiter.snapshot().rules[0].Continue || limiter.snapshot().rules[1].Continue || !limiter.snapshot().rules[2].Continue {
+		t.Errorf("Continue = %v %v %v, want true false true",
+			limiter.snapshot().rules[0].Continue, limiter.snapshot().rules[1].Continue, limiter.snapshot().rules[2].Continue)
+	}
+
+	limiter.Check("/api/data", "GET", "1.1.1.1", "")
+	if store.data["a:ip:1.1.1.1"] != 1 || store.data["b:ip:1.1.1.1"] != 1 {
+		t.Error("规则a和b都应该被检查")
+	}
+	if store.data["c:ip:1.1.1.1"] != 0 {
+		t.Error("规则b设置continue: false，不应该检查规则c")
+	}
+}
+
+// TestCheck_GlobalCheckedOnce 测试未匹配规则时全局限流只计数一次
+func TestCheck_GlobalCheckedOnce(t *testing.T) {
+	config := &Config{
+		Default: DefaultConfig{
+			Algorithm: "fixed_window",
+			Enabled:   true,
+		},
+		Global: &GlobalConfig{
+			Params: []string{"100", "1m"},
+		},
+	}
+
+	store := NewMockStore()
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("创建限流器失败: %v", err)
+	}
+
+	result, _ := limiter.Check("/any", "GET", "1.1.1.1", "")
+	if store.data["全局限流:global"] != 1 {
+		t.Errorf("全局计数 = %d, want 1", store.data["全局限流:global"])
+	}
+	if result.Remaining != 99 {
+		t.Errorf("Remaining = %d, want 99", result.Remaining)
+	}
+}
+
+func TestIsMoreRestrictive(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *Result
+		want bool
+	}{
+		{"拒绝优先", &Result{Allowed: false}, &Result{Allowed: true}, true},
+		{"允许不比拒绝严格", &Result{Allowed: true}, &Result{Allowed: false}, false},
+		{"拒绝时重试更久更严格", &Result{RetryAfter: 10}, &Result{RetryAfter: 5}, true},
+		{"重试秒数相同按毫秒比较", &Result{RetryAfter: 1, RetryAfterMillis: 900}, &Result{RetryAfter: 1, RetryAfterMillis: 200}, true},
+		{"剩余更少更严格", &Result{Allowed: true, Remaining: 1}, &Result{Allowed: true, Remaining: 5}, true},
+		{"剩余更多不严格", &Result{Allowed: true, Remaining: 5}, &Result{Allowed: true, Remaining: 1}, false},
+		{"剩余相同重置更晚更严格", &Result{Allowed: true, Reset: 200}, &Result{Allowed: true, Reset: 100}, true},
+		{"排队更久更严格", &Result{Allowed: true, Remaining: 5, Delay: time.Second}, &Result{Allowed: true, Remaining: 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isMoreRestrictive(tt.a, tt.b); got != tt.want {
+				t.Errorf("isMoreRestrictive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck_LeakyBucket(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "pay", Path: "/pay", By: "global", Algorithm: "leaky_bucket_queue", Params: []string{"3", "10/s"}},
+			{Name: "notify", Path: "/notify", By: "global", Algorithm: "leaky_bucket", Params: []string{"2", "1/m"}},
+		},
+	}
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("validateConfig() error = %v", err)
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	// 队列模式：请求排队，依次等待
+	var last time.Duration
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Check("/pay", "POST", "1.1.1.1", "")
+		if err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("第%d次请求应该允许", i+1)
+		}
+		if i > 0 && result.Delay <= last {
+			t.Errorf("第%d次 Delay = %v, 应该大于 %v", i+1, result.Delay, last)
+		}
+		last = result.Delay
+	}
+	if result, _ := limiter.Check("/pay", "POST", "1.1.1.1", ""); result.Allowed {
+		t.Error("队列已满应该拒绝")
+	}
+
+	// 计量模式：桶满拒绝
+	for i := 0; i < 2; i++ {
+		if result, _ := limiter.Check("/notify", "POST", "1.1.1.1", ""); !result.Allowed || result.Delay != 0 {
+			t.Fatalf("第%d次请求应该允许且不排队，result = %+v", i+1, result)
+		}
+	}
+	if result, _ := limiter.Check("/notify", "POST", "1.1.1.1", ""); result.Allowed {
+		t.Error("桶满应该拒绝")
+	}
+}
+
+func TestCheck_GCRA(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "gcra", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "api", Path: "/api", By: "ip", Params: []string{"2", "50/s"}},
+		},
+	}
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("validateConfig() error = %v", err)
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if result, _ := limiter.Check("/api", "GET", "1.1.1.1", ""); !result.Allowed {
+			t.Fatalf("第%d次请求应该允许", i+1)
+		}
+	}
+	result, err := limiter.Check("/api", "GET", "1.1.1.1", "")
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	// 每20毫秒恢复一个配额，重试时间为毫秒精度
+	if result.Allowed || result.RetryAfterMillis <= 0 || result.RetryAfterMillis > 20 {
+		t.Errorf("超出突发容量应该拒绝，result = %+v", result)
+	}
+
+	time.Sleep(time.Duration(result.RetryAfterMillis) * time.Millisecond)
+	if result, _ := limiter.Check("/api", "GET", "1.1.1.1", ""); !result.Allowed {
+		t.Error("等待RetryAfterMillis后应该允许")
+	}
+}
+
+func TestCheckRule_MillisFallback(t *testing.T) {
+	limiter, err := NewFromConfig(&Config{Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true}}, NewMockStore())
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	rule := &Rule{Name: "r", By: LimitByIP, Algorithm: AlgorithmFixedWindow, Limit: 10, Window: time.Minute}
+	result, err := limiter.evalRule(context.Background(), rule, &Request{IP: "1.1.1.1"}, modeCheck)
+	if err != nil {
+		t.Fatalf("evalRule() error = %v", err)
+	}
+	if result.ResetMillis != result.Reset*1000 {
+		t.Errorf("ResetMillis = %d, want %d", result.ResetMillis, result.Reset*1000)
+	}
+}
+
+func TestCheck_SlidingWindowCounter(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	limiter, err := NewFromConfig(&Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "export", Path: "/export", By: "user", Algorithm: "sliding_window_counter", Params: []string{"3", "1h"}},
+		},
+	}, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if result, _ := limiter.Check("/export", "GET", "1.1.1.1", "u1"); !result.Allowed {
+			t.Fatalf("第%d次请求应该允许", i+1)
+		}
+	}
+	if result, _ := limiter.Check("/export", "GET", "1.1.1.1", "u1"); result.Allowed {
+		t.Error("超出限制应该拒绝")
+	}
+	if result, _ := limiter.Check("/export", "GET", "1.1.1.1", "u2"); !result.Allowed {
+		t.Error("其他用户不受影响")
+	}
+}
+
+func TestCheck_WeightedCost(t *testing.T) {
+	algorithms := []struct {
+		algorithm string
+		params    []string
+	}{
+		{"fixed_window", []string{"10", "1m"}},
+		{"sliding_window", []string{"10", "1m"}},
+		{"sliding_window_counter", []string{"10", "1m"}},
+		{"token_bucket", []string{"10", "1/h"}},
+		{"leaky_bucket", []string{"10", "1/h"}},
+		{"gcra", []string{"10", "1/h"}},
+	}
+
+	for _, tt := range algorithms {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			store := memory.NewStore(0)
+			defer store.Close()
+
+			config := &Config{
+				Default: DefaultConfig{Algorithm: tt.algorithm, Enabled: true},
+				Rules: []RuleConfig{
+					{Name: "bulk", Path: "/bulk", By: "ip", Params: tt.params, Cost: "header:X-Batch-Size"},
+				},
+			}
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("validateConfig() error = %v", err)
+			}
+			limiter, err := NewFromConfig(config, store)
+			if err != nil {
+				t.Fatalf("NewFromConfig() error = %v", err)
+			}
+
+			check := func(batch string) *Result {
+				t.Helper()
+				header := http.Header{}
+				if batch != "" {
+					header.Set("X-Batch-Size", batch)
+				}
+				result, err := limiter.CheckRequest(&Request{Path: "/bulk", IP: "1.1.1.1", Header: header})
+				if err != nil {
+					t.Fatalf("CheckRequest() error = %v", err)
+				}
+				return result
+			}
+
+			// 批量6条后剩余4条
+			if result := check("6"); !result.Allowed || result.Remaining != 4 {
+				t.Fatalf("批量6条 = %+v, want allowed remaining=4", result)
+			}
+			// 批量5条超出剩余配额
+			if result := check("5"); result.Allowed {
+				t.Fatalf("批量5条应该拒绝, result = %+v", result)
 			}
 		})
 	}
 }
+
+func TestCheckN(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "token_bucket", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "import", Path: "/import", By: "user", Params: []string{"100", "1/h"}},
+			{Name: "fixed", Path: "/fixed", By: "user", Params: []string{"100", "1/h"}, Cost: "10"},
+		},
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	if result, _ := limiter.CheckN("/import", "POST", "1.1.1.1", "u1", 60); !result.Allowed || result.Remaining != 40 {
+		t.Errorf("CheckN(60) = %+v, want allowed remaining=40", result)
+	}
+	if result, _ := limiter.CheckN("/import", "POST", "1.1.1.1", "u1", 50); result.Allowed {
+		t.Errorf("CheckN(50) 应该拒绝, result = %+v", result)
+	}
+
+	// 规则配置了cost时以规则为准
+	if result, _ := limiter.CheckN("/fixed", "POST", "1.1.1.1", "u1", 60); !result.Allowed || result.Remaining != 90 {
+		t.Errorf("CheckN(60) = %+v, want allowed remaining=90", result)
+	}
+}
+
+func TestCheck_Reason(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Global:  &GlobalConfig{Params: []string{"100", "1m"}},
+		Rules: []RuleConfig{
+			{Name: "登录", Path: "/login", By: "user", Params: []string{"1", "1m"}},
+		},
+		Blacklist: BlacklistConfig{IPs: []string{"9.9.9.9"}, Users: []string{"bad"}, Dynamic: true},
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	// 规则限流：没有用户ID时降级为IP维度
+	result, _ := limiter.Check("/login", "POST", "1.2.3.4", "")
+	if !result.Allowed || result.Reason != ReasonNone || result.Rule != "登录" || result.Dimension != "ip" || result.Key != "登录:ip:1.2.3.4" {
+		t.Errorf("通过的结果 = %+v", result)
+	}
+	result, _ = limiter.Check("/login", "POST", "1.2.3.4", "")
+	if result.Allowed || result.Reason != ReasonRuleLimit || result.Rule != "登录" || result.Dimension != "ip" {
+		t.Errorf("规则限流结果 = %+v", result)
+	}
+
+	// 静态黑名单
+	result, _ = limiter.Check("/login", "POST", "9.9.9.9", "")
+	if result.Allowed || result.Reason != ReasonBlacklisted || result.Dimension != BanDimensionIP || result.Key != "9.9.9.9" {
+		t.Errorf("IP黑名单结果 = %+v", result)
+	}
+	if result.RetryAfter != 0 || result.BanTTL != 0 {
+		t.Errorf("静态黑名单不应有重试时间: %+v", result)
+	}
+	result, _ = limiter.Check("/login", "POST", "1.1.1.1", "bad")
+	if result.Reason != ReasonBlacklisted || result.Dimension != BanDimensionUser || result.Key != "bad" {
+		t.Errorf("用户黑名单结果 = %+v", result)
+	}
+
+	// 动态封禁带剩余时长
+	if err := limiter.Ban(BanDimensionIP, "5.5.5.5", 90*time.Second, "刷接口"); err != nil {
+		t.Fatalf("Ban() error = %v", err)
+	}
+	result, _ = limiter.Check("/login", "POST", "5.5.5.5", "")
+	if result.Allowed || result.Reason != ReasonBanned || result.Dimension != BanDimensionIP || result.Key != "5.5.5.5" {
+		t.Errorf("封禁结果 = %+v", result)
+	}
+	if result.BanTTL <= 85*time.Second || result.BanTTL > 90*time.Second {
+		t.Errorf("BanTTL = %v, want about 90s", result.BanTTL)
+	}
+	if result.RetryAfter != 90 || result.RetryAfterMillis <= 85000 || result.Reset < time.Now().Unix()+89 {
+		t.Errorf("封禁重试时间 = %+v", result)
+	}
+
+	// 永久封禁没有重试时间
+	if err := limiter.Ban(BanDimensionUser, "u1", 0, "盗号"); err != nil {
+		t.Fatalf("Ban() error = %v", err)
+	}
+	result, _ = limiter.Check("/login", "POST", "1.1.1.1", "u1")
+	if result.Reason != ReasonBanned || result.Dimension != BanDimensionUser || result.BanTTL != 0 || result.RetryAfter != 0 {
+		t.Errorf("永久封禁结果 = %+v", result)
+	}
+}
+
+func TestCheck_ReasonGlobalLimit(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Global:  &GlobalConfig{Params: []string{"1", "1m"}},
+		Rules: []RuleConfig{
+			{Name: "api", Path: "/api", By: "ip", Params: []string{"10", "1m"}},
+		},
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	limiter.Check("/api", "GET", "1.2.3.4", "")
+	result, _ := limiter.Check("/api", "GET", "1.2.3.4", "")
+	if result.Allowed || result.Reason != ReasonGlobalLimit || result.Rule != "全局限流" || result.Dimension != "global" {
+		t.Errorf("全局限流结果 = %+v", result)
+	}
+}
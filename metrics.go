@@ -0,0 +1,36 @@
+package ratelimiter
+
+// 检查结果
+const (
+	// DecisionAllowed 允许通过
+	DecisionAllowed = "allowed"
+	// DecisionDenied 被拒绝
+	DecisionDenied = "denied"
+)
+
+// Metrics 指标收集接口
+// 核心包只定义接口而不依赖任何指标库，drivers/metrics 提供Prometheus文本格式的实现
+// 方法在检查路径上同步调用，实现应当快速返回且并发安全
+type Metrics interface {
+	// ObserveDecision 记录规则的检查结果（decision为allowed/denied，预检不记录）
+	ObserveDecision(rule string, algorithm Algorithm, decision string)
+	// ObserveViolation 记录违规（weight为本次违规的分数）
+	ObserveViolation(dimension string, weight int)
+	// ObserveBan 记录封禁（source为manual/auto）
+	ObserveBan(dimension, source string)
+}
+
+// nopMetrics 未设置指标收集器时使用的空实现
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveDecision(string, Algorithm, string) {}
+func (nopMetrics) ObserveViolation(string, int)              {}
+func (nopMetrics) ObserveBan(string, string)                 {}
+
+// decision 将检查结果转换为指标中的decision标签
+func decision(allowed bool) string {
+	if allowed {
+		return DecisionAllowed
+	}
+	return DecisionDenied
+}
@@ -0,0 +1,86 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+// MockMetrics 记录调用的模拟指标收集器
+type MockMetrics struct {
+	decisions  []string
+	violations []string
+	bans       []string
+}
+
+func (m *MockMetrics) ObserveDecision(rule string, algorithm Algorithm, decision string) {
+	m.decisions = append(m.decisions, rule+"/"+string(algorithm)+"/"+decision)
+}
+
+func (m *MockMetrics) ObserveViolation(dimension string, weight int) {
+	m.violations = append(m.violations, dimension)
+}
+
+func (m *MockMetrics) ObserveBan(dimension, source string) {
+	m.bans = append(m.bans, dimension+"/"+source)
+}
+
+func TestWithMetrics(t *testing.T) {
+	metrics := &MockMetrics{}
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default:   DefaultConfig{Algorithm: "token_bucket", Enabled: true},
+		Global:    &GlobalConfig{Algorithm: "fixed_window", Params: []string{"100", "1m"}},
+		Blacklist: BlacklistConfig{Dynamic: true},
+		Rules: []RuleConfig{
+			{Name: "api", Path: "/api", By: "ip", Params: []string{"1", "1/h"}},
+		},
+	}
+	limiter, err := NewFromConfig(config, store, WithMetrics(metrics))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	limiter.Check("/api", "GET", "1.1.1.1", "")
+	limiter.Check("/api", "GET", "1.1.1.1", "")
+	limiter.Peek("/api", "GET", "1.1.1.1", "")
+
+	want := []string{
+		"全局限流/fixed_window/allowed", "api/token_bucket/allowed",
+		"全局限流/fixed_window/allowed", "api/token_bucket/denied",
+	}
+	if len(metrics.decisions) != len(want) {
+		t.Fatalf("decisions = %v, want %v", metrics.decisions, want)
+	}
+	for i := range want {
+		if metrics.decisions[i] != want[i] {
+			t.Errorf("decisions[%d] = %s, want %s", i, metrics.decisions[i], want[i])
+		}
+	}
+
+	if err := limiter.Ban(BanDimensionIP, "2.2.2.2", time.Hour, "测试"); err != nil {
+		t.Fatalf("Ban() error = %v", err)
+	}
+	if len(metrics.bans) != 1 || metrics.bans[0] != "ip/manual" {
+		t.Errorf("bans = %v, want [ip/manual]", metrics.bans)
+	}
+}
+
+func TestWithMetrics_Nil(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	limiter, err := NewFromConfig(&Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules:   []RuleConfig{{Name: "api", Path: "/api", By: "ip", Params: []string{"1", "1m"}}},
+	}, store, WithMetrics(nil))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	if _, err := limiter.Check("/api", "GET", "1.1.1.1", ""); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+}
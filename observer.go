@@ -0,0 +1,268 @@
+package ratelimiter
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultObserverBufferSize 异步观察者的默认事件队列长度
+const DefaultObserverBufferSize = 1024
+
+// 存储错误事件的操作名
+const (
+	OperationCheck   = "check"
+	OperationPeek    = "peek"
+	OperationBan     = "ban"
+	OperationUnban   = "unban"
+	OperationRelease = "release"
+	OperationRenew   = "renew"
+	OperationRefund  = "refund"
+)
+
+// Observer 限流事件观察者
+// 方法在检查路径上同步调用（panic会被恢复并忽略），耗时的处理（如发送告警、写入外部系统）应使用 NewAsyncObserver 包装
+// 只关心部分事件时可以嵌入 NopObserver
+type Observer interface {
+	// OnAllowed 请求通过（预检不触发）
+	OnAllowed(event DecisionEvent)
+	// OnDenied 请求被拒绝（预检不触发）
+	OnDenied(event DecisionEvent)
+	// OnViolation 记录了违规
+	OnViolation(event ViolationEvent)
+	// OnBan 封禁了IP或用户（包括自动拉黑和手动封禁）
+	OnBan(event BanEvent)
+	// OnUnban 解除了封禁
+	OnUnban(event UnbanEvent)
+	// OnStoreError 检查或管理操作因存储调用失败（参数校验等其他错误不触发）
+	OnStoreError(event StoreErrorEvent)
+}
+
+// DecisionEvent 检查结果事件
+type DecisionEvent struct {
+	// Time 事件时间
+	Time time.Time
+	// Request 请求描述（Header和Attributes与调用方共享，不应修改）
+	Request Request
+	// Rule 决定结果的规则名称（黑白名单等未经过规则检查时为空）
+	Rule string
+	// Key 决定结果的限流key（黑名单和封禁为用户ID或IP，白名单等未经过规则检查时为空）
+	Key string
+	// Algorithm 决定结果的规则使用的算法
+	Algorithm Algorithm
+	// Result 检查结果
+	Result Result
+}
+
+// ViolationEvent 违规事件
+type ViolationEvent struct {
+	// Time 事件时间
+	Time time.Time
+	// Rule 触发违规的规则名称
+	Rule string
+	// Dimension 违规维度（ip/user）
+	Dimension string
+	// Identifier IP（聚合后）或用户ID
+	Identifier string
+	// Weight 本次违规的分数
+	Weight int
+	// Score 违规窗口内的累计分数
+	Score int64
+	// Threshold 自动拉黑的分数阈值
+	Threshold int64
+}
+
+// BanEvent 封禁事件
+type BanEvent struct {
+	// Time 事件时间
+	Time time.Time
+	// Rule 触发自动拉黑的规则名称（手动封禁为空）
+	Rule string
+	// Dimension 封禁维度（ip/user）
+	Dimension string
+	// Identifier 被封禁的IP或用户ID
+	Identifier string
+	// Duration 封禁时长（<=0表示永久封禁）
+	Duration time.Duration
+	// Reason 封禁原因
+	Reason string
+	// Source 封禁来源（manual/auto）
+	Source string
+}
+
+// UnbanEvent 解除封禁事件
+type UnbanEvent struct {
+	// Time 事件时间
+	Time time.Time
+	// Dimension 封禁维度（ip/user）
+	Dimension string
+	// Identifier 被解封的IP或用户ID
+	Identifier string
+}
+
+// StoreErrorEvent 存储错误事件
+type StoreErrorEvent struct {
+	// Time 事件时间
+	Time time.Time
+	// Operation 失败的操作（check/peek/ban/unban/release/renew/refund）
+	Operation string
+	// Request 请求描述（非检查操作为空）
+	Request Request
+	// Err 错误
+	Err error
+}
+
+// WithObserver 注册事件观察者（可多次使用注册多个）
+func WithObserver(observer Observer) Option {
+	return func(l *Limiter) {
+		if observer != nil {
+			l.observers = append(l.observers, observer)
+		}
+	}
+}
+
+// notify 依次通知所有观察者，观察者panic不会影响检查结果和后续观察者
+func (l *Limiter) notify(fn func(Observer)) {
+	for _, observer := range l.observers {
+		func() {
+			defer func() { _ = recover() }()
+			fn(observer)
+		}()
+	}
+}
+
+// notifyDecision 通知检查结果
+func (l *Limiter) notifyDecision(req *Request, result *Result) {
+	if len(l.observers) == 0 {
+		return
+	}
+	event := DecisionEvent{Time: time.Now(), Rule: result.Rule, Key: result.Key, Result: *result}
+	if req != nil {
+		event.Request = *req
+	}
+	if result.rule != nil {
+		event.Algorithm = result.rule.Algorithm
+	}
+	l.notify(func(o Observer) {
+		if result.Allowed {
+			o.OnAllowed(event)
+		} else {
+			o.OnDenied(event)
+		}
+	})
+}
+
+// notifyStoreError 通知存储错误，参数校验、规则配置等不是由存储调用返回的错误不通知
+func (l *Limiter) notifyStoreError(operation string, req *Request, err error) {
+	if len(l.observers) == 0 || !isStoreError(err) {
+		return
+	}
+	event := StoreErrorEvent{Time: time.Now(), Operation: operation, Err: err}
+	if req != nil {
+		event.Request = *req
+	}
+	l.notify(func(o Observer) { o.OnStoreError(event) })
+}
+
+// NopObserver 不处理任何事件的观察者，嵌入后只需实现关心的方法
+type NopObserver struct{}
+
+func (NopObserver) OnAllowed(DecisionEvent)      {}
+func (NopObserver) OnDenied(DecisionEvent)       {}
+func (NopObserver) OnViolation(ViolationEvent)   {}
+func (NopObserver) OnBan(BanEvent)               {}
+func (NopObserver) OnUnban(UnbanEvent)           {}
+func (NopObserver) OnStoreError(StoreErrorEvent) {}
+
+// AsyncObserver 在后台goroutine中按顺序调用被包装的观察者
+// 事件队列满时丢弃事件而不阻塞检查路径，丢弃数量可通过Dropped查看
+type AsyncObserver struct {
+	observer Observer
+	events   chan func()
+	done     chan struct{}
+	dropped  atomic.Int64
+
+	// mu 保护closed，避免向已关闭的队列发送事件
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsyncObserver 创建异步观察者，bufferSize<=0 时使用 DefaultObserverBufferSize
+func NewAsyncObserver(observer Observer, bufferSize int) *AsyncObserver {
+	if bufferSize <= 0 {
+		bufferSize = DefaultObserverBufferSize
+	}
+	o := &AsyncObserver{
+		observer: observer,
+		events:   make(chan func(), bufferSize),
+		done:     make(chan struct{}),
+	}
+	go o.run()
+	return o
+}
+
+// run 按顺序处理事件，观察者panic不会终止处理
+func (o *AsyncObserver) run() {
+	defer close(o.done)
+	for fn := range o.events {
+		func() {
+			defer func() { _ = recover() }()
+			fn()
+		}()
+	}
+}
+
+// enqueue 将事件加入队列，队列已满或已关闭时丢弃
+func (o *AsyncObserver) enqueue(fn func()) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.closed {
+		o.dropped.Add(1)
+		return
+	}
+	select {
+	case o.events <- fn:
+	default:
+		o.dropped.Add(1)
+	}
+}
+
+// Dropped 返回因队列已满或已关闭而丢弃的事件数量
+func (o *AsyncObserver) Dropped() int64 {
+	return o.dropped.Load()
+}
+
+// Close 停止接收事件，处理完队列中剩余的事件后返回
+func (o *AsyncObserver) Close() {
+	o.mu.Lock()
+	if !o.closed {
+		o.closed = true
+		close(o.events)
+	}
+	o.mu.Unlock()
+	<-o.done
+}
+
+func (o *AsyncObserver) OnAllowed(event DecisionEvent) {
+	o.enqueue(func() { o.observer.OnAllowed(event) })
+}
+
+func (o *AsyncObserver) OnDenied(event DecisionEvent) {
+	o.enqueue(func() { o.observer.OnDenied(event) })
+}
+
+func (o *AsyncObserver) OnViolation(event ViolationEvent) {
+	o.enqueue(func() { o.observer.OnViolation(event) })
+}
+
+func (o *AsyncObserver) OnBan(event BanEvent) {
+	o.enqueue(func() { o.observer.OnBan(event) })
+}
+
+func (o *AsyncObserver) OnUnban(event UnbanEvent) {
+	o.enqueue(func() { o.observer.OnUnban(event) })
+}
+
+func (o *AsyncObserver) OnStoreError(event StoreErrorEvent) {
+	o.enqueue(func() { o.observer.OnStoreError(event) })
+}
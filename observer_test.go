@@ -0,0 +1,283 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+// MockObserver 记录收到的事件
+type MockObserver struct {
+	mu         sync.Mutex
+	allowed    []DecisionEvent
+	denied     []DecisionEvent
+	violations []ViolationEvent
+	bans       []BanEvent
+	unbans     []UnbanEvent
+	errors     []StoreErrorEvent
+}
+
+func (m *MockObserver) OnAllowed(event DecisionEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.allowed = append(m.allowed, event)
+}
+
+func (m *MockObserver) OnDenied(event DecisionEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.denied = append(m.denied, event)
+}
+
+func (m *MockObserver) OnViolation(event ViolationEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.violations = append(m.violations, event)
+}
+
+func (m *MockObserver) OnBan(event BanEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bans = append(m.bans, event)
+}
+
+func (m *MockObserver) OnUnban(event UnbanEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unbans = append(m.unbans, event)
+}
+
+func (m *MockObserver) OnStoreError(event StoreErrorEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors = append(m.errors, event)
+}
+
+func TestWithObserver(t *testing.T) {
+	observer := &MockObserver{}
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "登录", Path: "/login", By: "ip", Params: []string{"1", "1m"}, RecordViolation: true, ViolationWeight: 3},
+		},
+		AutoBan: AutoBanConfig{
+			Enabled:            true,
+			Dimensions:         []string{BanDimensionIP},
+			ViolationThreshold: 5,
+			ViolationWindow:    "1m",
+			BanDuration:        "1h",
+		},
+	}
+	limiter, err := NewFromConfig(config, store, WithObserver(observer))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		limiter.Check("/login", "POST", "1.1.1.1", "")
+	}
+	limiter.Peek("/login", "POST", "1.1.1.1", "")
+
+	// 检查结果，预检不触发
+	if len(observer.allowed) != 1 || observer.allowed[0].Rule != "登录" || observer.allowed[0].Algorithm != AlgorithmFixedWindow {
+		t.Errorf("allowed = %+v", observer.allowed)
+	}
+	if len(observer.denied) != 2 || observer.denied[0].Request.IP != "1.1.1.1" || observer.denied[0].Result.Allowed {
+		t.Errorf("denied = %+v", observer.denied)
+	}
+
+	// 违规分数累计达到阈值后自动拉黑
+	if len(observer.violations) != 2 {
+		t.Fatalf("violations = %+v", observer.violations)
+	}
+	if v := observer.violations[1]; v.Rule != "登录" || v.Dimension != BanDimensionIP || v.Identifier != "1.1.1.1" || v.Weight != 3 || v.Score != 6 || v.Threshold != 5 {
+		t.Errorf("violation = %+v", v)
+	}
+	if len(observer.bans) != 1 {
+		t.Fatalf("bans = %+v", observer.bans)
+	}
+	if b := observer.bans[0]; b.Rule != "登录" || b.Source != BanSourceAuto || b.Duration != time.Hour || b.Identifier != "1.1.1.1" {
+		t.Errorf("ban = %+v", b)
+	}
+
+	// 封禁后被黑名单拒绝，没有匹配的规则
+	limiter.Check("/login", "POST", "1.1.1.1", "")
+	if len(observer.denied) != 3 || observer.denied[2].Rule != "" {
+		t.Errorf("denied = %+v", observer.denied)
+	}
+
+	// 手动封禁和解封（解封未封禁的目标不触发）
+	limiter.Ban(BanDimensionIP, "2.2.2.2", 0, "测试")
+	limiter.Unban(BanDimensionIP, "2.2.2.2")
+	limiter.Unban(BanDimensionIP, "3.3.3.3")
+	if len(observer.bans) != 2 || observer.bans[1].Source != BanSourceManual || observer.bans[1].Rule != "" {
+		t.Errorf("bans = %+v", observer.bans)
+	}
+	if len(observer.unbans) != 1 || observer.unbans[0].Identifier != "2.2.2.2" {
+		t.Errorf("unbans = %+v", observer.unbans)
+	}
+
+	// 存储错误
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.CheckContext(ctx, "/login", "POST", "4.4.4.4", "")
+	if len(observer.errors) != 1 || observer.errors[0].Operation != OperationCheck || !errors.Is(observer.errors[0].Err, context.Canceled) {
+		t.Errorf("errors = %+v", observer.errors)
+	}
+}
+
+// panicObserver 每个回调都panic的观察者
+type panicObserver struct{ NopObserver }
+
+func (panicObserver) OnAllowed(DecisionEvent) { panic("OnAllowed") }
+func (panicObserver) OnDenied(DecisionEvent)  { panic("OnDenied") }
+func (panicObserver) OnBan(BanEvent)          { panic("OnBan") }
+
+func TestObserver_PanicRecovered(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	observer := &MockObserver{}
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "登录", Path: "/login", By: "ip", Params: []string{"1", "1m"}, RecordViolation: true},
+		},
+		AutoBan: AutoBanConfig{
+			Enabled:            true,
+			Dimensions:         []string{BanDimensionIP},
+			ViolationThreshold: 1,
+			ViolationWindow:    "1m",
+			BanDuration:        "1h",
+		},
+	}
+	limiter, err := NewFromConfig(config, store, WithObserver(panicObserver{}), WithObserver(observer))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	// 观察者panic不影响检查结果，后续观察者照常收到事件
+	if result, err := limiter.Check("/login", "POST", "1.1.1.1", ""); err != nil || !result.Allowed {
+		t.Fatalf("Check() = %+v, %v, want allowed", result, err)
+	}
+	if result, err := limiter.Check("/login", "POST", "1.1.1.1", ""); err != nil || result.Allowed {
+		t.Fatalf("Check() = %+v, %v, want denied", result, err)
+	}
+	if len(observer.allowed) != 1 || len(observer.denied) != 1 || len(observer.bans) != 1 {
+		t.Errorf("allowed=%d denied=%d bans=%d, want 1 1 1", len(observer.allowed), len(observer.denied), len(observer.bans))
+	}
+}
+
+// blockingObserver 处理事件时阻塞直到release关闭
+type blockingObserver struct {
+	NopObserver
+	release chan struct{}
+	mu      sync.Mutex
+	ips     []string
+}
+
+func (o *blockingObserver) OnDenied(event DecisionEvent) {
+	<-o.release
+	if event.Request.IP == "panic" {
+		panic("观察者异常")
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ips = append(o.ips, event.Request.IP)
+}
+
+func TestAsyncObserver(t *testing.T) {
+	inner := &blockingObserver{release: make(chan struct{})}
+	observer := NewAsyncObserver(inner, 2)
+
+	// 第一个事件被取出后阻塞，队列还能容纳两个，第四个被丢弃
+	observer.OnDenied(DecisionEvent{Request: Request{IP: "panic"}})
+	time.Sleep(10 * time.Millisecond)
+	observer.OnDenied(DecisionEvent{Request: Request{IP: "1"}})
+	observer.OnDenied(DecisionEvent{Request: Request{IP: "2"}})
+	observer.OnDenied(DecisionEvent{Request: Request{IP: "3"}})
+	if observer.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", observer.Dropped())
+	}
+
+	// Close处理完剩余事件后返回，panic不影响后续事件
+	close(inner.release)
+	observer.Close()
+	if len(inner.ips) != 2 || inner.ips[0] != "1" || inner.ips[1] != "2" {
+		t.Errorf("ips = %v, want [1 2]", inner.ips)
+	}
+
+	// 关闭后的事件被丢弃
+	observer.OnDenied(DecisionEvent{})
+	observer.Close()
+	if observer.Dropped() != 2 {
+		t.Errorf("Dropped() = %d, want 2", observer.Dropped())
+	}
+}
+
+// failingStore Eval返回固定结果的存储（err不为nil时模拟存储故障）
+type failingStore struct {
+	*MockStore
+	err error
+}
+
+func (s *failingStore) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return int64(0), nil
+}
+
+func TestObserver_OnlyStoreErrors(t *testing.T) {
+	observer := &MockObserver{}
+	errStore := errors.New("connection refused")
+	store := &failingStore{MockStore: NewMockStore()}
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "api", Path: "/api", By: "ip", Params: []string{"5", "1m"}},
+		},
+		Blacklist: BlacklistConfig{Dynamic: true},
+	}
+	limiter, err := NewFromConfig(config, store, WithObserver(observer))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	// 参数校验、规则信息缺失、租约到期等错误不是存储错误
+	if err := limiter.Ban("unknown", "1.1.1.1", time.Hour, ""); err == nil {
+		t.Error("无效的封禁维度应返回错误")
+	}
+	if err := limiter.Refund(&Result{Refunds: []Refund{{Key: "api:ip:1.1.1.1", Cost: 1}}}); err == nil {
+		t.Error("缺少规则信息应返回错误")
+	}
+	if err := limiter.Renew(&Result{Leases: []Lease{{Key: "export:user:1", ID: "lease-1", Duration: time.Minute}}}); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("Renew() error = %v, want ErrLeaseExpired", err)
+	}
+	if len(observer.errors) != 0 {
+		t.Fatalf("非存储错误不应通知, errors = %+v", observer.errors)
+	}
+
+	// 存储调用失败时通知，错误链保留原错误
+	store.err = errStore
+	if _, err := limiter.Check("/api", "GET", "1.1.1.1", ""); !errors.Is(err, errStore) {
+		t.Fatalf("Check() error = %v, want %v", err, errStore)
+	}
+	if err := limiter.Ban(BanDimensionIP, "1.1.1.1", time.Hour, ""); !errors.Is(err, errStore) {
+		t.Fatalf("Ban() error = %v, want %v", err, errStore)
+	}
+	if len(observer.errors) != 2 || observer.errors[0].Operation != OperationCheck || observer.errors[1].Operation != OperationBan {
+		t.Fatalf("errors = %+v", observer.errors)
+	}
+	if !errors.Is(observer.errors[0].Err, errStore) || observer.errors[0].Request.IP != "1.1.1.1" {
+		t.Errorf("errors[0] = %+v", observer.errors[0])
+	}
+}
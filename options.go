@@ -0,0 +1,13 @@
+package ratelimiter
+
+// Option 限流器选项
+type Option func(*Limiter)
+
+// WithMetrics 设置指标收集器（如 drivers/metrics.Collector）
+func WithMetrics(metrics Metrics) Option {
+	return func(l *Limiter) {
+		if metrics != nil {
+			l.metrics = metrics
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/algorithm"
+)
+
+// Peek 检查请求是否会被允许（只读，不消耗配额）
+// 用于配额查询接口和预检，返回的Remaining为当前剩余配额
+func (l *Limiter) Peek(path, method, ip, userID string) (*Result, error) {
+	return l.PeekRequestContext(context.Background(), &Request{
+		Path:   path,
+		Method: method,
+		IP:     ip,
+		UserID: userID,
+	})
+}
+
+// PeekContext 检查请求是否会被允许（只读，支持context）
+func (l *Limiter) PeekContext(ctx context.Context, path, method, ip, userID string) (*Result, error) {
+	return l.PeekRequestContext(ctx, &Request{
+		Path:   path,
+		Method: method,
+		IP:     ip,
+		UserID: userID,
+	})
+}
+
+// PeekRequest 检查请求是否会被允许（只读，使用请求描述）
+func (l *Limiter) PeekRequest(req *Request) (*Result, error) {
+	return l.PeekRequestContext(context.Background(), req)
+}
+
+// PeekRequestContext 检查请求是否会被允许（只读，使用请求描述，支持context）
+// 与 CheckRequestContext 使用相同的规则匹配和黑白名单逻辑，但不消耗配额、不占用并发名额、不记录违规
+func (l *Limiter) PeekRequestContext(ctx context.Context, req *Request) (*Result, error) {
+	return l.check(ctx, req, modePeek)
+}
+
+// peekAlgorithm 按规则的算法读取当前状态
+func (l *Limiter) peekAlgorithm(ctx context.Context, rule *Rule, key string, cost int64) (*algorithm.Context, error) {
+	switch rule.Algorithm {
+	case AlgorithmFixedWindow:
+		return l.fixedWindow.PeekNContext(ctx, key, rule.Limit, rule.Window, cost)
+	case AlgorithmSlidingWindow:
+		return l.slidingWindow.PeekNContext(ctx, key, rule.Limit, rule.Window, cost)
+	case AlgorithmSlidingWindowCounter:
+		return l.windowCounter.PeekNContext(ctx, key, rule.Limit, rule.Window, cost)
+	case AlgorithmTokenBucket:
+		return l.tokenBucket.PeekNContext(ctx, key, rule.Capacity, rule.Rate, cost)
+	case AlgorithmLeakyBucket:
+		return l.leakyBucket.PeekNContext(ctx, key, rule.Capacity, rule.Rate, cost)
+	case AlgorithmLeakyBucketQueue:
+		return l.leakyBucket.PeekQueueNContext(ctx, key, rule.Capacity, rule.Rate, cost)
+	case AlgorithmGCRA:
+		return l.gcra.PeekNContext(ctx, key, rule.Capacity, rule.Rate, cost)
+	case AlgorithmConcurrency:
+		return l.concurrency.PeekContext(ctx, key, rule.Limit, rule.Window)
+	default:
+		return nil, fmt.Errorf("未知的算法: %s", rule.Algorithm)
+	}
+}
@@ -0,0 +1,106 @@
+package ratelimiter
+
+import (
+	"testing"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+func TestPeek_DoesNotConsume(t *testing.T) {
+	algorithms := []struct {
+		algorithm string
+		params    []string
+	}{
+		{"fixed_window", []string{"3", "1m"}},
+		{"sliding_window", []string{"3", "1m"}},
+		{"sliding_window_counter", []string{"3", "1m"}},
+		{"token_bucket", []string{"3", "1/h"}},
+		{"leaky_bucket", []string{"3", "1/h"}},
+		{"leaky_bucket_queue", []string{"3", "1/h"}},
+		{"gcra", []string{"3", "1/h"}},
+		{"concurrency", []string{"3", "1m"}},
+	}
+
+	for _, tt := range algorithms {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			store := memory.NewStore(0)
+			defer store.Close()
+
+			config := &Config{
+				Default: DefaultConfig{Algorithm: tt.algorithm, Enabled: true},
+				Rules: []RuleConfig{
+					{Name: "api", Path: "/api", By: "ip", Params: tt.params},
+				},
+			}
+			limiter, err := NewFromConfig(config, store)
+			if err != nil {
+				t.Fatalf("NewFromConfig() error = %v", err)
+			}
+
+			// 未使用时剩余全部配额，多次查询不消耗配额
+			for i := 0; i < 5; i++ {
+				result, err := limiter.Peek("/api", "GET", "1.1.1.1", "")
+				if err != nil {
+					t.Fatalf("Peek() error = %v", err)
+				}
+				if !result.Allowed || result.Remaining != 3 || len(result.Leases) != 0 {
+					t.Fatalf("Peek() = %+v, want allowed remaining=3", result)
+				}
+			}
+
+			// 消耗2个配额后剩余1个
+			for i := 0; i < 2; i++ {
+				if result, _ := limiter.Check("/api", "GET", "1.1.1.1", ""); !result.Allowed {
+					t.Fatalf("第%d次请求应该允许", i+1)
+				}
+			}
+			if result, _ := limiter.Peek("/api", "GET", "1.1.1.1", ""); !result.Allowed || result.Remaining != 1 {
+				t.Errorf("Peek() = %+v, want allowed remaining=1", result)
+			}
+
+			// 配额用完后预检返回拒绝
+			if result, _ := limiter.Check("/api", "GET", "1.1.1.1", ""); !result.Allowed {
+				t.Fatal("第3次请求应该允许")
+			}
+			result, err := limiter.Peek("/api", "GET", "1.1.1.1", "")
+			if err != nil {
+				t.Fatalf("Peek() error = %v", err)
+			}
+			if result.Allowed || result.Remaining != 0 || result.RetryAfter < 1 {
+				t.Errorf("Peek() = %+v, want denied", result)
+			}
+		})
+	}
+}
+
+func TestPeek_NoViolation(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "login", Path: "/login", By: "ip", Params: []string{"1", "1m"}, RecordViolation: true, ViolationWeight: 5},
+		},
+		AutoBan: AutoBanConfig{Enabled: true, Dimensions: []string{"ip"}, ViolationThreshold: 5, ViolationWindow: "5m", BanDuration: "1h"},
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	if result, _ := limiter.Check("/login", "POST", "1.1.1.1", ""); !result.Allowed {
+		t.Fatal("第1次请求应该允许")
+	}
+	if result, _ := limiter.Peek("/login", "POST", "1.1.1.1", ""); result.Allowed {
+		t.Fatal("配额用完后预检应该返回拒绝")
+	}
+
+	// 预检被拒绝不记录违规，也不会触发自动拉黑
+	if score, _ := limiter.GetViolationScore(BanDimensionIP, "1.1.1.1"); score != 0 {
+		t.Errorf("违规分数 = %d, want 0", score)
+	}
+	if result, _ := limiter.Peek("/other", "GET", "1.1.1.1", ""); !result.Allowed {
+		t.Error("预检不应触发自动拉黑")
+	}
+}
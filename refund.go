@@ -0,0 +1,73 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Refund 退还检查结果中消耗的配额（下游处理失败时调用）
+func (l *Limiter) Refund(result *Result) error {
+	return l.RefundContext(context.Background(), result)
+}
+
+// RefundContext 退还检查结果中消耗的配额（支持context）
+// 仅fixed_window、sliding_window、token_bucket算法支持退还，其他算法消耗的配额不退还
+// 退还后清空result.Refunds，重复调用不会产生影响；窗口已重置或记录已过期时不视为错误
+func (l *Limiter) RefundContext(ctx context.Context, result *Result) error {
+	if result == nil || len(result.Refunds) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, refund := range result.Refunds {
+		if err := l.refund(ctx, refund); err != nil {
+			errs = append(errs, fmt.Errorf("退还配额失败: %w", err))
+		}
+	}
+	result.Refunds = nil
+	err := errors.Join(errs...)
+	if err != nil {
+		l.notifyStoreError(OperationRefund, nil, err)
+	}
+	return err
+}
+
+// refundQuota 请求被拒绝时退还已通过的规则消耗的配额
+// 退还失败时配额会随窗口重置或令牌补充恢复，因此忽略错误
+func (l *Limiter) refundQuota(ctx context.Context, refunds []Refund) {
+	for _, refund := range refunds {
+		_ = l.refund(ctx, refund)
+	}
+}
+
+// refund 按消耗时的规则退还配额
+func (l *Limiter) refund(ctx context.Context, refund Refund) error {
+	rule := refund.rule
+	if rule == nil {
+		return fmt.Errorf("缺少规则信息: %s", refund.Key)
+	}
+
+	var err error
+	switch rule.Algorithm {
+	case AlgorithmFixedWindow:
+		_, err = l.fixedWindow.RefundContext(ctx, refund.Key, rule.Window, refund.Cost, refund.token)
+	case AlgorithmSlidingWindow:
+		_, err = l.slidingWindow.RefundContext(ctx, refund.Key, refund.Cost, refund.token)
+	case AlgorithmTokenBucket:
+		_, err = l.tokenBucket.RefundContext(ctx, refund.Key, rule.Capacity, refund.Cost)
+	default:
+		err = fmt.Errorf("算法不支持退还配额: %s", rule.Algorithm)
+	}
+	return err
+}
+
+// refundable 判断算法是否支持退还配额
+func refundable(algo Algorithm) bool {
+	switch algo {
+	case AlgorithmFixedWindow, AlgorithmSlidingWindow, AlgorithmTokenBucket:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,133 @@
+package ratelimiter
+
+import (
+	"testing"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+func TestRefund_RestoresQuota(t *testing.T) {
+	algorithms := []struct {
+		algorithm string
+		params    []string
+	}{
+		{"fixed_window", []string{"2", "1m"}},
+		{"sliding_window", []string{"2", "1m"}},
+		{"token_bucket", []string{"2", "1/h"}},
+	}
+
+	for _, tt := range algorithms {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			store := memory.NewStore(0)
+			defer store.Close()
+
+			config := &Config{
+				Default: DefaultConfig{Algorithm: tt.algorithm, Enabled: true},
+				Rules: []RuleConfig{
+					{Name: "api", Path: "/api", By: "ip", Params: tt.params, Cost: "2"},
+				},
+			}
+			limiter, err := NewFromConfig(config, store)
+			if err != nil {
+				t.Fatalf("NewFromConfig() error = %v", err)
+			}
+
+			result, err := limiter.Check("/api", "GET", "1.1.1.1", "")
+			if err != nil || !result.Allowed {
+				t.Fatalf("Check() = %+v, %v", result, err)
+			}
+			if len(result.Refunds) != 1 || result.Refunds[0].Cost != 2 {
+				t.Fatalf("Refunds = %+v, want 1 refund cost=2", result.Refunds)
+			}
+			if denied, _ := limiter.Check("/api", "GET", "1.1.1.1", ""); denied.Allowed {
+				t.Fatal("配额用尽后应该拒绝")
+			}
+
+			// 退还后配额恢复，重复退还不产生影响
+			if err := limiter.Refund(result); err != nil {
+				t.Fatalf("Refund() error = %v", err)
+			}
+			if len(result.Refunds) != 0 {
+				t.Error("退还后应清空Refunds")
+			}
+			if err := limiter.Refund(result); err != nil {
+				t.Fatalf("重复Refund() error = %v", err)
+			}
+
+			peek, _ := limiter.Peek("/api", "GET", "1.1.1.1", "")
+			if !peek.Allowed || peek.Remaining != 2 {
+				t.Errorf("Peek() = %+v, want allowed remaining=2", peek)
+			}
+		})
+	}
+}
+
+func TestRefund_UnsupportedAlgorithm(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "gcra", Enabled: true},
+		Global:  &GlobalConfig{Algorithm: "sliding_window", Params: []string{"10", "1m"}},
+		Rules: []RuleConfig{
+			{Name: "api", Path: "/api", By: "ip", Params: []string{"2", "1/s"}},
+		},
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	// 只退还支持退还的全局规则
+	result, err := limiter.Check("/api", "GET", "1.1.1.1", "")
+	if err != nil || !result.Allowed {
+		t.Fatalf("Check() = %+v, %v", result, err)
+	}
+	if len(result.Refunds) != 1 || result.Refunds[0].Key == "" {
+		t.Fatalf("Refunds = %+v, want 1 refund", result.Refunds)
+	}
+	if err := limiter.Refund(result); err != nil {
+		t.Errorf("Refund() error = %v", err)
+	}
+
+	// 预检不返回可退还的配额
+	if peek, _ := limiter.Peek("/api", "GET", "1.1.1.1", ""); len(peek.Refunds) != 0 {
+		t.Errorf("Peek() Refunds = %+v, want none", peek.Refunds)
+	}
+}
+
+func TestCheck_DeniedRuleRefundsEarlierRules(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	yes := true
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Global:  &GlobalConfig{Algorithm: "sliding_window", Params: []string{"5", "1m"}},
+		Rules: []RuleConfig{
+			{Name: "api", Path: "/api", By: "ip", Params: []string{"5", "1m"}, Continue: &yes},
+			{Name: "write", Path: "/api", By: "ip", Params: []string{"1", "1m"}},
+		},
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	if result, err := limiter.Check("/api", "GET", "1.1.1.1", ""); err != nil || !result.Allowed {
+		t.Fatalf("Check() = %+v, %v", result, err)
+	}
+
+	// 后续规则拒绝时，全局规则和之前的continue规则消耗的配额被退还
+	for i := 0; i < 3; i++ {
+		if result, _ := limiter.Check("/api", "GET", "1.1.1.1", ""); result.Allowed || result.Rule != "write" {
+			t.Fatalf("Check() = %+v, want denied by write", result)
+		}
+	}
+	if count, _ := store.Get("api:ip:1.1.1.1"); count != 1 {
+		t.Errorf("api计数 = %d, want 1", count)
+	}
+	if peek, _ := limiter.Peek("/other", "GET", "1.1.1.1", ""); peek.Remaining != 4 {
+		t.Errorf("全局Remaining = %d, want 4", peek.Remaining)
+	}
+}
@@ -0,0 +1,140 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultWatchInterval 默认的配置文件检查间隔
+const DefaultWatchInterval = 5 * time.Second
+
+// Reload 重新加载配置文件（仅适用于通过NewFromFile创建的限流器）
+// 加载或验证失败时返回错误，继续使用原有配置
+func (l *Limiter) Reload() error {
+	if l.configFile == "" {
+		return fmt.Errorf("限流器不是从配置文件创建的，无法重新加载")
+	}
+
+	config, err := LoadConfig(l.configFile)
+	if err != nil {
+		return fmt.Errorf("重新加载配置失败: %w", err)
+	}
+
+	return l.ReloadConfig(config)
+}
+
+// ReloadConfig 使用新的配置替换当前规则集
+// 新配置先经过验证和编译，全部成功后才原子替换；失败时返回错误，继续使用原有配置
+// 正在进行的检查使用替换前的规则集完成，之后的检查使用新规则集
+// 决策日志只有采样比例支持热更新，启用状态、路径和轮转设置变化时返回错误
+func (l *Limiter) ReloadConfig(config *Config) error {
+	if config == nil {
+		return fmt.Errorf("配置不能为空")
+	}
+	if err := validateConfig(config); err != nil {
+		return fmt.Errorf("配置验证失败: %w", err)
+	}
+
+	rs, err := newRuleSet(config)
+	if err != nil {
+		return err
+	}
+
+	l.updateMu.Lock()
+	defer l.updateMu.Unlock()
+	if decisionLogChanged(l.snapshot().config.DecisionLog, config.DecisionLog) {
+		return fmt.Errorf("决策日志的启用状态、路径和轮转设置不支持重新加载，需要重新创建限流器")
+	}
+	l.current.Store(rs)
+
+	return nil
+}
+
+// ConfigFile 返回限流器使用的配置文件路径（不是从文件创建时为空）
+func (l *Limiter) ConfigFile() string {
+	return l.configFile
+}
+
+// WatchConfig 定期检查配置文件，文件修改后自动重新加载
+// interval 为检查间隔（<=0时使用DefaultWatchInterval），ctx 结束时停止检查
+// 每次重新加载后调用 onReload（可为nil），成功时参数为nil
+func (l *Limiter) WatchConfig(ctx context.Context, interval time.Duration, onReload func(error)) error {
+	if l.configFile == "" {
+		return fmt.Errorf("限流器不是从配置文件创建的，无法监听配置")
+	}
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	last, err := os.Stat(l.configFile)
+	if err != nil {
+		return fmt.Errorf("读取配置文件信息失败: %w", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(l.configFile)
+			if err != nil {
+				// 文件可能正在被替换，下次再检查
+				continue
+			}
+			if info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			last = info
+
+			err = l.Reload()
+			if onReload != nil {
+				onReload(err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// WatchSignal 收到指定信号时重新加载配置文件（未指定信号时使用SIGHUP）
+// ctx 结束时停止监听；每次重新加载后调用 onReload（可为nil），成功时参数为nil
+func (l *Limiter) WatchSignal(ctx context.Context, onReload func(error), sigs ...os.Signal) error {
+	if l.configFile == "" {
+		return fmt.Errorf("限流器不是从配置文件创建的，无法监听配置")
+	}
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		defer signal.Stop(ch)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+			}
+
+			err := l.Reload()
+			if onReload != nil {
+				onReload(err)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,322 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+const reloadTestConfig = `
+default:
+  algorithm: fixed_window
+  enabled: true
+
+rules:
+  - name: api
+    path: /api/*
+    by: ip
+    params: ["%d", "1m"]
+`
+
+// writeReloadConfig 写入指定限流阈值的配置文件
+func writeReloadConfig(t *testing.T, path string, limit int) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(reloadTestConfig, limit)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newReloadLimiter(t *testing.T, limit int) (*Limiter, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rate_limit.yaml")
+	writeReloadConfig(t, path, limit)
+
+	limiter, err := NewFromFile(path, NewMockStore())
+	if err != nil {
+		t.Fatalf("创建限流器失败: %v", err)
+	}
+	return limiter, path
+}
+
+func TestReload(t *testing.T) {
+	limiter, path := newReloadLimiter(t, 1)
+
+	if limiter.ConfigFile() != path {
+		t.Errorf("ConfigFile() = %s, want %s", limiter.ConfigFile(), path)
+	}
+
+	result, _ := limiter.Check("/api/a", "GET", "1.1.1.1", "")
+	if result.Limit != 1 {
+		t.Errorf("Limit = %d, want 1", result.Limit)
+	}
+
+	writeReloadConfig(t, path, 100)
+	if err := limiter.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	result, _ = limiter.Check("/api/a", "GET", "1.1.1.1", "")
+	if !result.Allowed || result.Limit != 100 {
+		t.Errorf("重新加载后结果 = %+v, want Allowed Limit=100", result)
+	}
+	if limiter.GetConfig().Rules[0].Params[0] != "100" {
+		t.Error("GetConfig() 应该返回新配置")
+	}
+}
+
+func TestReload_InvalidConfigKeepsOld(t *testing.T) {
+	limiter, path := newReloadLimiter(t, 5)
+	old := limiter.GetConfig()
+
+	// 无效的YAML
+	if err := os.WriteFile(path, []byte("rules: ["), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.Reload(); err == nil {
+		t.Error("期望解析错误")
+	}
+
+	// 验证失败
+	writeReloadConfig(t, path, 0)
+	if err := limiter.Reload(); err == nil {
+		t.Error("期望验证错误")
+	}
+
+	if limiter.GetConfig() != old {
+		t.Error("重新加载失败时应该保留原有配置")
+	}
+	result, _ := limiter.Check("/api/a", "GET", "1.1.1.1", "")
+	if result.Limit != 5 {
+		t.Errorf("Limit = %d, want 5", result.Limit)
+	}
+}
+
+func TestReload_NotFromFile(t *testing.T) {
+	limiter, err := NewFromConfig(&Config{Default: DefaultConfig{Enabled: true}}, NewMockStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := limiter.Reload(); err == nil {
+		t.Error("不是从文件创建时Reload应该返回错误")
+	}
+	if err := limiter.WatchConfig(context.Background(), time.Second, nil); err == nil {
+		t.Error("不是从文件创建时WatchConfig应该返回错误")
+	}
+	if err := limiter.WatchSignal(context.Background(), nil); err == nil {
+		t.Error("不是从文件创建时WatchSignal应该返回错误")
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	limiter, err := NewFromConfig(&Config{Default: DefaultConfig{Enabled: true}}, NewMockStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := limiter.ReloadConfig(nil); err == nil {
+		t.Error("ReloadConfig(nil) 应该返回错误")
+	}
+
+	err = limiter.ReloadConfig(&Config{
+		Default: DefaultConfig{Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "bad", Path: "/api/*", By: "unknown", Params: []string{"1", "1m"}},
+		},
+	})
+	if err == nil {
+		t.Error("无效配置应该返回错误")
+	}
+
+	err = limiter.ReloadConfig(&Config{
+		Default: DefaultConfig{Enabled: true},
+		Blacklist: BlacklistConfig{
+			IPs: []string{"6.6.6.6"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	result, _ := limiter.Check("/api/a", "GET", "6.6.6.6", "")
+	if result.Allowed {
+		t.Error("新配置的黑名单应该生效")
+	}
+	// 验证时补全默认算法
+	if limiter.GetConfig().Default.Algorithm != string(AlgorithmFixedWindow) {
+		t.Errorf("Default.Algorithm = %s, want fixed_window", limiter.GetConfig().Default.Algorithm)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	limiter, path := newReloadLimiter(t, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloaded := make(chan error, 1)
+	if err := limiter.WatchConfig(ctx, 10*time.Millisecond, func(err error) {
+		reloaded <- err
+	}); err != nil {
+		t.Fatalf("WatchConfig() error = %v", err)
+	}
+
+	writeReloadConfig(t, path, 1000)
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("重新加载失败: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("配置文件修改后应该自动重新加载")
+	}
+
+	result, _ := limiter.Check("/api/a", "GET", "1.1.1.1", "")
+	if result.Limit != 1000 {
+		t.Errorf("Limit = %d, want 1000", result.Limit)
+	}
+}
+
+func TestWatchSignal(t *testing.T) {
+	limiter, path := newReloadLimiter(t, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloaded := make(chan error, 1)
+	if err := limiter.WatchSignal(ctx, func(err error) {
+		reloaded <- err
+	}); err != nil {
+		t.Fatalf("WatchSignal() error = %v", err)
+	}
+
+	writeReloadConfig(t, path, 50)
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Skipf("当前平台不支持发送SIGHUP: %v", err)
+	}
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("重新加载失败: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("收到SIGHUP后应该重新加载")
+	}
+
+	result, _ := limiter.Check("/api/a", "GET", "1.1.1.1", "")
+	if result.Limit != 50 {
+		t.Errorf("Limit = %d, want 50", result.Limit)
+	}
+}
+
+// TestReloadConfig_Concurrent 测试检查与重新加载并发执行（配合 -race 使用）
+func TestReloadConfig_Concurrent(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	newConfig := func(limit int) *Config {
+		return &Config{
+			Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+			Rules: []RuleConfig{
+				{Name: "api", Path: "/api/*", By: "ip", Params: []string{fmt.Sprint(limit), "1m"}},
+			},
+			Whitelist: WhitelistConfig{IPs: []string{"127.0.0.1"}},
+		}
+	}
+
+	limiter, err := NewFromConfig(newConfig(10), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				result, err := limiter.Check("/api/a", "GET", fmt.Sprintf("10.0.0.%d", i), "")
+				if err != nil {
+					t.Errorf("Check() error = %v", err)
+					return
+				}
+				if result.Limit != 10 && result.Limit != 20 {
+					t.Errorf("Limit = %d, want 10 或 20", result.Limit)
+					return
+				}
+			}
+		}(i)
+	}
+
+	for i := 0; i < 50; i++ {
+		if err := limiter.ReloadConfig(newConfig(10 + 10*(i%2))); err != nil {
+			t.Fatalf("ReloadConfig() error = %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestReloadConfig_DecisionLogOutputChange(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{
+		Default:     DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		DecisionLog: DecisionLogConfig{Enabled: true, Path: filepath.Join(dir, "decision.log"), SampleRate: 1},
+	}
+	limiter, err := NewFromConfig(config, NewMockStore())
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	defer limiter.Close()
+
+	tests := []struct {
+		name   string
+		modify func(c *DecisionLogConfig)
+	}{
+		{"关闭", func(c *DecisionLogConfig) { c.Enabled = false }},
+		{"修改路径", func(c *DecisionLogConfig) { c.Path = filepath.Join(dir, "other.log") }},
+		{"修改大小上限", func(c *DecisionLogConfig) { c.MaxSize = "1MB" }},
+		{"修改保留数量", func(c *DecisionLogConfig) { c.MaxBackups = 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reloaded := *config
+			tt.modify(&reloaded.DecisionLog)
+			if err := limiter.ReloadConfig(&reloaded); err == nil {
+				t.Error("决策日志输出设置变化时应该返回错误")
+			}
+			if limiter.GetConfig().DecisionLog != config.DecisionLog {
+				t.Error("失败时应继续使用原有配置")
+			}
+		})
+	}
+
+	// 采样比例支持重新加载
+	reloaded := *config
+	reloaded.DecisionLog.SampleRate = 0.5
+	if err := limiter.ReloadConfig(&reloaded); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	// 未启用时不能通过重新加载启用
+	disabled, err := NewFromConfig(&Config{Default: DefaultConfig{Enabled: true}}, NewMockStore())
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	if err := disabled.ReloadConfig(config); err == nil {
+		t.Error("重新加载启用决策日志应该返回错误")
+	}
+}
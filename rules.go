@@ -0,0 +1,181 @@
+package ratelimiter
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrRuleNotFound 规则不存在
+	ErrRuleNotFound = errors.New("规则不存在")
+	// ErrRuleExists 同名规则已存在
+	ErrRuleExists = errors.New("同名规则已存在")
+)
+
+// ListRules 返回当前生效的规则配置（按匹配顺序）
+// 返回的是副本，修改它不会影响限流器
+func (l *Limiter) ListRules() []RuleConfig {
+	rules := l.snapshot().config.Rules
+	list := make([]RuleConfig, len(rules))
+	for i := range rules {
+		list[i] = cloneRuleConfig(rules[i])
+	}
+	return list
+}
+
+// GetRule 按名称获取规则配置
+func (l *Limiter) GetRule(name string) (RuleConfig, error) {
+	rules := l.snapshot().config.Rules
+	i := indexOfRule(rules, name)
+	if i < 0 {
+		return RuleConfig{}, fmt.Errorf("%w: %s", ErrRuleNotFound, name)
+	}
+	return cloneRuleConfig(rules[i]), nil
+}
+
+// AddRule 在规则列表末尾添加规则
+// 规则名称不能为空且不能与已有规则重复，通过验证后立即生效
+func (l *Limiter) AddRule(rule RuleConfig) error {
+	return l.InsertRule(-1, rule)
+}
+
+// InsertRule 在指定位置插入规则（index 超出范围或小于0时添加到末尾）
+func (l *Limiter) InsertRule(index int, rule RuleConfig) error {
+	if rule.Name == "" {
+		return fmt.Errorf("规则名称不能为空")
+	}
+
+	return l.updateConfig(func(config *Config) error {
+		if indexOfRule(config.Rules, rule.Name) >= 0 {
+			return fmt.Errorf("%w: %s", ErrRuleExists, rule.Name)
+		}
+
+		if index < 0 || index > len(config.Rules) {
+			index = len(config.Rules)
+		}
+		config.Rules = append(config.Rules, RuleConfig{})
+		copy(config.Rules[index+1:], config.Rules[index:])
+		config.Rules[index] = cloneRuleConfig(rule)
+		return nil
+	})
+}
+
+// UpdateRule 替换指定名称的规则（保持原有位置）
+// rule.Name 为空时沿用原名称，不为空时可用于重命名
+func (l *Limiter) UpdateRule(name string, rule RuleConfig) error {
+	if rule.Name == "" {
+		rule.Name = name
+	}
+
+	return l.updateConfig(func(config *Config) error {
+		i := indexOfRule(config.Rules, name)
+		if i < 0 {
+			return fmt.Errorf("%w: %s", ErrRuleNotFound, name)
+		}
+		if rule.Name != name && indexOfRule(config.Rules, rule.Name) >= 0 {
+			return fmt.Errorf("%w: %s", ErrRuleExists, rule.Name)
+		}
+
+		config.Rules[i] = cloneRuleConfig(rule)
+		return nil
+	})
+}
+
+// RemoveRule 删除指定名称的规则
+func (l *Limiter) RemoveRule(name string) error {
+	return l.updateConfig(func(config *Config) error {
+		i := indexOfRule(config.Rules, name)
+		if i < 0 {
+			return fmt.Errorf("%w: %s", ErrRuleNotFound, name)
+		}
+
+		config.Rules = append(config.Rules[:i], config.Rules[i+1:]...)
+		return nil
+	})
+}
+
+// MoveRule 将指定名称的规则移动到新位置（index 超出范围时移动到末尾）
+func (l *Limiter) MoveRule(name string, index int) error {
+	return l.updateConfig(func(config *Config) error {
+		i := indexOfRule(config.Rules, name)
+		if i < 0 {
+			return fmt.Errorf("%w: %s", ErrRuleNotFound, name)
+		}
+
+		rule := config.Rules[i]
+		config.Rules = append(config.Rules[:i], config.Rules[i+1:]...)
+		if index < 0 || index > len(config.Rules) {
+			index = len(config.Rules)
+		}
+		config.Rules = append(config.Rules, RuleConfig{})
+		copy(config.Rules[index+1:], config.Rules[index:])
+		config.Rules[index] = rule
+		return nil
+	})
+}
+
+// SetEnabled 启用或禁用限流（立即生效）
+func (l *Limiter) SetEnabled(enabled bool) {
+	l.updateMu.Lock()
+	defer l.updateMu.Unlock()
+
+	// 只修改开关，规则无需重新编译
+	rs := *l.snapshot()
+	config := *rs.config
+	config.Default.Enabled = enabled
+	rs.config = &config
+	l.current.Store(&rs)
+}
+
+// updateConfig 基于当前配置的副本进行修改，验证并编译通过后原子替换规则集
+// 失败时返回错误，当前规则集保持不变
+func (l *Limiter) updateConfig(modify func(config *Config) error) error {
+	l.updateMu.Lock()
+	defer l.updateMu.Unlock()
+
+	config := cloneConfig(l.snapshot().config)
+	if err := modify(config); err != nil {
+		return err
+	}
+	if err := validateConfig(config); err != nil {
+		return fmt.Errorf("配置验证失败: %w", err)
+	}
+
+	rs, err := newRuleSet(config)
+	if err != nil {
+		return err
+	}
+	l.current.Store(rs)
+
+	return nil
+}
+
+// indexOfRule 返回指定名称的规则位置，不存在时返回-1
+func indexOfRule(rules []RuleConfig, name string) int {
+	for i := range rules {
+		if rules[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// cloneConfig 复制配置（规则列表深拷贝，其余部分修改前需自行复制）
+func cloneConfig(config *Config) *Config {
+	clone := *config
+	clone.Rules = make([]RuleConfig, len(config.Rules))
+	for i := range config.Rules {
+		clone.Rules[i] = cloneRuleConfig(config.Rules[i])
+	}
+	return &clone
+}
+
+// cloneRuleConfig 复制规则配置
+func cloneRuleConfig(rule RuleConfig) RuleConfig {
+	rule.Params = append([]string(nil), rule.Params...)
+	if rule.Continue != nil {
+		c := *rule.Continue
+		rule.Continue = &c
+	}
+	return rule
+}
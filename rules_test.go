@@ -0,0 +1,267 @@
+package ratelimiter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+func newRulesLimiter(t *testing.T) *Limiter {
+	t.Helper()
+	config := &Config{
+		Default: DefaultConfig{
+			Algorithm: "fixed_window",
+			Enabled:   true,
+		},
+		Rules: []RuleConfig{
+			{Name: "login", Path: "/api/login", By: "ip", Params: []string{"1", "1m"}},
+			{Name: "api", Path: "/api/*", By: "ip", Params: []string{"100", "1m"}},
+		},
+	}
+
+	limiter, err := NewFromConfig(config, NewMockStore())
+	if err != nil {
+		t.Fatalf("创建限流器失败: %v", err)
+	}
+	return limiter
+}
+
+// ruleNames 返回当前规则名称列表
+func ruleNames(l *Limiter) []string {
+	var names []string
+	for _, rule := range l.ListRules() {
+		names = append(names, rule.Name)
+	}
+	return names
+}
+
+func TestListRules(t *testing.T) {
+	limiter := newRulesLimiter(t)
+
+	rules := limiter.ListRules()
+	if len(rules) != 2 || rules[0].Name != "login" || rules[1].Name != "api" {
+		t.Fatalf("ListRules() = %v", ruleNames(limiter))
+	}
+
+	// 修改返回值不影响限流器
+	rules[0].Params[0] = "999"
+	if limiter.ListRules()[0].Params[0] != "1" {
+		t.Error("ListRules() 应该返回副本")
+	}
+
+	rule, err := limiter.GetRule("api")
+	if err != nil || rule.Path != "/api/*" {
+		t.Errorf("GetRule() = %+v, %v", rule, err)
+	}
+	if _, err := limiter.GetRule("missing"); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("GetRule() error = %v, want ErrRuleNotFound", err)
+	}
+}
+
+func TestAddRule(t *testing.T) {
+	limiter := newRulesLimiter(t)
+
+	err := limiter.AddRule(RuleConfig{Name: "upload", Path: "/upload", By: "user", Params: []string{"5", "1m"}})
+	if err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	// 新规则立即生效
+	result, _ := limiter.Check("/upload", "POST", "1.1.1.1", "u1")
+	if result.Limit != 5 {
+		t.Errorf("Limit = %d, want 5", result.Limit)
+	}
+
+	tests := []struct {
+		name string
+		rule RuleConfig
+		want error
+	}{
+		{"名称为空", RuleConfig{Path: "/x", By: "ip", Params: []string{"1", "1m"}}, nil},
+		{"名称重复", RuleConfig{Name: "api", Path: "/x", By: "ip", Params: []string{"1", "1m"}}, ErrRuleExists},
+		{"缺少path", RuleConfig{Name: "x", By: "ip", Params: []string{"1", "1m"}}, nil},
+		{"无效维度", RuleConfig{Name: "x", Path: "/x", By: "bad", Params: []string{"1", "1m"}}, nil},
+		{"无效阈值", RuleConfig{Name: "x", Path: "/x", By: "ip", Params: []string{"0", "1m"}}, nil},
+		{"未知提取器", RuleConfig{Name: "x", Path: "/x", By: "custom", Key: "unknown", Params: []string{"1", "1m"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := limiter.AddRule(tt.rule)
+			if err == nil {
+				t.Fatal("期望返回错误")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if got := len(limiter.ListRules()); got != 3 {
+		t.Errorf("添加失败时不应修改规则，规则数 = %d, want 3", got)
+	}
+}
+
+func TestInsertAndMoveRule(t *testing.T) {
+	limiter := newRulesLimiter(t)
+
+	if err := limiter.InsertRule(0, RuleConfig{Name: "first", Path: "/api/*", By: "ip", Params: []string{"3", "1m"}}); err != nil {
+		t.Fatalf("InsertRule() error = %v", err)
+	}
+	if got := fmt.Sprint(ruleNames(limiter)); got != "[first login api]" {
+		t.Errorf("规则顺序 = %s", got)
+	}
+
+	// 插入到最前面后优先匹配
+	result, _ := limiter.Check("/api/login", "POST", "1.1.1.1", "")
+	if result.Limit != 3 {
+		t.Errorf("Limit = %d, want 3", result.Limit)
+	}
+
+	if err := limiter.MoveRule("first", 10); err != nil {
+		t.Fatalf("MoveRule() error = %v", err)
+	}
+	if got := fmt.Sprint(ruleNames(limiter)); got != "[login api first]" {
+		t.Errorf("规则顺序 = %s", got)
+	}
+	if err := limiter.MoveRule("api", 0); err != nil {
+		t.Fatalf("MoveRule() error = %v", err)
+	}
+	if got := fmt.Sprint(ruleNames(limiter)); got != "[api login first]" {
+		t.Errorf("规则顺序 = %s", got)
+	}
+
+	if err := limiter.MoveRule("missing", 0); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("MoveRule() error = %v, want ErrRuleNotFound", err)
+	}
+}
+
+func TestUpdateRule(t *testing.T) {
+	limiter := newRulesLimiter(t)
+
+	// 未指定名称时沿用原名称
+	err := limiter.UpdateRule("login", RuleConfig{Path: "/api/login", By: "ip", Params: []string{"50", "1m"}})
+	if err != nil {
+		t.Fatalf("UpdateRule() error = %v", err)
+	}
+	result, _ := limiter.Check("/api/login", "POST", "1.1.1.1", "")
+	if result.Limit != 50 {
+		t.Errorf("Limit = %d, want 50", result.Limit)
+	}
+
+	// 重命名
+	err = limiter.UpdateRule("login", RuleConfig{Name: "signin", Path: "/api/login", By: "ip", Params: []string{"50", "1m"}})
+	if err != nil {
+		t.Fatalf("UpdateRule() error = %v", err)
+	}
+	if got := fmt.Sprint(ruleNames(limiter)); got != "[signin api]" {
+		t.Errorf("规则顺序 = %s", got)
+	}
+
+	if err := limiter.UpdateRule("signin", RuleConfig{Name: "api", Path: "/x", By: "ip", Params: []string{"1", "1m"}}); !errors.Is(err, ErrRuleExists) {
+		t.Errorf("UpdateRule() error = %v, want ErrRuleExists", err)
+	}
+	if err := limiter.UpdateRule("missing", RuleConfig{Path: "/x", By: "ip", Params: []string{"1", "1m"}}); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("UpdateRule() error = %v, want ErrRuleNotFound", err)
+	}
+	if err := limiter.UpdateRule("api", RuleConfig{Path: "/x", By: "ip", Algorithm: "bad", Params: []string{"1", "1m"}}); err == nil {
+		t.Error("无效算法应该返回错误")
+	}
+	if rule, _ := limiter.GetRule("api"); rule.Path != "/api/*" {
+		t.Error("更新失败时应该保留原规则")
+	}
+}
+
+func TestRemoveRule(t *testing.T) {
+	limiter := newRulesLimiter(t)
+
+	if err := limiter.RemoveRule("login"); err != nil {
+		t.Fatalf("RemoveRule() error = %v", err)
+	}
+
+	// 删除后由下一条匹配的规则处理
+	result, _ := limiter.Check("/api/login", "POST", "1.1.1.1", "")
+	if result.Limit != 100 {
+		t.Errorf("Limit = %d, want 100", result.Limit)
+	}
+
+	if err := limiter.RemoveRule("login"); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("RemoveRule() error = %v, want ErrRuleNotFound", err)
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	limiter := newRulesLimiter(t)
+
+	limiter.Check("/api/login", "POST", "1.1.1.1", "")
+	result, _ := limiter.Check("/api/login", "POST", "1.1.1.1", "")
+	if result.Allowed {
+		t.Fatal("第2次请求应该被拒绝")
+	}
+
+	limiter.SetEnabled(false)
+	if limiter.IsEnabled() {
+		t.Error("IsEnabled() 应该为false")
+	}
+	result, _ = limiter.Check("/api/login", "POST", "1.1.1.1", "")
+	if !result.Allowed {
+		t.Error("禁用后应该允许所有请求")
+	}
+
+	limiter.SetEnabled(true)
+	result, _ = limiter.Check("/api/login", "POST", "1.1.1.1", "")
+	if result.Allowed {
+		t.Error("重新启用后应该继续限流")
+	}
+	if len(limiter.ListRules()) != 2 {
+		t.Error("SetEnabled 不应修改规则")
+	}
+}
+
+// TestRuleManagement_Concurrent 测试规则修改与检查并发执行（配合 -race 使用）
+func TestRuleManagement_Concurrent(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	limiter, err := NewFromConfig(&Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+	}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if _, err := limiter.Check("/api/a", "GET", fmt.Sprintf("10.0.0.%d", i), ""); err != nil {
+					t.Errorf("Check() error = %v", err)
+					return
+				}
+				limiter.ListRules()
+			}
+		}(i)
+	}
+
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("rule%d", i)
+		if err := limiter.AddRule(RuleConfig{Name: name, Path: "/api/*", By: "ip", Params: []string{"10", "1m"}}); err != nil {
+			t.Fatalf("AddRule() error = %v", err)
+		}
+		limiter.SetEnabled(i%2 == 0)
+		if i%3 == 0 {
+			if err := limiter.RemoveRule(name); err != nil {
+				t.Fatalf("RemoveRule() error = %v", err)
+			}
+		}
+	}
+	wg.Wait()
+
+	if got := len(limiter.ListRules()); got != 13 {
+		t.Errorf("规则数 = %d, want 13", got)
+	}
+}
@@ -0,0 +1,237 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ToContextStore 将Store转换为ContextStore
+// 如果store本身实现了ContextStore则直接返回；否则包装为在每次调用前检查context状态的适配器
+func ToContextStore(store Store) ContextStore {
+	if cs, ok := store.(ContextStore); ok {
+		return cs
+	}
+	return &contextStore{store: store}
+}
+
+// contextStore 为不支持context的Store提供ContextStore适配
+// 底层调用无法被中断，但已取消或超时的context不会再发起新的调用
+type contextStore struct {
+	store Store
+}
+
+// GetContext 获取键的值
+func (s *contextStore) GetContext(ctx context.Context, key string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.Get(key)
+}
+
+// SetContext 设置键的值
+func (s *contextStore) SetContext(ctx context.Context, key string, value int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.Set(key, value)
+}
+
+// DelContext 删除键
+func (s *contextStore) DelContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.Del(key)
+}
+
+// IncrContext 增加键的值
+func (s *contextStore) IncrContext(ctx context.Context, key string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.Incr(key)
+}
+
+// IncrByContext 增加键的值指定数量
+func (s *contextStore) IncrByContext(ctx context.Context, key string, value int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.IncrBy(key, value)
+}
+
+// ExpireContext 设置键的过期时间
+func (s *contextStore) ExpireContext(ctx context.Context, key string, expiration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.Expire(key, expiration)
+}
+
+// TTLContext 获取键的剩余过期时间
+func (s *contextStore) TTLContext(ctx context.Context, key string) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.TTL(key)
+}
+
+// ZAddContext 添加有序集合成员
+func (s *contextStore) ZAddContext(ctx context.Context, key string, score float64, member string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.ZAdd(key, score, member)
+}
+
+// ZRemRangeByScoreContext 删除有序集合中指定分数范围的成员
+func (s *contextStore) ZRemRangeByScoreContext(ctx context.Context, key string, min, max float64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.ZRemRangeByScore(key, min, max)
+}
+
+// ZCountContext 统计有序集合中指定分数范围的成员数量
+func (s *contextStore) ZCountContext(ctx context.Context, key string, min, max float64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.store.ZCount(key, min, max)
+}
+
+// EvalContext 执行Lua脚本
+func (s *contextStore) EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.store.Eval(script, keys, args...)
+}
+
+// storeError 存储调用返回的错误，观察者只对这类错误触发 OnStoreError
+// Error和Unwrap保持原错误不变，调用方仍可用errors.Is判断原错误
+type storeError struct {
+	err error
+}
+
+func (e *storeError) Error() string { return e.err.Error() }
+func (e *storeError) Unwrap() error { return e.err }
+
+// isStoreError 判断错误链中是否包含存储调用返回的错误
+func isStoreError(err error) bool {
+	var se *storeError
+	return errors.As(err, &se)
+}
+
+// markStoreError 将存储调用返回的错误标记为storeError
+func markStoreError(err error) error {
+	if err == nil || isStoreError(err) {
+		return err
+	}
+	return &storeError{err: err}
+}
+
+// marked 标记带返回值的存储调用的错误
+func marked[T any](v T, err error) (T, error) {
+	return v, markStoreError(err)
+}
+
+// errorMarkingStore 标记存储返回错误的装饰器，限流器和算法驱动都通过它访问存储，
+// 从而区分存储错误和参数校验、规则配置等其他错误
+type errorMarkingStore struct {
+	store ContextStore
+}
+
+// newErrorMarkingStore 包装存储，标记其返回的错误
+func newErrorMarkingStore(store Store) *errorMarkingStore {
+	return &errorMarkingStore{store: ToContextStore(store)}
+}
+
+func (s *errorMarkingStore) Get(key string) (int64, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+func (s *errorMarkingStore) Set(key string, value int64) error {
+	return s.SetContext(context.Background(), key, value)
+}
+
+func (s *errorMarkingStore) Del(key string) error {
+	return s.DelContext(context.Background(), key)
+}
+
+func (s *errorMarkingStore) Incr(key string) (int64, error) {
+	return s.IncrContext(context.Background(), key)
+}
+
+func (s *errorMarkingStore) IncrBy(key string, value int64) (int64, error) {
+	return s.IncrByContext(context.Background(), key, value)
+}
+
+func (s *errorMarkingStore) Expire(key string, expiration time.Duration) error {
+	return s.ExpireContext(context.Background(), key, expiration)
+}
+
+func (s *errorMarkingStore) TTL(key string) (time.Duration, error) {
+	return s.TTLContext(context.Background(), key)
+}
+
+func (s *errorMarkingStore) ZAdd(key string, score float64, member string) error {
+	return s.ZAddContext(context.Background(), key, score, member)
+}
+
+func (s *errorMarkingStore) ZRemRangeByScore(key string, min, max float64) error {
+	return s.ZRemRangeByScoreContext(context.Background(), key, min, max)
+}
+
+func (s *errorMarkingStore) ZCount(key string, min, max float64) (int64, error) {
+	return s.ZCountContext(context.Background(), key, min, max)
+}
+
+func (s *errorMarkingStore) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return s.EvalContext(context.Background(), script, keys, args...)
+}
+
+func (s *errorMarkingStore) GetContext(ctx context.Context, key string) (int64, error) {
+	return marked(s.store.GetContext(ctx, key))
+}
+
+func (s *errorMarkingStore) SetContext(ctx context.Context, key string, value int64) error {
+	return markStoreError(s.store.SetContext(ctx, key, value))
+}
+
+func (s *errorMarkingStore) DelContext(ctx context.Context, key string) error {
+	return markStoreError(s.store.DelContext(ctx, key))
+}
+
+func (s *errorMarkingStore) IncrContext(ctx context.Context, key string) (int64, error) {
+	return marked(s.store.IncrContext(ctx, key))
+}
+
+func (s *errorMarkingStore) IncrByContext(ctx context.Context, key string, value int64) (int64, error) {
+	return marked(s.store.IncrByContext(ctx, key, value))
+}
+
+func (s *errorMarkingStore) ExpireContext(ctx context.Context, key string, expiration time.Duration) error {
+	return markStoreError(s.store.ExpireContext(ctx, key, expiration))
+}
+
+func (s *errorMarkingStore) TTLContext(ctx context.Context, key string) (time.Duration, error) {
+	return marked(s.store.TTLContext(ctx, key))
+}
+
+func (s *errorMarkingStore) ZAddContext(ctx context.Context, key string, score float64, member string) error {
+	return markStoreError(s.store.ZAddContext(ctx, key, score, member))
+}
+
+func (s *errorMarkingStore) ZRemRangeByScoreContext(ctx context.Context, key string, min, max float64) error {
+	return markStoreError(s.store.ZRemRangeByScoreContext(ctx, key, min, max))
+}
+
+func (s *errorMarkingStore) ZCountContext(ctx context.Context, key string, min, max float64) (int64, error) {
+	return marked(s.store.ZCountContext(ctx, key, min, max))
+}
+
+func (s *errorMarkingStore) EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return marked(s.store.EvalContext(ctx, script, keys, args...))
+}
@@ -0,0 +1,51 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// ctxMockStore 同时实现Store和ContextStore的模拟存储
+type ctxMockStore struct {
+	*MockStore
+	ContextStore
+}
+
+func TestToContextStore(t *testing.T) {
+	store := NewMockStore()
+	cs := ToContextStore(store)
+
+	// 普通Store会被包装
+	if _, ok := cs.(*contextStore); !ok {
+		t.Fatalf("普通Store应该被包装为contextStore, 实际 %T", cs)
+	}
+
+	// 已实现ContextStore的直接返回
+	both := &ctxMockStore{MockStore: store, ContextStore: cs}
+	if got := ToContextStore(both); got != ContextStore(both) {
+		t.Error("已实现ContextStore的存储应该直接返回")
+	}
+
+	ctx := context.Background()
+	count, err := cs.IncrByContext(ctx, "key", 3)
+	if err != nil {
+		t.Fatalf("IncrByContext() error = %v", err)
+	}
+	if count != 3 {
+		t.Errorf("IncrByContext() = %d, want 3", count)
+	}
+	if val, _ := cs.GetContext(ctx, "key"); val != 3 {
+		t.Errorf("GetContext() = %d, want 3", val)
+	}
+
+	// 已取消的context不再调用底层存储
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := cs.IncrContext(canceled, "key"); !errors.Is(err, context.Canceled) {
+		t.Errorf("IncrContext() error = %v, want context.Canceled", err)
+	}
+	if val, _ := store.Get("key"); val != 3 {
+		t.Errorf("取消后值不应变化, Get() = %d, want 3", val)
+	}
+}
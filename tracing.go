@@ -0,0 +1,72 @@
+package ratelimiter
+
+import (
+	"context"
+)
+
+// TracerName 创建Tracer时使用的instrumentation名称
+const TracerName = "github.com/Fischlvor/go-ratelimiter"
+
+// TracerProvider 链路追踪提供者
+// 核心包不依赖OpenTelemetry，通过实现该接口的适配器接入（drivers/tracing/otel 模块提供OpenTelemetry适配器）
+type TracerProvider interface {
+	// Tracer 按instrumentation名称获取Tracer
+	Tracer(name string) Tracer
+}
+
+// Tracer 创建span
+type Tracer interface {
+	// Start 开始一个span，返回携带该span的context，后续的存储调用作为其子span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span 追踪span
+type Span interface {
+	// SetAttributes 设置属性
+	SetAttributes(attrs ...Attribute)
+	// RecordError 记录错误
+	RecordError(err error)
+	// End 结束span
+	End()
+}
+
+// Attribute span属性，Value为string、int64、float64或bool
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// span属性名
+const (
+	AttrKeyPath      = "ratelimiter.path"
+	AttrKeyMethod    = "ratelimiter.method"
+	AttrKeyRule      = "ratelimiter.rule"
+	AttrKeyAlgorithm = "ratelimiter.algorithm"
+	AttrKeyDecision  = "ratelimiter.decision"
+	AttrKeyLimit     = "ratelimiter.limit"
+	AttrKeyRemaining = "ratelimiter.remaining"
+	AttrKeyReason    = "ratelimiter.reason"
+)
+
+// WithTracerProvider 设置链路追踪提供者，Check和Peek会创建span
+func WithTracerProvider(provider TracerProvider) Option {
+	return func(l *Limiter) {
+		if provider != nil {
+			l.tracer = provider.Tracer(TracerName)
+		}
+	}
+}
+
+// nopTracer 未设置链路追踪时使用的空实现
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+// nopSpan 空span
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+func (nopSpan) RecordError(error)          {}
+func (nopSpan) End()                       {}
@@ -0,0 +1,108 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+// MockSpan 记录属性和错误的模拟span
+type MockSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *MockSpan) SetAttributes(attrs ...Attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *MockSpan) RecordError(err error) { s.err = err }
+func (s *MockSpan) End()                  { s.ended = true }
+
+// MockTracerProvider 记录创建的span
+type MockTracerProvider struct {
+	name  string
+	spans []*MockSpan
+}
+
+func (p *MockTracerProvider) Tracer(name string) Tracer {
+	p.name = name
+	return p
+}
+
+func (p *MockTracerProvider) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &MockSpan{name: name, attrs: make(map[string]interface{})}
+	p.spans = append(p.spans, span)
+	return ctx, span
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	provider := &MockTracerProvider{}
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default:   DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Blacklist: BlacklistConfig{IPs: []string{"9.9.9.9"}},
+		Rules: []RuleConfig{
+			{Name: "api", Path: "/api", By: "ip", Params: []string{"5", "1m"}},
+		},
+	}
+	limiter, err := NewFromConfig(config, store, WithTracerProvider(provider))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	if provider.name != TracerName {
+		t.Errorf("Tracer name = %s, want %s", provider.name, TracerName)
+	}
+
+	limiter.Check("/api", "GET", "1.1.1.1", "")
+	limiter.Peek("/api", "GET", "1.1.1.1", "")
+	limiter.Check("/api", "GET", "9.9.9.9", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.CheckContext(ctx, "/api", "GET", "1.1.1.1", "")
+
+	if len(provider.spans) != 4 {
+		t.Fatalf("span数量 = %d, want 4", len(provider.spans))
+	}
+
+	check := provider.spans[0]
+	if check.name != "ratelimiter.Check" || !check.ended {
+		t.Errorf("span = %+v", check)
+	}
+	want := map[string]interface{}{
+		AttrKeyPath:      "/api",
+		AttrKeyMethod:    "GET",
+		AttrKeyRule:      "api",
+		AttrKeyAlgorithm: "fixed_window",
+		AttrKeyDecision:  DecisionAllowed,
+		AttrKeyLimit:     int64(5),
+		AttrKeyRemaining: int64(4),
+	}
+	for key, value := range want {
+		if check.attrs[key] != value {
+			t.Errorf("属性 %s = %v, want %v", key, check.attrs[key], value)
+		}
+	}
+
+	if peek := provider.spans[1]; peek.name != "ratelimiter.Peek" || peek.attrs[AttrKeyRemaining] != int64(4) {
+		t.Errorf("Peek span = %+v", peek)
+	}
+
+	// 黑名单拒绝没有匹配的规则
+	blacklisted := provider.spans[2]
+	if _, ok := blacklisted.attrs[AttrKeyRule]; ok || blacklisted.attrs[AttrKeyDecision] != DecisionDenied || blacklisted.attrs[AttrKeyReason] != string(ReasonBlacklisted) {
+		t.Errorf("黑名单span属性 = %v", blacklisted.attrs)
+	}
+
+	if failed := provider.spans[3]; !errors.Is(failed.err, context.Canceled) || !failed.ended {
+		t.Errorf("失败的span = %+v", failed)
+	}
+}
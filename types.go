@@ -1,6 +1,10 @@
 package ratelimiter
 
-import "time"
+import (
+	"context"
+	"net/http"
+	"time"
+)
 
 // Algorithm 限流算法类型
 type Algorithm string
@@ -10,8 +14,18 @@ const (
 	AlgorithmFixedWindow Algorithm = "fixed_window"
 	// AlgorithmSlidingWindow 滑动窗口计数器
 	AlgorithmSlidingWindow Algorithm = "sliding_window"
+	// AlgorithmSlidingWindowCounter 近似滑动窗口（按上一窗口的剩余比例加权，每个key只存储两个计数）
+	AlgorithmSlidingWindowCounter Algorithm = "sliding_window_counter"
 	// AlgorithmTokenBucket 令牌桶算法
 	AlgorithmTokenBucket Algorithm = "token_bucket"
+	// AlgorithmLeakyBucket 漏桶算法（计量模式，桶满时拒绝）
+	AlgorithmLeakyBucket Algorithm = "leaky_bucket"
+	// AlgorithmLeakyBucketQueue 漏桶算法（队列模式，请求排队后以恒定速率放行）
+	AlgorithmLeakyBucketQueue Algorithm = "leaky_bucket_queue"
+	// AlgorithmGCRA 通用信元速率算法（每个key只存储一个值，毫秒精度）
+	AlgorithmGCRA Algorithm = "gcra"
+	// AlgorithmConcurrency 并发限制（限制同时处理中的请求数，请求结束后需要释放名额）
+	AlgorithmConcurrency Algorithm = "concurrency"
 )
 
 // LimitBy 限流维度
@@ -30,6 +44,22 @@ const (
 	LimitByCustom LimitBy = "custom"
 )
 
+// Reason 请求被拒绝的原因
+type Reason string
+
+const (
+	// ReasonNone 请求通过
+	ReasonNone Reason = ""
+	// ReasonBlacklisted 命中配置文件中的静态黑名单
+	ReasonBlacklisted Reason = "blacklisted"
+	// ReasonBanned 命中动态封禁（自动拉黑或手动封禁）
+	ReasonBanned Reason = "banned"
+	// ReasonGlobalLimit 超出全局限流
+	ReasonGlobalLimit Reason = "global_limit"
+	// ReasonRuleLimit 超出规则限流
+	ReasonRuleLimit Reason = "rule_limit"
+)
+
 // Result 限流检查结果
 type Result struct {
 	// Allowed 是否允许通过
@@ -42,6 +72,76 @@ type Result struct {
 	Reset int64
 	// RetryAfter 建议重试时间（秒）
 	RetryAfter int64
+	// Delay 排队等待时间（仅leaky_bucket_queue算法），调用方应等待该时间后再处理请求
+	Delay time.Duration
+	// ResetMillis 重置时间（Unix毫秒时间戳，gcra和concurrency算法为精确值，其他算法由Reset换算）
+	ResetMillis int64
+	// RetryAfterMillis 建议重试时间（毫秒，gcra和concurrency算法为精确值，其他算法由RetryAfter换算）
+	RetryAfterMillis int64
+	// Leases 本次检查占用的并发名额（仅concurrency算法），请求处理完成后应调用 Limiter.Release 释放
+	Leases []Lease
+	// Refunds 本次检查消耗的可退还配额（仅fixed_window、sliding_window、token_bucket算法），下游处理失败时可调用 Limiter.Refund 退还
+	Refunds []Refund
+	// Reason 拒绝原因（通过时为空）
+	Reason Reason
+	// Rule 决定该结果的规则名称（黑白名单等未经过规则检查时为空）
+	Rule string
+	// Dimension 决定该结果的维度：规则为实际使用的限流维度（ip/user/path/global/custom），黑名单和封禁为ip/user
+	Dimension string
+	// Key 决定该结果的标识：规则为限流key，黑名单和封禁为用户ID或IP（IP聚合时为网段）
+	Key string
+	// BanTTL 封禁的剩余时长（仅banned，永久封禁为0）
+	BanTTL time.Duration
+	// rule 决定该结果的规则（黑白名单等未经过规则检查时为nil）
+	rule *Rule
+}
+
+// Lease 并发名额租约
+type Lease struct {
+	// Key 限流key
+	Key string
+	// ID 租约ID
+	ID string
+	// Duration 租约时长（规则的window），到期前未释放或续期时名额被自动回收
+	Duration time.Duration
+}
+
+// Refund 可退还的配额
+type Refund struct {
+	// Key 限流key
+	Key string
+	// Cost 消耗的配额数量
+	Cost int64
+	// rule 消耗配额时使用的规则（热更新后仍按原规则退还）
+	rule *Rule
+	// token 算法返回的退还凭证
+	token string
+}
+
+// Request 限流请求描述
+// 包含常用维度字段和任意自定义属性，用于规则匹配、key构建和黑白名单检查
+type Request struct {
+	// Path 请求路径
+	Path string
+	// Method HTTP方法
+	Method string
+	// IP 客户端IP
+	IP string
+	// UserID 用户ID（为空表示未登录）
+	UserID string
+	// Header 请求头（可选，供 header 提取器使用）
+	Header http.Header
+	// Attributes 自定义属性（如租户、API Key、地域等，供自定义维度的key提取器使用）
+	Attributes map[string]string
+	// Cost 请求消耗的配额数量（如批量接口的条数，小于1时按1计算；规则配置了cost时以规则为准）
+	Cost int64
+	// ContentLength 请求体字节数（-1表示未知，供 cost: content_length 使用）
+	ContentLength int64
+}
+
+// Attribute 获取自定义属性
+func (r *Request) Attribute(name string) string {
+	return r.Attributes[name]
 }
 
 // Rule 限流规则
@@ -54,20 +154,27 @@ type Rule struct {
 	Method string
 	// By 限流维度
 	By LimitBy
+	// Key 自定义维度的key提取器（仅By为custom时使用，格式 name 或 name:arg）
+	Key string
 	// Algorithm 限流算法
 	Algorithm Algorithm
 	// Limit 限流阈值（请求数）
 	Limit int64
-	// Window 时间窗口
+	// Window 时间窗口（concurrency算法为租约时长，持有者未释放时名额在到期后自动回收）
 	Window time.Duration
-	// Capacity 令牌桶容量（仅token_bucket算法使用）
+	// Capacity 令牌桶容量/漏桶容量（仅token_bucket、leaky_bucket系列和gcra算法使用）
 	Capacity int64
-	// Rate 令牌生成速率（每秒生成的令牌数，仅token_bucket算法使用）
+	// Rate 令牌生成速率/漏桶流出速率（每秒数量，仅token_bucket、leaky_bucket系列和gcra算法使用）
 	Rate float64
 	// RecordViolation 是否记录违规（用于自动拉黑）
 	RecordViolation bool
 	// ViolationWeight 违规权重（默认1，用于分级违规记录）
 	ViolationWeight int
+	// Continue 通过后是否继续检查后续匹配的规则
+	Continue bool
+	// Cost 请求消耗的配额数量表达式（为空时使用 Request.Cost）
+	// 支持固定值（如 5）、header:<name>、attr:<name>、content_length 和 content_length:<unit>
+	Cost string
 }
 
 // Store 存储接口
@@ -95,3 +202,30 @@ type Store interface {
 	// Eval 执行Lua脚本
 	Eval(script string, keys []string, args ...interface{}) (interface{}, error)
 }
+
+// ContextStore 支持context的存储接口
+// 所有操作接受context，用于传递请求的取消信号、超时和追踪信息
+type ContextStore interface {
+	// GetContext 获取键的值
+	GetContext(ctx context.Context, key string) (int64, error)
+	// SetContext 设置键的值
+	SetContext(ctx context.Context, key string, value int64) error
+	// DelContext 删除键
+	DelContext(ctx context.Context, key string) error
+	// IncrContext 增加键的值
+	IncrContext(ctx context.Context, key string) (int64, error)
+	// IncrByContext 增加键的值指定数量
+	IncrByContext(ctx context.Context, key string, value int64) (int64, error)
+	// ExpireContext 设置键的过期时间
+	ExpireContext(ctx context.Context, key string, expiration time.Duration) error
+	// TTLContext 获取键的剩余过期时间
+	TTLContext(ctx context.Context, key string) (time.Duration, error)
+	// ZAddContext 添加有序集合成员
+	ZAddContext(ctx context.Context, key string, score float64, member string) error
+	// ZRemRangeByScoreContext 删除有序集合中指定分数范围的成员
+	ZRemRangeByScoreContext(ctx context.Context, key string, min, max float64) error
+	// ZCountContext 统计有序集合中指定分数范围的成员数量
+	ZCountContext(ctx context.Context, key string, min, max float64) (int64, error)
+	// EvalContext 执行Lua脚本
+	EvalContext(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
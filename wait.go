@@ -0,0 +1,113 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrDenied 请求被拒绝且无法通过等待获得配额（如命中黑名单）
+	ErrDenied = errors.New("请求被拒绝")
+	// ErrWaitExceedsDeadline 需要等待的时间超过context的截止时间
+	ErrWaitExceedsDeadline = errors.New("等待时间超过截止时间")
+)
+
+// Reservation 配额预约结果
+type Reservation struct {
+	// OK 是否已获得配额；为false时未消耗配额，应在Delay后重试
+	OK bool
+	// Delay 需要等待的时间（已获得配额时为漏桶队列的排队时间，否则为建议重试时间）
+	Delay time.Duration
+	// Result 限流检查结果
+	Result *Result
+}
+
+// Reserve 尝试获取配额并返回需要等待的时间
+func (l *Limiter) Reserve(ctx context.Context, path, method, ip, userID string) (*Reservation, error) {
+	return l.ReserveRequest(ctx, &Request{
+		Path:   path,
+		Method: method,
+		IP:     ip,
+		UserID: userID,
+	})
+}
+
+// ReserveRequest 尝试获取配额并返回需要等待的时间（使用请求描述）
+// 先只读预检，预检通过后才消耗配额；无论拒绝发生在预检还是消耗时，都不记录违规、不统计、不通知观察者，
+// Delay由算法的重试时间得出；请求消耗超过规则容量等无法通过等待获得配额时返回 ErrDenied
+func (l *Limiter) ReserveRequest(ctx context.Context, req *Request) (*Reservation, error) {
+	result, err := l.check(ctx, req, modePeek)
+	if err != nil {
+		return nil, err
+	}
+	if result.Allowed {
+		// 预检和消耗之间配额可能被其他请求占用，仍按消耗时的结果返回
+		if result, err = l.check(ctx, req, modeReserve); err != nil {
+			return nil, err
+		}
+		if result.Allowed {
+			return &Reservation{OK: true, Delay: result.Delay, Result: result}, nil
+		}
+	}
+	if result.RetryAfterMillis <= 0 {
+		return nil, ErrDenied
+	}
+	return &Reservation{Delay: time.Duration(result.RetryAfterMillis) * time.Millisecond, Result: result}, nil
+}
+
+// Wait 阻塞直到获得配额（用于调用第三方接口等客户端限速场景）
+func (l *Limiter) Wait(ctx context.Context, path, method, ip, userID string) (*Result, error) {
+	return l.WaitRequest(ctx, &Request{
+		Path:   path,
+		Method: method,
+		IP:     ip,
+		UserID: userID,
+	})
+}
+
+// WaitRequest 阻塞直到获得配额（使用请求描述）
+// 被拒绝时按重试时间等待后再次检查，漏桶队列模式会等待排队时间后返回；
+// context结束时返回其错误，剩余时间不足以等待时立即返回 ErrWaitExceedsDeadline
+// 已获得配额后在排队期间context结束时，释放占用的并发名额并退还配额
+// 使用concurrency算法时，返回结果中的名额仍需调用 Release 释放
+func (l *Limiter) WaitRequest(ctx context.Context, req *Request) (*Result, error) {
+	for {
+		r, err := l.ReserveRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !r.OK {
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < r.Delay {
+				return nil, ErrWaitExceedsDeadline
+			}
+		}
+		if err := sleepContext(ctx, r.Delay); err != nil {
+			if r.OK {
+				cleanupCtx := context.WithoutCancel(ctx)
+				_ = l.ReleaseContext(cleanupCtx, r.Result)
+				_ = l.RefundContext(cleanupCtx, r.Result)
+			}
+			return nil, err
+		}
+		if r.OK {
+			return r.Result, nil
+		}
+	}
+}
+
+// sleepContext 等待指定时间，context结束时提前返回其错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
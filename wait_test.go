@@ -0,0 +1,280 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Fischlvor/go-ratelimiter/drivers/store/memory"
+)
+
+// newWaitLimiter 创建只有一条规则的限流器
+func newWaitLimiter(t *testing.T, algorithm string, params []string) *Limiter {
+	t.Helper()
+	store := memory.NewStore(0)
+	t.Cleanup(func() { store.Close() })
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: algorithm, Enabled: true},
+		Blacklist: BlacklistConfig{
+			IPs: []string{"10.0.0.1"},
+		},
+		Rules: []RuleConfig{
+			{Name: "vendor", Path: "/vendor/api", By: "global", Params: params},
+		},
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	return limiter
+}
+
+func TestReserve(t *testing.T) {
+	limiter := newWaitLimiter(t, "gcra", []string{"1", "10/s"})
+	ctx := context.Background()
+
+	r, err := limiter.Reserve(ctx, "/vendor/api", "GET", "", "")
+	if err != nil || !r.OK || r.Delay != 0 {
+		t.Fatalf("Reserve() = %+v, %v, want ok without delay", r, err)
+	}
+
+	// 配额用尽时不消耗配额，返回建议的等待时间
+	r, err = limiter.Reserve(ctx, "/vendor/api", "GET", "", "")
+	if err != nil || r.OK {
+		t.Fatalf("Reserve() = %+v, %v, want not ok", r, err)
+	}
+	if r.Delay <= 0 || r.Delay > 100*time.Millisecond {
+		t.Errorf("Delay = %v, want (0, 100ms]", r.Delay)
+	}
+
+	// 黑名单无法通过等待获得配额
+	if _, err := limiter.Reserve(ctx, "/vendor/api", "GET", "10.0.0.1", ""); !errors.Is(err, ErrDenied) {
+		t.Errorf("Reserve() error = %v, want ErrDenied", err)
+	}
+}
+
+func TestWait(t *testing.T) {
+	limiter := newWaitLimiter(t, "gcra", []string{"1", "20/s"})
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Wait(ctx, "/vendor/api", "GET", "", "")
+		if err != nil || !result.Allowed {
+			t.Fatalf("第%d次Wait() = %+v, %v", i+1, result, err)
+		}
+	}
+	// 第一次立即通过，之后每次间隔50ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("elapsed = %v, want >= 100ms", elapsed)
+	}
+}
+
+func TestWait_QueueDelay(t *testing.T) {
+	limiter := newWaitLimiter(t, "leaky_bucket_queue", []string{"5", "20/s"})
+	ctx := context.Background()
+
+	limiter.Wait(ctx, "/vendor/api", "GET", "", "")
+	start := time.Now()
+	if _, err := limiter.Wait(ctx, "/vendor/api", "GET", "", ""); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("elapsed = %v, want about 50ms", elapsed)
+	}
+}
+
+func TestWait_Context(t *testing.T) {
+	limiter := newWaitLimiter(t, "sliding_window", []string{"1", "1m"})
+	limiter.Check("/vendor/api", "GET", "", "")
+
+	// 剩余时间不足时立即返回
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if _, err := limiter.Wait(ctx, "/vendor/api", "GET", "", ""); !errors.Is(err, ErrWaitExceedsDeadline) {
+		t.Errorf("Wait() error = %v, want ErrWaitExceedsDeadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("elapsed = %v, 应该立即返回", elapsed)
+	}
+
+	// 等待期间取消
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := limiter.Wait(ctx, "/vendor/api", "GET", "", ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestWait_RetryDoesNotRecordViolation(t *testing.T) {
+	observer := &MockObserver{}
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "vendor", Path: "/vendor/api", By: "ip", Params: []string{"1", "1s"}, RecordViolation: true},
+		},
+		AutoBan: AutoBanConfig{
+			Enabled:            true,
+			Dimensions:         []string{BanDimensionIP},
+			ViolationThreshold: 1,
+			ViolationWindow:    "1m",
+			BanDuration:        "1h",
+		},
+	}
+	limiter, err := NewFromConfig(config, store, WithObserver(observer))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	ctx := context.Background()
+
+	// 配额用尽后预约只做预检，不消耗配额、不记录违规
+	for i := 0; i < 3; i++ {
+		r, err := limiter.Reserve(ctx, "/vendor/api", "GET", "1.2.3.4", "")
+		if err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+		if r.OK != (i == 0) {
+			t.Fatalf("第%d次Reserve() OK = %v", i+1, r.OK)
+		}
+	}
+	if count, _ := store.Get("vendor:ip:1.2.3.4"); count != 1 {
+		t.Errorf("计数 = %d, want 1", count)
+	}
+	if len(observer.violations) != 0 || len(observer.bans) != 0 || len(observer.denied) != 0 {
+		t.Errorf("violations=%d bans=%d denied=%d, want 0",
+			len(observer.violations), len(observer.bans), len(observer.denied))
+	}
+
+	// 等待期间的重试不会导致自动拉黑
+	waitCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	if result, err := limiter.Wait(waitCtx, "/vendor/api", "GET", "1.2.3.4", ""); err != nil || !result.Allowed {
+		t.Fatalf("Wait() = %+v, %v", result, err)
+	}
+	if bans, _ := limiter.ListBans(BanDimensionIP); len(bans) != 0 {
+		t.Errorf("ListBans() = %+v, 等待重试不应触发自动拉黑", bans)
+	}
+}
+
+func TestWait_CanceledWhileQueuedReturnsQuota(t *testing.T) {
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "leaky_bucket_queue", Enabled: true},
+		Global:  &GlobalConfig{Algorithm: "fixed_window", Params: []string{"2", "1m"}},
+		Rules: []RuleConfig{
+			{Name: "vendor", Path: "/vendor/api", By: "global", Params: []string{"5", "10/s"}},
+		},
+	}
+	limiter, err := NewFromConfig(config, store)
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+
+	if _, err := limiter.Wait(context.Background(), "/vendor/api", "GET", "1.2.3.4", ""); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	// 第二次需要排队约100ms，排队期间取消时退还全局配额
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := limiter.Wait(ctx, "/vendor/api", "GET", "1.2.3.4", ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() error = %v, want context.Canceled", err)
+	}
+
+	peek, err := limiter.Peek("/other", "GET", "1.2.3.4", "")
+	if err != nil {
+		t.Fatalf("Peek() error = %v", err)
+	}
+	if peek.Remaining != 1 {
+		t.Errorf("Remaining = %d, want 1", peek.Remaining)
+	}
+}
+
+func TestWait_CostExceedsCapacity(t *testing.T) {
+	for _, tt := range []struct {
+		algorithm string
+		params    []string
+	}{
+		{"fixed_window", []string{"5", "1s"}},
+		{"token_bucket", []string{"5", "10/s"}},
+		{"gcra", []string{"5", "10/s"}},
+	} {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			limiter := newWaitLimiter(t, tt.algorithm, tt.params)
+			req := &Request{Path: "/vendor/api", Method: "GET", Cost: 10}
+
+			// 消耗超过容量时等待多久都无法通过，没有截止时间也应立即返回
+			done := make(chan error, 1)
+			go func() {
+				_, err := limiter.WaitRequest(context.Background(), req)
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				if !errors.Is(err, ErrDenied) {
+					t.Errorf("WaitRequest() error = %v, want ErrDenied", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("WaitRequest() 没有返回")
+			}
+
+			result, err := limiter.CheckRequest(req)
+			if err != nil || result.Allowed || result.RetryAfterMillis != 0 {
+				t.Errorf("CheckRequest() = %+v, %v, want denied without retry", result, err)
+			}
+		})
+	}
+}
+
+func TestReserve_LostRaceHasNoSideEffects(t *testing.T) {
+	observer := &MockObserver{}
+	store := memory.NewStore(0)
+	defer store.Close()
+
+	config := &Config{
+		Default: DefaultConfig{Algorithm: "fixed_window", Enabled: true},
+		Rules: []RuleConfig{
+			{Name: "vendor", Path: "/vendor/api", By: "ip", Params: []string{"1", "1m"}, RecordViolation: true},
+		},
+		AutoBan: AutoBanConfig{
+			Enabled:            true,
+			Dimensions:         []string{BanDimensionIP},
+			ViolationThreshold: 1,
+			ViolationWindow:    "1m",
+			BanDuration:        "1h",
+		},
+	}
+	limiter, err := NewFromConfig(config, store, WithObserver(observer))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	req := &Request{Path: "/vendor/api", Method: "GET", IP: "1.2.3.4"}
+
+	// 模拟预检通过后配额被其他请求抢占：直接以预约方式消耗已用尽的配额
+	if result, err := limiter.check(context.Background(), req, modeReserve); err != nil || !result.Allowed {
+		t.Fatalf("check() = %+v, %v", result, err)
+	}
+	result, err := limiter.check(context.Background(), req, modeReserve)
+	if err != nil || result.Allowed {
+		t.Fatalf("check() = %+v, %v, want denied", result, err)
+	}
+
+	if len(observer.violations) != 0 || len(observer.bans) != 0 || len(observer.denied) != 0 {
+		t.Errorf("violations=%d bans=%d denied=%d, want 0",
+			len(observer.violations), len(observer.bans), len(observer.denied))
+	}
+	if len(observer.allowed) != 1 {
+		t.Errorf("allowed=%d, want 1", len(observer.allowed))
+	}
+	if bans, _ := limiter.ListBans(BanDimensionIP); len(bans) != 0 {
+		t.Errorf("ListBans() = %+v, want none", bans)
+	}
+}